@@ -0,0 +1,73 @@
+package servicelogger
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// clfTimeLayout is the layout of the timestamp of the Common Log Format
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// formatAccess returns the line for an entry written by HTTPMiddleware with HTTPLogConfig.Access set, in Common Log
+// Format or, when combined is set, in Combined Log Format. Other entries are written in LF_TEXT format, so that they
+// are not lost but are easy for analyzers to skip
+func formatAccess(e *Entry, combined bool, sanitize SanitizeMode) string {
+	status, ok := e.Fields["status"]
+	if !ok || e.Fields["method"] == nil {
+		return formatEntry(e, LF_TEXT, sanitize)
+	}
+	host := accessField(e, "remote")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	size := accessField(e, "bytes")
+	if size == "0" {
+		size = "-"
+	}
+	// like Apache, the time the request was received
+	received := e.Time
+	if duration, ok := e.Fields["duration"].(time.Duration); ok {
+		received = received.Add(-duration)
+	}
+	request := fmt.Sprintf("%s %s %s", fieldString(e.Fields["method"]), accessField(e, "uri"), accessField(e, "proto"))
+	line := fmt.Sprintf("%s - %s [%s] %s %s %s", host, accessField(e, "user"), received.Format(clfTimeLayout), quoteAccess(request), fieldString(status), size)
+	if combined {
+		line += " " + quoteAccess(accessField(e, "referer")) + " " + quoteAccess(accessField(e, "user_agent"))
+	}
+	return line + "\n"
+}
+
+// accessField returns a field of an access entry as text, or "-" when it is missing or empty
+func accessField(e *Entry, key string) string {
+	value, ok := e.Fields[key]
+	if !ok {
+		return "-"
+	}
+	if s := fieldString(value); s != "" {
+		return s
+	}
+	return "-"
+}
+
+// quoteAccess quotes s the way Apache does in access logs: quotes and backslashes are escaped with a backslash, and
+// control characters and bytes outside ASCII as \xhh
+func quoteAccess(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
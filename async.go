@@ -0,0 +1,239 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type BackpressurePolicy int
+
+const (
+	BP_BLOCK       BackpressurePolicy = 1 // the log call waits until there is room in the queue
+	BP_DROP_NEWEST BackpressurePolicy = 2 // the entry being logged is dropped
+	BP_DROP_OLDEST BackpressurePolicy = 3 // the oldest queued entry that may be dropped makes room for the new one
+)
+
+// BackpressureConfig selects what an asynchronous Logger does when its queue is full. Zero members get their default
+type BackpressureConfig struct {
+	Policy BackpressurePolicy              // policy of levels without a policy of their own, defaults to BP_BLOCK
+	Levels map[LogLevel]BackpressurePolicy // policies of individual levels, e.g. BP_DROP_NEWEST for LL_TRACE
+}
+
+// asyncWriter writes queued lines to the log file from a single background goroutine. Because the queue is a single
+// FIFO and there is exactly one consumer, lines are written in the order they were queued
+type asyncWriter struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []asyncItem
+	size     int
+	closed   bool
+	stopped  chan struct{}
+}
+
+// asyncItem is either a line to write for an entry or, when flushed is set, a flush barrier. Items that are droppable
+// may be dropped by BP_DROP_OLDEST
+type asyncItem struct {
+	line      string
+	entry     *Entry
+	flushed   chan struct{}
+	droppable bool
+}
+
+// EnableAsync makes the Logger hand entries to a background goroutine that writes them to the log file, so that log
+// calls only block while the queue of queueSize entries is full. EnableAsync must be called before the Logger is used
+// from multiple goroutines.
+//
+// In asynchronous mode the Logger guarantees that:
+//   - entries logged by a single goroutine are written in the order of the log calls
+//   - entries logged by different goroutines are written in the order they were queued, and a log call that returns
+//     before another log call starts is queued before it
+//   - every entry is timestamped when it is logged, not when it is written
+//   - Flush returns only after every entry logged before the call to Flush has been written, so the writes happen
+//     before Flush returns
+//   - LogFatal flushes the queue before exiting
+//
+// Entries that are dropped because of the backpressure policy are not written at all; the order of the others holds
+func (l *Logger) EnableAsync(queueSize int) error {
+	if l.async != nil {
+		return errors.New("logger is already asynchronous")
+	}
+	if queueSize < 1 {
+		return errors.New("queue size too low (>=1)")
+	}
+	a := &asyncWriter{
+		size:    queueSize,
+		stopped: make(chan struct{}),
+	}
+	a.notEmpty = sync.NewCond(&a.mu)
+	a.notFull = sync.NewCond(&a.mu)
+	go a.run(l)
+	l.async = a
+	return nil
+}
+
+// SetBackpressure selects what log calls do while the queue of an asynchronous Logger is full: wait for room, drop the
+// entry being logged, or drop the oldest queued entry whose level may be dropped. ERROR and FATAL entries are never
+// dropped, whatever the configuration. Dropped entries are counted in Stats. SetBackpressure must be called before the
+// Logger is used from multiple goroutines
+func (l *Logger) SetBackpressure(config BackpressureConfig) {
+	l.backpressure = config
+}
+
+// backpressurePolicy returns the policy for entries at level
+func (l *Logger) backpressurePolicy(level LogLevel) BackpressurePolicy {
+	if level.atLeast(LL_ERROR) {
+		return BP_BLOCK
+	}
+	if policy, ok := l.backpressure.Levels[level]; ok {
+		return policy
+	}
+	if l.backpressure.Policy == 0 {
+		return BP_BLOCK
+	}
+	return l.backpressure.Policy
+}
+
+// DisableAsync writes all queued entries, stops the background goroutine and returns the Logger to synchronous
+// writing. It must not be called while other goroutines are logging
+func (l *Logger) DisableAsync() {
+	if l.async == nil {
+		return
+	}
+	l.async.stop(context.Background())
+	l.async = nil
+}
+
+// Flush blocks until all entries logged before the call have been written to the log file. It establishes a
+// happens-before relation between those writes and the return of Flush. For a synchronous Logger it only flushes the
+// write buffer, if any
+func (l *Logger) Flush() {
+	if a := l.async; a != nil {
+		<-a.barrier()
+	}
+	l.flushBuffer()
+}
+
+// stop closes the queue and waits until the background goroutine has written the queued entries and exited, or until
+// ctx is done, in which case the entries still queued are discarded. It returns the number of discarded entries
+func (a *asyncWriter) stop(ctx context.Context) int {
+	a.mu.Lock()
+	a.closed = true
+	a.notEmpty.Signal()
+	a.notFull.Broadcast()
+	a.mu.Unlock()
+	select {
+	case <-a.stopped:
+		return 0
+	case <-ctx.Done():
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	discarded := 0
+	for _, item := range a.items {
+		if item.flushed != nil {
+			close(item.flushed)
+		} else {
+			discarded++
+		}
+	}
+	a.items = nil
+	return discarded
+}
+
+// barrier queues a flush barrier and returns the channel that is closed once it is reached. Barriers do not count
+// against the size of the queue, so that flushing never waits for room. Once the queue is stopped the channel is closed
+// right away
+func (a *asyncWriter) barrier() chan struct{} {
+	flushed := make(chan struct{})
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		close(flushed)
+		return flushed
+	}
+	a.items = append(a.items, asyncItem{flushed: flushed})
+	a.notEmpty.Signal()
+	a.mu.Unlock()
+	return flushed
+}
+
+// enqueue queues the line for an entry, applying policy while the queue is full. It returns the number of entries
+// dropped, either e or an older one. Entries queued after the queue is stopped are dropped
+func (a *asyncWriter) enqueue(line string, e *Entry, policy BackpressurePolicy) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dropped := 0
+	for len(a.items) >= a.size || a.closed {
+		if a.closed {
+			return dropped + 1
+		}
+		if policy == BP_DROP_NEWEST || (policy == BP_DROP_OLDEST && !a.dropOldest()) {
+			return 1
+		}
+		if policy == BP_DROP_OLDEST {
+			dropped++
+			continue
+		}
+		a.notFull.Wait()
+	}
+	a.items = append(a.items, asyncItem{line: line, entry: e, droppable: policy != BP_BLOCK})
+	a.notEmpty.Signal()
+	return dropped
+}
+
+// dropOldest removes the oldest droppable entry from the queue and reports whether there was one
+func (a *asyncWriter) dropOldest() bool {
+	for n, item := range a.items {
+		if item.droppable {
+			a.items = append(a.items[:n], a.items[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns the number of queued entries, not counting flush barriers
+func (a *asyncWriter) pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	count := 0
+	for _, item := range a.items {
+		if item.flushed == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// run writes the queued entries until the queue is closed. The writer counts as logging throughout, since everything
+// it calls into, such as hooks and sinks, runs on behalf of a log call
+func (a *asyncWriter) run(l *Logger) {
+	defer close(a.stopped)
+	id := goroutineID()
+	l.reentry.active.Store(id, struct{}{})
+	defer l.reentry.active.Delete(id)
+	for {
+		a.mu.Lock()
+		for len(a.items) == 0 && !a.closed {
+			a.notEmpty.Wait()
+		}
+		if len(a.items) == 0 {
+			a.mu.Unlock()
+			return
+		}
+		item := a.items[0]
+		a.items[0] = asyncItem{}
+		a.items = a.items[1:]
+		a.notFull.Signal()
+		a.mu.Unlock()
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		l.writeFile(item.entry, item.line)
+		l.writeSinks(item.entry)
+		l.putEntry(item.entry)
+	}
+}
@@ -0,0 +1,140 @@
+package servicelogger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a Logger writing to a file in a temporary directory, closed when the test ends
+func newTestLogger(t *testing.T, rotate bool, rotatesize string, keep int) (*Logger, string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "test.log")
+	l0, err := New("test", filename, LL_TRACE, rotate, rotatesize, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &l0
+	t.Cleanup(func() { l.Close() })
+	return l, filename
+}
+
+// readMessages returns the messages of the LF_TEXT lines in filename
+func readMessages(t *testing.T, filename string) []string {
+	t.Helper()
+	fh, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	var messages []string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if _, message, ok := strings.Cut(scanner.Text(), "test.async "); ok {
+			messages = append(messages, message)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestAsyncOrderWithinGoroutine(t *testing.T) {
+	l, filename := newTestLogger(t, false, "10M", 1)
+	if err := l.EnableAsync(8); err != nil {
+		t.Fatal(err)
+	}
+	const goroutines, entries = 8, 2000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < entries; n++ {
+				l.LogInfo("TestAsyncOrder", "async", fmt.Sprintf("%d %d", g, n))
+			}
+		}(g)
+	}
+	wg.Wait()
+	l.Flush()
+	messages := readMessages(t, filename)
+	if len(messages) != goroutines*entries {
+		t.Fatalf("got %d entries, want %d", len(messages), goroutines*entries)
+	}
+	next := make([]int, goroutines)
+	for _, message := range messages {
+		var g, n int
+		if _, err := fmt.Sscanf(message, "%d %d", &g, &n); err != nil {
+			t.Fatalf("unexpected message %q", message)
+		}
+		if n != next[g] {
+			t.Fatalf("goroutine %d: got entry %d, want %d", g, n, next[g])
+		}
+		next[g]++
+	}
+}
+
+// slowSink delays every entry, so that the background goroutine lags behind the log calls
+type slowSink struct {
+	written atomic.Int64
+}
+
+func (s *slowSink) WriteEntry(e *Entry) error {
+	time.Sleep(200 * time.Microsecond)
+	s.written.Add(1)
+	return nil
+}
+
+func TestAsyncFlushWaitsForQueuedEntries(t *testing.T) {
+	l, filename := newTestLogger(t, false, "10M", 1)
+	sink := &slowSink{}
+	l.AddSink(sink, SinkConfig{})
+	if err := l.EnableAsync(1000); err != nil {
+		t.Fatal(err)
+	}
+	for round := 1; round <= 5; round++ {
+		for n := 0; n < 200; n++ {
+			l.LogInfo("TestAsyncFlush", "async", fmt.Sprintf("%d %d", round, n))
+		}
+		l.Flush()
+		if written := sink.written.Load(); written != int64(round*200) {
+			t.Fatalf("round %d: %d entries handed to the sink when Flush returned, want %d", round, written, round*200)
+		}
+		if got := len(readMessages(t, filename)); got != round*200 {
+			t.Fatalf("round %d: %d entries in the log file when Flush returned, want %d", round, got, round*200)
+		}
+		if pending := l.async.pending(); pending != 0 {
+			t.Fatalf("round %d: %d entries still queued after Flush", round, pending)
+		}
+	}
+}
+
+func TestShutdownStopsAsyncWriter(t *testing.T) {
+	l, filename := newTestLogger(t, false, "10M", 1)
+	if err := l.EnableAsync(100); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 50; n++ {
+		l.LogInfo("TestShutdown", "async", fmt.Sprint(n))
+	}
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-l.async.stopped:
+	default:
+		t.Fatal("background goroutine still running after Shutdown")
+	}
+	if got := len(readMessages(t, filename)); got != 50 {
+		t.Fatalf("%d entries written, want 50", got)
+	}
+	l.Flush()
+}
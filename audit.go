@@ -0,0 +1,255 @@
+package servicelogger
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditArchiveLayout is the layout of the timestamp appended to the name of an archived audit log
+const auditArchiveLayout = "20060102T150405.000000000Z"
+
+// AuditConfig configures the audit log of a Logger. Zero members get their default
+type AuditConfig struct {
+	Filename   string   // path of the audit log
+	Facilities []string // facility prefixes, as used by facility filters, of the audit facilities
+	HMACKey    []byte   // key of the HMAC chain over the records, no chain when empty
+	ArchiveAt  string   // size at which the audit log is archived, as the rotation size of New, defaults to "100M"
+}
+
+// auditRecord is a line of an audit log. HMAC is the hex HMAC-SHA256 of the HMAC of the previous record, the sequence
+// number and the entry, separated by colons, and chains every record to all records before it
+type auditRecord struct {
+	Seq   uint64          `json:"seq"`
+	Entry json.RawMessage `json:"entry"`
+	HMAC  string          `json:"hmac,omitempty"`
+}
+
+// auditLog writes the entries of the audit facilities to a file of their own
+type auditLog struct {
+	mu         sync.Mutex
+	filename   string
+	facilities []string
+	key        []byte
+	archiveAt  int64
+	fh         *os.File
+	size       int64
+	seq        uint64
+	mac        string
+}
+
+// EnableAudit writes the entries of the audit facilities to an append-only audit log with stricter guarantees than the
+// log file:
+//   - entries of audit facilities are never filtered by level
+//   - every entry is written and synced to disk before the log call returns, also for an asynchronous Logger
+//   - records are numbered without gaps, continuing after the last record when the Logger is restarted
+//   - with an HMAC key, every record carries an HMAC over the record and the HMAC of the previous record, so that
+//     changed, removed or reordered records are detected by VerifyAuditLog
+//   - at the archive size the log is renamed to a name with a timestamp, and archives are never deleted
+//
+// Audit entries are still written to the log file and the sinks like other entries. Entries that cannot be written to
+// the audit log are counted in Stats. EnableAudit must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableAudit(config AuditConfig) error {
+	if l.audit != nil {
+		return errors.New("audit log is already enabled")
+	}
+	if config.Filename == "" || len(config.Facilities) == 0 {
+		return errors.New("audit log needs a file name and at least one facility")
+	}
+	if config.ArchiveAt == "" {
+		config.ArchiveAt = "100M"
+	}
+	size, err := logSizeStringToLogSizeInt64(config.ArchiveAt)
+	if err != nil {
+		return err
+	}
+	a := &auditLog{filename: config.Filename, facilities: config.Facilities, key: config.HMACKey, archiveAt: size}
+	if err = a.resume(); err != nil {
+		return err
+	}
+	if a.fh, err = os.OpenFile(a.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640); err != nil {
+		return err
+	}
+	info, err := a.fh.Stat()
+	if err != nil {
+		a.fh.Close()
+		return err
+	}
+	a.size = info.Size()
+	l.audit = a
+	return nil
+}
+
+// auditFiles returns the archives of the audit log filename, oldest first, followed by filename
+func auditFiles(filename string) ([]string, error) {
+	archives, err := filepath.Glob(filename + ".[0-9]*Z")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(archives)
+	return append(archives, filename), nil
+}
+
+// resume picks up the sequence number and HMAC of the last record, from the audit log or else from its newest archive
+func (a *auditLog) resume() error {
+	files, err := auditFiles(a.filename)
+	if err != nil {
+		return err
+	}
+	for n := len(files) - 1; n >= 0; n-- {
+		record, found, err := lastAuditRecord(files[n])
+		if err != nil {
+			return err
+		}
+		if found {
+			a.seq, a.mac = record.Seq, record.HMAC
+			return nil
+		}
+	}
+	return nil
+}
+
+func lastAuditRecord(name string) (auditRecord, bool, error) {
+	var last auditRecord
+	fh, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, false, nil
+	}
+	if err != nil {
+		return last, false, err
+	}
+	defer fh.Close()
+	found := false
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return last, false, fmt.Errorf("%s: unable to read audit record: %w", name, err)
+		}
+		last, found = record, true
+	}
+	return last, found, scanner.Err()
+}
+
+// auditMAC returns the HMAC of a record chained to the HMAC of the previous record
+func auditMAC(key []byte, previous string, seq uint64, entry []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(previous))
+	mac.Write([]byte(":" + strconv.FormatUint(seq, 10) + ":"))
+	mac.Write(entry)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// write appends e to the audit log as the next record and syncs it, archiving the log first when it is full
+func (a *auditLog) write(e *Entry) error {
+	entry := strings.TrimSuffix(formatJSON(e, nil), "\n")
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.size >= a.archiveAt {
+		if err := a.archive(); err != nil {
+			return err
+		}
+	}
+	seq := a.seq + 1
+	var line string
+	var mac string
+	if len(a.key) > 0 {
+		mac = auditMAC(a.key, a.mac, seq, []byte(entry))
+		line = fmt.Sprintf(`{"seq":%d,"entry":%s,"hmac":"%s"}`+"\n", seq, entry, mac)
+	} else {
+		line = fmt.Sprintf(`{"seq":%d,"entry":%s}`+"\n", seq, entry)
+	}
+	if _, err := a.fh.WriteString(line); err != nil {
+		return err
+	}
+	if err := a.fh.Sync(); err != nil {
+		return err
+	}
+	a.size += int64(len(line))
+	a.seq, a.mac = seq, mac
+	return nil
+}
+
+// archive renames the audit log to a name with the current time and starts a new one
+func (a *auditLog) archive() error {
+	archived := a.filename + "." + time.Now().UTC().Format(auditArchiveLayout)
+	if err := os.Rename(a.filename, archived); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(a.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	a.fh.Close()
+	a.fh, a.size = fh, 0
+	syncDir(filepath.Dir(a.filename))
+	return nil
+}
+
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.fh.Close()
+}
+
+// VerifyAuditLog checks the audit log filename and its archives, oldest first, and returns the number of records. It
+// returns an error for the first record that does not follow the previous one without a gap, and, with an HMAC key,
+// for the first record whose HMAC does not match, which means records were changed, removed or reordered. Without a
+// key the HMACs are not checked
+func VerifyAuditLog(filename string, key []byte) (int, error) {
+	files, err := auditFiles(filename)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	var seq uint64
+	var mac string
+	for _, name := range files {
+		fh, err := os.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && name == filename {
+				break
+			}
+			return count, err
+		}
+		scanner := bufio.NewScanner(fh)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			var record auditRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				fh.Close()
+				return count, fmt.Errorf("%s:%d: unable to read audit record: %w", name, line, err)
+			}
+			if count > 0 && record.Seq != seq+1 {
+				fh.Close()
+				return count, fmt.Errorf("%s:%d: record %d follows record %d", name, line, record.Seq, seq)
+			}
+			if len(key) > 0 {
+				if expected := auditMAC(key, mac, record.Seq, record.Entry); !hmac.Equal([]byte(expected), []byte(record.HMAC)) {
+					fh.Close()
+					return count, fmt.Errorf("%s:%d: HMAC of record %d does not match", name, line, record.Seq)
+				}
+			}
+			seq, mac = record.Seq, record.HMAC
+			count++
+		}
+		err = scanner.Err()
+		fh.Close()
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,80 @@
+package servicelogger
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ModeEnv is the environment variable that overrides the detection of NewAuto: "stdout" always logs JSON to stdout,
+// "file" always logs to the log file, and "auto" or an empty value detects a container
+const ModeEnv = "SERVICELOGGER_MODE"
+
+// containerMarkers are files that container runtimes create in the root of a container
+var containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+
+// NewAuto returns a Logger for the environment the service runs in, so that the same binary and configuration work
+// both on a host and in a container. In a container, or when SERVICELOGGER_MODE is "stdout", the Logger writes one
+// JSON object per line to stdout and leaves collecting, rotating and keeping the logs to the container runtime: the
+// file arguments are ignored and no file is created. Everywhere else NewAuto is New with the same arguments
+func NewAuto(prefix string, filename string, minloglevel LogLevel, rotate bool, rotatesize string, keep int) (l Logger, err error) {
+	stdout, err := stdoutMode()
+	if err != nil {
+		return l, err
+	}
+	if !stdout {
+		return New(prefix, filename, minloglevel, rotate, rotatesize, keep)
+	}
+	return newStdout(prefix, minloglevel), nil
+}
+
+// newStdout returns a Logger that writes entries as JSON to stdout, without a log file
+func newStdout(prefix string, minloglevel LogLevel) Logger {
+	l := Logger{loggerState: &loggerState{
+		prefix:      prefix,
+		MinLoglevel: minloglevel,
+		sanitize:    SM_ESCAPE,
+		format:      LF_JSON,
+		stats:       &loggerStats{},
+	}}
+	l.base = log.New(os.Stdout, "", 0)
+	return l
+}
+
+// stdoutMode reports whether NewAuto logs to stdout, from SERVICELOGGER_MODE or else from the detection of a container
+func stdoutMode() (bool, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv(ModeEnv))); mode {
+	case "stdout":
+		return true, nil
+	case "file":
+		return false, nil
+	case "", "auto":
+		return InContainer(), nil
+	default:
+		return false, fmt.Errorf("unknown %s %q, allowed values: 'auto', 'file', 'stdout'", ModeEnv, mode)
+	}
+}
+
+// InContainer reports whether the process runs in a container: under Kubernetes, in a Docker or Podman container, or
+// in a container whose cgroup names a container runtime
+func InContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range containerMarkers {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+		if strings.Contains(string(cgroup), runtime) {
+			return true
+		}
+	}
+	return false
+}
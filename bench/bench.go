@@ -0,0 +1,138 @@
+// Package bench - drives a servicelogger Logger with generated load and reports what it achieved
+package bench
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+)
+
+// Config describes the load generated by Run
+type Config struct {
+	Rate        int                    // target entries per second over all workers, 0 logs as fast as possible
+	Duration    time.Duration          // how long to generate load
+	Workers     int                    // number of goroutines logging concurrently, defaults to 1
+	Level       servicelogger.LogLevel // level of the generated entries, defaults to LL_INFO. LL_PANIC and LL_FATAL are not allowed
+	MessageSize int                    // length of the generated messages in bytes, defaults to 100
+}
+
+// Result holds the measurements of a Run
+type Result struct {
+	Entries        uint64        // number of log calls made
+	Elapsed        time.Duration // wall clock time of the run
+	Throughput     float64       // achieved log calls per second
+	P50            time.Duration // median latency of a log call
+	P99            time.Duration // 99th percentile latency of a log call
+	Max            time.Duration // slowest log call
+	AllocsPerEntry float64       // heap allocations per log call
+	BytesPerEntry  float64       // heap bytes allocated per log call
+	Dropped        uint64        // entries the Logger reported as dropped during the run
+}
+
+// Run generates load on l as described by cfg and returns the measurements. The Logger should be configured exactly as
+// it will be in production, including its queue and buffer settings
+func Run(l *servicelogger.Logger, cfg Config) (Result, error) {
+	var res Result
+	if cfg.Duration <= 0 {
+		return res, fmt.Errorf("duration must be positive")
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.Level == 0 {
+		cfg.Level = servicelogger.LL_INFO
+	}
+	if cfg.Level.Severity() >= servicelogger.LL_PANIC.Severity() {
+		return res, fmt.Errorf("cannot benchmark at %s level", servicelogger.LogLevelToString(cfg.Level))
+	}
+	if cfg.MessageSize <= 0 {
+		cfg.MessageSize = 100
+	}
+	message := strings.Repeat("x", cfg.MessageSize)
+	var interval time.Duration
+	if cfg.Rate > 0 {
+		interval = time.Duration(int64(time.Second) * int64(cfg.Workers) / int64(cfg.Rate))
+	}
+
+	latencies := make([][]time.Duration, cfg.Workers)
+	statsBefore := l.Stats()
+	var memBefore, memAfter runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memBefore)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(cfg.Duration)
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			lat := make([]time.Duration, 0, 1024)
+			next := time.Now()
+			for {
+				now := time.Now()
+				if !now.Before(deadline) {
+					break
+				}
+				if interval > 0 {
+					if now.Before(next) {
+						time.Sleep(next.Sub(now))
+					}
+					next = next.Add(interval)
+				}
+				callStart := time.Now()
+				logAt(l, cfg.Level, message)
+				lat = append(lat, time.Since(callStart))
+			}
+			latencies[w] = lat
+		}(w)
+	}
+	wg.Wait()
+	res.Elapsed = time.Since(start)
+	runtime.ReadMemStats(&memAfter)
+	statsAfter := l.Stats()
+
+	var all []time.Duration
+	for _, lat := range latencies {
+		all = append(all, lat...)
+	}
+	res.Entries = uint64(len(all))
+	if res.Entries == 0 {
+		return res, nil
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	res.Throughput = float64(res.Entries) / res.Elapsed.Seconds()
+	res.P50 = all[len(all)/2]
+	res.P99 = all[(len(all)*99)/100]
+	res.Max = all[len(all)-1]
+	res.AllocsPerEntry = float64(memAfter.Mallocs-memBefore.Mallocs) / float64(res.Entries)
+	res.BytesPerEntry = float64(memAfter.TotalAlloc-memBefore.TotalAlloc) / float64(res.Entries)
+	res.Dropped = statsAfter.Dropped - statsBefore.Dropped
+	return res, nil
+}
+
+func logAt(l *servicelogger.Logger, level servicelogger.LogLevel, message string) {
+	switch level {
+	case servicelogger.LL_TRACE:
+		l.LogTrace("Run", "bench", message)
+	case servicelogger.LL_DEBUG:
+		l.LogDebug("Run", "bench", message)
+	case servicelogger.LL_WARN:
+		l.LogWarn("Run", "bench", message)
+	case servicelogger.LL_ERROR:
+		l.LogError("Run", "bench", message)
+	default:
+		l.LogInfo("Run", "bench", message)
+	}
+}
+
+// String returns a one line summary of the Result
+func (r Result) String() string {
+	return fmt.Sprintf("%d entries in %s (%.0f/s), latency p50 %s p99 %s max %s, %.1f allocs/entry, %.0f B/entry, %d dropped",
+		r.Entries, r.Elapsed.Round(time.Millisecond), r.Throughput, r.P50, r.P99, r.Max, r.AllocsPerEntry, r.BytesPerEntry, r.Dropped)
+}
@@ -0,0 +1,270 @@
+package servicelogger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// binaryMarker starts every LF_BINARY frame. It is a control character, so it never starts a line in one of the text
+// formats and readers can tell frames and lines apart
+const binaryMarker = 0x1e
+
+// Field value types of LF_BINARY frames
+const (
+	binaryString = 's'
+	binaryInt    = 'i'
+	binaryFloat  = 'f'
+	binaryBool   = 'b'
+)
+
+// formatBinary returns the LF_BINARY frame for an entry: binaryMarker, the uvarint length of the payload, the payload
+// and a newline. The payload holds the time in Unix nanoseconds as a varint, the level as a byte, the prefix, source,
+// function and message, and the number of fields followed by each key, value type and value. Strings are prefixed by
+// their uvarint length. The trailing newline keeps log.Logger from appending one of its own
+func formatBinary(e *Entry) string {
+	payload := make([]byte, 0, 64+len(e.Message))
+	payload = binary.AppendVarint(payload, e.Time.UnixNano())
+	payload = append(payload, byte(e.Level))
+	payload = appendBinaryString(payload, e.Prefix)
+	payload = appendBinaryString(payload, e.Source)
+	payload = appendBinaryString(payload, e.Function)
+	payload = appendBinaryString(payload, e.Message)
+	payload = binary.AppendUvarint(payload, uint64(len(e.Fields)))
+	for key, value := range e.Fields {
+		payload = appendBinaryString(payload, key)
+		switch v := value.(type) {
+		case int:
+			payload = binary.AppendVarint(append(payload, binaryInt), int64(v))
+		case int64:
+			payload = binary.AppendVarint(append(payload, binaryInt), v)
+		case int32:
+			payload = binary.AppendVarint(append(payload, binaryInt), int64(v))
+		case float64:
+			payload = binary.LittleEndian.AppendUint64(append(payload, binaryFloat), math.Float64bits(v))
+		case bool:
+			b := byte(0)
+			if v {
+				b = 1
+			}
+			payload = append(payload, binaryBool, b)
+		default:
+			payload = appendBinaryString(append(payload, binaryString), fieldString(value))
+		}
+	}
+	frame := make([]byte, 0, len(payload)+binary.MaxVarintLen64+2)
+	frame = append(frame, binaryMarker)
+	frame = binary.AppendUvarint(frame, uint64(len(payload)))
+	frame = append(frame, payload...)
+	frame = append(frame, '\n')
+	return string(frame)
+}
+
+// fieldString returns the text form of a field value, as used by formats that do not keep its type
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func appendBinaryString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readBinaryFrame reads an LF_BINARY frame from br, which must be positioned at its binaryMarker
+func readBinaryFrame(br *bufio.Reader) (Entry, error) {
+	e, _, err := readBinaryFrameSize(br)
+	return e, err
+}
+
+// readBinaryFrameSize reads an LF_BINARY frame like readBinaryFrame, and also returns the number of bytes it took
+func readBinaryFrameSize(br *bufio.Reader) (Entry, int64, error) {
+	if marker, err := br.ReadByte(); err != nil || marker != binaryMarker {
+		return Entry{}, 0, errors.New("not a binary frame")
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return Entry{}, 0, err
+	}
+	if size > 64*1024*1024 {
+		return Entry{}, 0, errors.New("binary frame too large")
+	}
+	frame := make([]byte, size+1)
+	if _, err = io.ReadFull(br, frame); err != nil {
+		return Entry{}, 0, io.ErrUnexpectedEOF
+	}
+	if frame[size] != '\n' {
+		return Entry{}, 0, errors.New("corrupt binary frame")
+	}
+	e, err := decodeBinaryPayload(frame[:size])
+	var head [binary.MaxVarintLen64]byte
+	return e, int64(1+binary.PutUvarint(head[:], size)) + int64(size) + 1, err
+}
+
+// binaryDecoder reads the values of a payload, remembering the first error
+type binaryDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *binaryDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *binaryDecoder) string() string {
+	size := d.uvarint()
+	if uint64(len(d.b)) < size {
+		d.fail()
+		return ""
+	}
+	s := string(d.b[:size])
+	d.b = d.b[size:]
+	return s
+}
+
+func (d *binaryDecoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("corrupt binary frame")
+	}
+	d.b = nil
+}
+
+func decodeBinaryPayload(payload []byte) (Entry, error) {
+	d := &binaryDecoder{b: payload}
+	e := Entry{
+		Time:     time.Unix(0, d.varint()),
+		Level:    LogLevel(d.byte()),
+		Prefix:   d.string(),
+		Source:   d.string(),
+		Function: d.string(),
+		Message:  d.string(),
+	}
+	count := d.uvarint()
+	for n := uint64(0); n < count && d.err == nil; n++ {
+		key := d.string()
+		switch d.byte() {
+		case binaryString:
+			e.SetField(key, d.string())
+		case binaryInt:
+			e.SetField(key, d.varint())
+		case binaryFloat:
+			if len(d.b) < 8 {
+				d.fail()
+				break
+			}
+			e.SetField(key, math.Float64frombits(binary.LittleEndian.Uint64(d.b)))
+			d.b = d.b[8:]
+		case binaryBool:
+			e.SetField(key, d.byte() == 1)
+		default:
+			d.fail()
+		}
+	}
+	return e, d.err
+}
+
+// EntryReader reads entries one at a time from a stream in any of the formats a Logger writes, including LF_BINARY.
+// A stream may mix formats, e.g. when a service switched formats without rotating its log file
+type EntryReader struct {
+	br           *bufio.Reader
+	prefix       string
+	layout       *TextLayout    // layout of LF_TEXT lines, or nil for the default
+	continuation string         // marker of the continuation lines of multi-line messages
+	location     *time.Location // time zone of LF_TEXT timestamps, or nil for time.Local
+}
+
+// NewEntryReader returns an EntryReader for in. prefix is passed on to ParseLine for lines in LF_TEXT format.
+// Continuation lines with the default marker of SetMultiline are joined to the message of their entry
+func NewEntryReader(in io.Reader, prefix string) *EntryReader {
+	return &EntryReader{br: bufio.NewReaderSize(in, 64*1024), prefix: prefix, continuation: defaultContinuation}
+}
+
+// Next returns the next entry, skipping lines that are not entries. It returns io.EOF at the end of the stream
+func (r *EntryReader) Next() (Entry, error) {
+	for {
+		e, raw, err := r.next()
+		if err != nil || raw == "" {
+			return e, err
+		}
+	}
+}
+
+// next returns the next entry, or the raw line when the next line is not an entry
+func (r *EntryReader) next() (Entry, string, error) {
+	first, err := r.br.Peek(1)
+	if err != nil {
+		return Entry{}, "", err
+	}
+	if first[0] == binaryMarker {
+		e, err := readBinaryFrame(r.br)
+		return e, "", err
+	}
+	line, err := r.br.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return Entry{}, "", err
+	}
+	if line[0] != '{' && !isLogfmtLine(line) {
+		line = r.joinContinuation(line)
+	}
+	e, perr := parseLine(line, r.prefix, r.layout, r.location)
+	if perr != nil {
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		return Entry{}, line, nil
+	}
+	return e, "", nil
+}
+
+// joinContinuation appends the continuation lines that follow line, without their marker, as further lines of its
+// message
+func (r *EntryReader) joinContinuation(line string) string {
+	for {
+		next, err := r.br.Peek(len(r.continuation))
+		if err != nil || string(next) != r.continuation {
+			return line
+		}
+		continued, err := r.br.ReadString('\n')
+		if err != nil && continued == "" {
+			return line
+		}
+		line = strings.TrimRight(line, "\r\n") + "\n" + strings.TrimRight(continued[len(r.continuation):], "\r\n") + "\n"
+	}
+}
@@ -0,0 +1,84 @@
+package servicelogger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerConfig configures the circuit breaker of a sink. Zero members get their default
+type BreakerConfig struct {
+	Threshold int           // consecutive failures that open the breaker, 0 disables it
+	CoolDown  time.Duration // time the sink is skipped once the breaker opened, defaults to 30 seconds
+}
+
+// States of a circuit breaker
+const (
+	breakerClosed   = 0 // the sink is called for every entry
+	breakerOpen     = 1 // the sink is skipped until the cool-down has passed
+	breakerHalfOpen = 2 // a single trial entry is on its way to the sink
+)
+
+// breaker stops calls to a sink that keeps failing, so that a dead destination does not slow down every log call
+type breaker struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	state    int
+	failures int
+	opened   time.Time
+}
+
+func newBreaker(config BreakerConfig) *breaker {
+	if config.Threshold <= 0 {
+		return nil
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = 30 * time.Second
+	}
+	return &breaker{config: config}
+}
+
+// allow reports whether the sink may be called now. Once the cool-down has passed, a single trial call is allowed
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.opened) < b.config.CoolDown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// result records the outcome of a call to the sink. It returns the level and text of a diagnostic when the state of the
+// breaker changed, and an empty text otherwise
+func (b *breaker) result(err error, now time.Time) (LogLevel, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		if b.state == breakerClosed {
+			return 0, ""
+		}
+		b.state = breakerClosed
+		return LL_INFO, "trial entry delivered, resuming"
+	}
+	b.failures++
+	switch {
+	case b.state == breakerHalfOpen:
+		b.state = breakerOpen
+		b.opened = now
+		return LL_WARN, fmt.Sprintf("trial entry failed, pausing for another %s: %s", b.config.CoolDown, err.Error())
+	case b.state == breakerClosed && b.failures >= b.config.Threshold:
+		b.state = breakerOpen
+		b.opened = now
+		return LL_WARN, fmt.Sprintf("failed %d times in a row, pausing for %s: %s", b.failures, b.config.CoolDown, err.Error())
+	}
+	return 0, ""
+}
@@ -0,0 +1,120 @@
+package servicelogger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// WriteBufferConfig configures the buffer of EnableWriteBuffer. Zero members get their default
+type WriteBufferConfig struct {
+	Size          int           // size of the buffer in bytes, defaults to 64 KiB
+	FlushInterval time.Duration // interval of the background flush, defaults to one second
+}
+
+// writeBuffer collects lines in memory before they are written to the log file
+type writeBuffer struct {
+	mu       sync.Mutex
+	w        *bufio.Writer
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// EnableWriteBuffer collects lines in a buffer and writes them to the log file when it is full, which saves system
+// calls for services that log a lot. A background ticker flushes the buffer at every flush interval, even when no
+// entries are logged, so that the last entries of a quiet service do not stay in memory. Flush, Close, Shutdown,
+// LogFatal and rotation flush the buffer as well; entries still in the buffer when the process crashes are lost.
+// Shared log files and FIFOs cannot be buffered. EnableWriteBuffer must be called before the Logger is used from
+// multiple goroutines
+func (l *Logger) EnableWriteBuffer(config WriteBufferConfig) error {
+	if l.buffer != nil {
+		return errors.New("write buffer is already enabled")
+	}
+	if l.memory != nil || l.fifo != nil || l.filehandle == nil {
+		return errors.New("only a log file can be buffered")
+	}
+	if l.shared != nil {
+		return errors.New("a shared log file cannot be buffered")
+	}
+	if config.Size <= 0 {
+		config.Size = 64 * 1024
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	b := &writeBuffer{w: bufio.NewWriterSize(l.filehandle, config.Size), stop: make(chan struct{})}
+	l.buffer = b
+	base, err := l.newBase()
+	l.base = base
+	l.drainers = append(l.drainers, b)
+	go b.autoFlush(config.FlushInterval)
+	return err
+}
+
+func (b *writeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Write(p)
+}
+
+// flush writes the buffered lines to the log file
+func (b *writeBuffer) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Flush()
+}
+
+// buffered returns the number of bytes in the buffer
+func (b *writeBuffer) buffered() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Buffered()
+}
+
+// retarget makes the buffer write to w from now on, e.g. after rotation. The buffer must have been flushed
+func (b *writeBuffer) retarget(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.w.Reset(w)
+}
+
+// autoFlush flushes the buffer at every interval until the Logger is shut down. Write errors are not reported here,
+// because the buffer keeps them and fails the next write, which counts the entry as dropped
+func (b *writeBuffer) autoFlush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			_ = b.flush()
+		}
+	}
+}
+
+// Drain flushes the buffer and stops the ticker
+func (b *writeBuffer) Drain(ctx context.Context) (int, error) {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+	return 0, b.flush()
+}
+
+// flushBuffer flushes the write buffer, if any
+func (l *Logger) flushBuffer() {
+	if l.buffer != nil {
+		_ = l.buffer.flush()
+	}
+}
+
+// output returns the writer lines for the log file are written to: the write buffer, or else the log file
+func (l *Logger) output() io.Writer {
+	if l.buffer != nil {
+		return l.buffer
+	}
+	return l.filehandle
+}
@@ -0,0 +1,87 @@
+package servicelogger
+
+import "fmt"
+
+// EntryBuilder builds an entry step by step, for call sites with many fields:
+//
+//	l.Error().Func("Handle").Source("http").Field("status", 500).Err(err).Msg("request failed")
+//
+// Nothing is logged until Msg or Msgf is called. An EntryBuilder must not be used after Msg or Msgf, nor from
+// multiple goroutines
+type EntryBuilder struct {
+	l     *Logger
+	entry Entry
+}
+
+// Level returns an EntryBuilder for an entry at level
+func (l *Logger) Level(level LogLevel) *EntryBuilder {
+	return l.getBuilder(level)
+}
+
+// Trace returns an EntryBuilder for an entry at TRACE level
+func (l *Logger) Trace() *EntryBuilder {
+	return l.Level(LL_TRACE)
+}
+
+// Debug returns an EntryBuilder for an entry at DEBUG level
+func (l *Logger) Debug() *EntryBuilder {
+	return l.Level(LL_DEBUG)
+}
+
+// Info returns an EntryBuilder for an entry at INFO level
+func (l *Logger) Info() *EntryBuilder {
+	return l.Level(LL_INFO)
+}
+
+// Warn returns an EntryBuilder for an entry at WARN level
+func (l *Logger) Warn() *EntryBuilder {
+	return l.Level(LL_WARN)
+}
+
+// Error returns an EntryBuilder for an entry at ERROR level
+func (l *Logger) Error() *EntryBuilder {
+	return l.Level(LL_ERROR)
+}
+
+// Func sets the function of the entry
+func (b *EntryBuilder) Func(function string) *EntryBuilder {
+	b.entry.Function = function
+	return b
+}
+
+// Source sets the source of the entry
+func (b *EntryBuilder) Source(source string) *EntryBuilder {
+	b.entry.Source = source
+	return b
+}
+
+// Field adds a field to the entry, replacing an earlier field with the same key
+func (b *EntryBuilder) Field(key string, value interface{}) *EntryBuilder {
+	b.entry.SetField(key, value)
+	return b
+}
+
+// Fields adds fields to the entry, replacing earlier fields with the same keys
+func (b *EntryBuilder) Fields(fields map[string]interface{}) *EntryBuilder {
+	for key, value := range fields {
+		b.entry.SetField(key, value)
+	}
+	return b
+}
+
+// Err adds the fields of err as LogErrorCause does, or nothing when err is nil
+func (b *EntryBuilder) Err(err error) *EntryBuilder {
+	return b.Fields(errorFields(err))
+}
+
+// Msg logs the entry with text as its message
+func (b *EntryBuilder) Msg(text string) {
+	b.l.log(b.entry.Level, b.entry.Function, b.entry.Source, text, b.entry.Fields)
+	b.l.putBuilder(b)
+}
+
+// Msgf logs the entry with a message formatted by fmt.Sprintf
+func (b *EntryBuilder) Msgf(format string, args ...interface{}) {
+	b.l.log(b.entry.Level, b.entry.Function, b.entry.Source, fmt.Sprintf(format, args...), b.entry.Fields)
+	b.l.putBuilder(b)
+}
@@ -0,0 +1,104 @@
+package servicelogger
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BurstConfig configures the burst buffer of EnableBurstBuffer. Zero members get their default
+type BurstConfig struct {
+	Size       int           // entries kept per facility, defaults to 50
+	Window     time.Duration // age after which kept entries are discarded, defaults to one minute
+	Trigger    LogLevel      // lowest level that writes the kept entries of its facility, defaults to LL_ERROR
+	Facilities []string      // facility prefixes that share a buffer, every facility has its own buffer when empty
+}
+
+// burstBuffer keeps the recent entries that did not pass the filters, per facility
+type burstBuffer struct {
+	mu      sync.Mutex
+	config  BurstConfig
+	buffers map[string][]*Entry
+}
+
+// EnableBurstBuffer keeps the most recent entries that are below the level of their facility, such as TRACE and DEBUG
+// entries, in memory instead of discarding them. When an entry at or above the trigger level is logged, the entries
+// kept for its facility in the last window are written first, marked with the field burst=true, so that an error comes
+// with the detailed context that normal filtering discards. Without facility prefixes every facility has a buffer of
+// its own; with them, all facilities below a prefix share one, e.g. "delta.db" for every function of the db source.
+// Entries of other facilities are then not kept. EnableBurstBuffer must be called before the Logger is used from
+// multiple goroutines
+func (l *Logger) EnableBurstBuffer(config BurstConfig) error {
+	if config.Size == 0 {
+		config.Size = 50
+	}
+	if config.Size < 0 {
+		return errors.New("burst buffer size too low (>=1)")
+	}
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.Trigger == 0 {
+		config.Trigger = LL_ERROR
+	}
+	l.burst = &burstBuffer{config: config, buffers: make(map[string][]*Entry)}
+	return nil
+}
+
+// group returns the key of the buffer of facility, or false when entries of facility are not kept
+func (b *burstBuffer) group(facility string) (string, bool) {
+	if len(b.config.Facilities) == 0 {
+		return facility, true
+	}
+	for _, prefix := range b.config.Facilities {
+		if facilityMatches([]string{prefix}, facility) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// keep adds e to the buffer of its facility, discarding the oldest entry of a full buffer
+func (b *burstBuffer) keep(facility string, e *Entry) {
+	key, ok := b.group(facility)
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := b.buffers[key]
+	if len(kept) >= b.config.Size {
+		copy(kept, kept[1:])
+		kept = kept[:len(kept)-1]
+	}
+	b.buffers[key] = append(kept, e)
+}
+
+// take removes the entries kept for facility and returns those logged within the window
+func (b *burstBuffer) take(facility string, now time.Time) []*Entry {
+	key, ok := b.group(facility)
+	if !ok {
+		return nil
+	}
+	b.mu.Lock()
+	kept := b.buffers[key]
+	delete(b.buffers, key)
+	b.mu.Unlock()
+	cutoff := now.Add(-b.config.Window)
+	for n, e := range kept {
+		if !e.Time.Before(cutoff) {
+			return kept[n:]
+		}
+	}
+	return nil
+}
+
+// flushBurst writes the entries kept for facility, oldest first, through the interceptors
+func (l *Logger) flushBurst(facility string) {
+	for _, e := range l.burst.take(facility, l.now()) {
+		e.SetField("burst", true)
+		if l.intercept(e) {
+			l.writeEntry(e)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package servicelogger
+
+import "time"
+
+// Clock tells the time to a Logger
+type Clock interface {
+	Now() time.Time
+}
+
+// SetClock makes the Logger take the time from clock instead of the system clock, e.g. a fake clock in tests or the
+// simulation time of a service that runs in one. The clock is used for the timestamps of entries, scheduled rotation
+// and retention, LogEvery, burst windows, circuit breakers and the rate limit of the Sentry forwarder. Background
+// tickers, timeouts and durations measured by the Logger keep using the system clock. A nil clock restores the system
+// clock. SetClock must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetClock(clock Clock) {
+	l.clock = clock
+}
+
+// now returns the time of the clock of the Logger
+func (l *Logger) now() time.Time {
+	if l.clock == nil {
+		return time.Now()
+	}
+	return l.clock.Now()
+}
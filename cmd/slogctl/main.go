@@ -0,0 +1,370 @@
+// Command slogctl - inspects log files written by servicelogger
+//
+// Usage:
+//
+//	slogctl cat [flags] file...        print entries, converting between the text, JSON, logfmt and binary formats
+//	slogctl query [flags] logfile      print entries of a log file and its rotated segments
+//	slogctl follow [flags] logfile     print new entries as they are written, across rotations
+//	slogctl decompress file.gz...      decompress rotated segments next to the compressed files
+//	slogctl verify [flags] auditlog    check the sequence numbers and HMAC chain of an audit log and its archives
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "cat":
+		err = cat(os.Args[2:])
+	case "query":
+		err = query(os.Args[2:])
+	case "follow":
+		err = follow(os.Args[2:])
+	case "decompress":
+		err = decompress(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "slogctl: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: slogctl cat|query|follow|decompress|verify [flags] file...")
+	os.Exit(2)
+}
+
+// filterFlags holds the flags shared by the commands that print entries
+type filterFlags struct {
+	level    string
+	facility string
+	since    string
+	until    string
+	format   string
+	prefix   string
+}
+
+func newFlagSet(name string, ff *filterFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&ff.level, "level", "", "lowest level printed, e.g. warn")
+	fs.StringVar(&ff.facility, "facility", "", "facility prefix, e.g. delta.db")
+	fs.StringVar(&ff.since, "since", "", "first moment printed, a duration ago such as 1h or an RFC3339 time")
+	fs.StringVar(&ff.until, "until", "", "first moment no longer printed, a duration ago or an RFC3339 time")
+	fs.StringVar(&ff.format, "format", "text", "output format, text, json, logfmt, binary, or clf or combined for access entries")
+	fs.StringVar(&ff.prefix, "prefix", "", "prefix of the logger that wrote the file, needed when it contains dots")
+	return fs
+}
+
+func (ff *filterFlags) query() (servicelogger.Query, servicelogger.LogFormat, error) {
+	var q servicelogger.Query
+	q.Facility = ff.facility
+	if label := strings.ToUpper(ff.level); label == "WARNING" {
+		q.MinLevel = servicelogger.LL_WARN
+	} else if label != "" {
+		q.MinLevel = servicelogger.StringToLogLevel(label)
+		if servicelogger.LogLevelToString(q.MinLevel) != label {
+			return q, 0, fmt.Errorf("unknown level %q", ff.level)
+		}
+	}
+	var err error
+	if q.Since, err = parseTime(ff.since); err != nil {
+		return q, 0, err
+	}
+	if q.Until, err = parseTime(ff.until); err != nil {
+		return q, 0, err
+	}
+	switch ff.format {
+	case "text":
+		return q, servicelogger.LF_TEXT, nil
+	case "json":
+		return q, servicelogger.LF_JSON, nil
+	case "logfmt":
+		return q, servicelogger.LF_LOGFMT, nil
+	case "binary":
+		return q, servicelogger.LF_BINARY, nil
+	case "clf":
+		return q, servicelogger.LF_CLF, nil
+	case "combined":
+		return q, servicelogger.LF_COMBINED, nil
+	default:
+		return q, 0, fmt.Errorf("unknown format %q", ff.format)
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// cat prints the entries of the given files, which may be gzip compressed
+func cat(args []string) error {
+	var ff filterFlags
+	fs := newFlagSet("cat", &ff)
+	_ = fs.Parse(args)
+	q, format, err := ff.query()
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, name := range fs.Args() {
+		if err := catFile(out, name, ff.prefix, q, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openLog opens a log file for reading, decompressing it when its name ends in .gz
+func openLog(name string) (io.ReadCloser, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return fh, nil
+	}
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, fh}, nil
+}
+
+func catFile(out io.Writer, name string, prefix string, q servicelogger.Query, format servicelogger.LogFormat) error {
+	in, err := openLog(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if q == (servicelogger.Query{}) {
+		_, err = servicelogger.Convert(in, out, format, prefix)
+		return err
+	}
+	r := servicelogger.NewEntryReader(in, prefix)
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if q.Matches(&e) {
+			io.WriteString(out, servicelogger.FormatEntry(&e, format))
+		}
+	}
+}
+
+// printLine prints a line that matches q in format. Lines that are not entries are printed as they are, unless a
+// filter is active
+func printLine(out io.Writer, line string, prefix string, q servicelogger.Query, format servicelogger.LogFormat) {
+	e, err := servicelogger.ParseLine(line, prefix)
+	if err != nil {
+		if q == (servicelogger.Query{}) {
+			fmt.Fprintln(out, line)
+		}
+		return
+	}
+	if q.Matches(&e) {
+		io.WriteString(out, servicelogger.FormatEntry(&e, format))
+	}
+}
+
+// query prints the entries of a log file and its rotated segments, oldest first
+func query(args []string) error {
+	var ff filterFlags
+	fs := newFlagSet("query", &ff)
+	limit := fs.Int("limit", 0, "print only the most recent entries")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("query needs exactly one log file")
+	}
+	q, format, err := ff.query()
+	if err != nil {
+		return err
+	}
+	q.Limit = *limit
+	r := servicelogger.NewLogReader(fs.Arg(0))
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if q.Limit > 0 {
+		entries, err := r.Query(q)
+		for _, e := range entries {
+			e := e
+			io.WriteString(out, servicelogger.FormatEntry(&e, format))
+		}
+		return err
+	}
+	return r.Scan(q, func(e servicelogger.Entry) bool {
+		io.WriteString(out, servicelogger.FormatEntry(&e, format))
+		return true
+	})
+}
+
+// follow prints the entries appended to a log file, reopening it when it is rotated or truncated
+func follow(args []string) error {
+	var ff filterFlags
+	fs := newFlagSet("follow", &ff)
+	interval := fs.Duration("interval", 250*time.Millisecond, "polling interval")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("follow needs exactly one log file")
+	}
+	q, format, err := ff.query()
+	if err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+	fh, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(fh)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			printLine(os.Stdout, partial+line, ff.prefix, q, format)
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		partial += line
+		time.Sleep(*interval)
+		current, err := os.Stat(name)
+		if err != nil {
+			// between the rename and the creation of the new file
+			continue
+		}
+		open, err := fh.Stat()
+		if err != nil {
+			return err
+		}
+		offset, _ := fh.Seek(0, io.SeekCurrent)
+		if os.SameFile(open, current) {
+			if current.Size() >= offset {
+				continue
+			}
+			// truncated: start over
+			if _, err = fh.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			reader.Reset(fh)
+			partial = ""
+			continue
+		}
+		// rotated: print what was written to the old file before the rotation, then continue with the new one
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				printLine(os.Stdout, partial+line, ff.prefix, q, format)
+				partial = ""
+			}
+			if err != nil {
+				break
+			}
+		}
+		fh.Close()
+		if fh, err = os.Open(name); err != nil {
+			return err
+		}
+		reader.Reset(fh)
+		partial = ""
+	}
+}
+
+// decompress writes the contents of every file.gz to file
+func decompress(args []string) error {
+	if len(args) == 0 {
+		return errors.New("decompress needs at least one file")
+	}
+	for _, name := range args {
+		if !strings.HasSuffix(name, ".gz") {
+			return fmt.Errorf("%s: not a .gz file", name)
+		}
+		if err := decompressFile(name, strings.TrimSuffix(name, ".gz")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decompressFile(name string, target string) error {
+	fh, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err.Error())
+	}
+	defer gz.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, gz); err != nil {
+		out.Close()
+		os.Remove(target)
+		return fmt.Errorf("%s: %s", name, err.Error())
+	}
+	return out.Close()
+}
+
+// verify checks an audit log and its archives, reading the HMAC key from a file
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "file holding the HMAC key, the HMACs are not checked without it")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("verify needs exactly one audit log")
+	}
+	var key []byte
+	if *keyFile != "" {
+		var err error
+		if key, err = os.ReadFile(*keyFile); err != nil {
+			return err
+		}
+	}
+	count, err := servicelogger.VerifyAuditLog(fs.Arg(0), key)
+	if err != nil {
+		return fmt.Errorf("verification failed after %d records: %s", count, err.Error())
+	}
+	fmt.Printf("%d records verified\n", count)
+	return nil
+}
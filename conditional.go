@@ -0,0 +1,25 @@
+package servicelogger
+
+// LogIfError logs err at ERROR level when it is not nil, with the message of err as text and the fields of
+// LogErrorCause. It reports whether err was not nil, also when the entry was filtered out, so that the result can
+// decide the error handling of the caller:
+//
+//	if l.LogIfError("Load", "config", err) {
+//		return err
+//	}
+func (l *Logger) LogIfError(function string, source string, err error) bool {
+	if err == nil {
+		return false
+	}
+	l.log(LL_ERROR, function, source, err.Error(), errorFields(err))
+	return true
+}
+
+// LogIf logs a message at the provided level when cond is set. Like LogIfError it reports whether cond was set, also
+// when the entry was filtered out. Unlike LogFatal it never exits the application
+func (l *Logger) LogIf(cond bool, level LogLevel, function string, source string, text string) bool {
+	if cond {
+		l.log(level, function, source, text, nil)
+	}
+	return cond
+}
@@ -0,0 +1,41 @@
+package servicelogger
+
+import "context"
+
+type contextKey int
+
+const (
+	correlationIDKey contextKey = iota
+	traceparentKey
+)
+
+// WithContext returns a child logger that attaches ctx to every entry it logs, so that interceptors can take request
+// scoped values such as trace IDs from it. A correlation ID carried by ctx is added to every entry as the field
+// correlation_id, and the trace and parent span of a W3C traceparent as the fields trace_id and span_id. The child
+// shares the log file, filters and settings of l
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	child := *l
+	child.ctx = ctx
+	return &child
+}
+
+// WithCorrelationID returns a child logger that adds id as the field correlation_id to every entry it logs. The ID is
+// carried in the context of the child, so it reaches interceptors and hooks through Entry.Context as well
+func (l *Logger) WithCorrelationID(id string) *Logger {
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return l.WithContext(ContextWithCorrelationID(ctx, id))
+}
+
+// ContextWithCorrelationID returns a copy of ctx carrying a correlation ID
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,32 @@
+package servicelogger
+
+import (
+	"bufio"
+	"io"
+)
+
+// Convert reads entries in any of the formats a Logger writes from in, and writes them to out in format, e.g. to
+// migrate historical log files when a service switches to JSON logging. Lines that are not entries are copied
+// unchanged, so nothing is lost. prefix is passed on to ParseLine. Convert returns the number of entries converted
+func Convert(in io.Reader, out io.Writer, format LogFormat, prefix string) (int, error) {
+	r := NewEntryReader(in, prefix)
+	w := bufio.NewWriter(out)
+	converted := 0
+	for {
+		e, raw, err := r.next()
+		if err == io.EOF {
+			return converted, w.Flush()
+		}
+		if err != nil {
+			w.Flush()
+			return converted, err
+		}
+		if raw == "" {
+			raw = FormatEntry(&e, format)
+			converted++
+		}
+		if _, err = w.WriteString(raw); err != nil {
+			return converted, err
+		}
+	}
+}
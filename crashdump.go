@@ -0,0 +1,98 @@
+package servicelogger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// CrashDumpTarget selects where EnableCrashDump writes the goroutine dump
+type CrashDumpTarget int
+
+const (
+	CT_LOG  CrashDumpTarget = 1 // as the field goroutines of the FATAL entry
+	CT_FILE CrashDumpTarget = 2 // to a .crash file, named in the field crash_file of the FATAL entry
+)
+
+// CrashDumpConfig configures the goroutine dump of EnableCrashDump. Zero members get their default
+type CrashDumpConfig struct {
+	Target CrashDumpTarget // where the dump is written, defaults to CT_LOG
+	Dir    string          // directory of the .crash files, defaults to the directory of the log file or else the temp dir
+}
+
+// crashDump captures the goroutines for FATAL entries
+type crashDump struct {
+	config CrashDumpConfig
+}
+
+// EnableCrashDump captures the stacks of all goroutines with every FATAL entry, before LogFatal exits, so that a
+// post-mortem shows what every goroutine was doing at the moment of death, e.g. which of them held up a hung service.
+// With CT_LOG the dump is added to the FATAL entry as the field goroutines, without hiding any frames. With CT_FILE it
+// is written to a file named after the log file with a .crash suffix, a timestamp and the process ID, which keeps
+// large dumps out of the log and the sinks; the entry names the file in the field crash_file, or the reason it could
+// not be written in the field crash_error. EnableCrashDump must be called before the Logger is used from multiple
+// goroutines
+func (l *Logger) EnableCrashDump(config CrashDumpConfig) {
+	if config.Target == 0 {
+		config.Target = CT_LOG
+	}
+	if config.Dir == "" {
+		config.Dir = os.TempDir()
+		if l.filename != "" {
+			config.Dir = filepath.Dir(l.filename)
+		}
+	}
+	l.crashDump = &crashDump{config: config}
+}
+
+// attach captures the goroutines and adds them, or the name of the file they were written to, to the FATAL entry e
+func (c *crashDump) attach(l *Logger, e *Entry) {
+	dump := goroutineStacks()
+	if c.config.Target == CT_LOG {
+		all := &stackTraces{config: StackTraceConfig{Hide: []string{}}}
+		e.SetField("goroutines", all.parse(dump))
+		return
+	}
+	filename, err := c.write(l, dump, fmt.Sprintf("[%s] %s.%s %s", e.Function, e.Prefix, e.Source, e.Message))
+	if err != nil {
+		e.SetField("crash_error", err.Error())
+		return
+	}
+	e.SetField("crash_file", filename)
+}
+
+// write writes the dump to a .crash file headed by the FATAL message, and returns its name
+func (c *crashDump) write(l *Logger, dump []byte, message string) (string, error) {
+	now := l.now()
+	base := l.prefix
+	if l.filename != "" {
+		base = filepath.Base(l.filename)
+	}
+	if base == "" {
+		base = "servicelogger"
+	}
+	filename := filepath.Join(c.config.Dir, fmt.Sprintf("%s.crash.%s.%d", base, now.UTC().Format(flightRecordLayout), os.Getpid()))
+	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(fh)
+	fmt.Fprintf(w, "FATAL: %s\n", message)
+	fmt.Fprintf(w, "pid %d, %s, %s\n\n", os.Getpid(), runtime.Version(), now.Format(time.RFC3339Nano))
+	w.Write(dump)
+	err = w.Flush()
+	if serr := fh.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,103 @@
+package servicelogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deadLetterRecord is a line of a dead-letter file: the entry in LF_JSON format, why it could not be delivered and when
+type deadLetterRecord struct {
+	Failed time.Time       `json:"failed"`
+	Reason string          `json:"reason"`
+	Entry  json.RawMessage `json:"entry"`
+}
+
+// deadLetter appends the entries a sink could not deliver to a file, one JSON object per line
+type deadLetter struct {
+	mu   sync.Mutex
+	name string
+}
+
+// write appends e to the dead-letter file with the reason it could not be delivered
+func (d *deadLetter) write(e *Entry, reason string) error {
+	line, err := json.Marshal(deadLetterRecord{
+		Failed: time.Now(),
+		Reason: reason,
+		Entry:  json.RawMessage(strings.TrimSuffix(formatJSON(e, nil), "\n")),
+	})
+	if err != nil {
+		return err
+	}
+	return d.append(append(line, '\n'))
+}
+
+func (d *deadLetter) append(p []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fh, err := os.OpenFile(d.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Write(p); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// RedriveDeadLetters hands the entries of a dead-letter file written by a RetrySink to s again, e.g. after the
+// destination was fixed. The file is taken over before the entries are delivered, so the sink that wrote it may keep
+// adding to it. Entries that fail again, and lines that cannot be read, are written back to the file. RedriveDeadLetters
+// returns the number of entries delivered
+func RedriveDeadLetters(filename string, s Sink) (int, error) {
+	taken := filename + ".redrive"
+	if err := os.Rename(filename, taken); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	fh, err := os.Open(taken)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+	d := &deadLetter{name: filename}
+	delivered := 0
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record deadLetterRecord
+		err := json.Unmarshal(line, &record)
+		var e Entry
+		if err == nil {
+			e, err = parseJSONLine(string(record.Entry))
+		}
+		if err != nil {
+			if err = d.append(append(line, '\n')); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if werr := s.WriteEntry(&e); werr != nil {
+			if err = d.write(&e, werr.Error()); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		delivered++
+	}
+	if err := scanner.Err(); err != nil {
+		return delivered, err
+	}
+	return delivered, os.Remove(taken)
+}
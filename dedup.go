@@ -0,0 +1,142 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupKey selects what makes two entries repeats of each other
+type DedupKey int
+
+const (
+	DK_FACILITY_MESSAGE DedupKey = 1 // the same message from the same facility
+	DK_MESSAGE          DedupKey = 2 // the same message from any facility
+	DK_CUSTOM           DedupKey = 3 // the same key returned by DedupConfig.KeyFunc
+)
+
+// DedupConfig configures the deduplication of EnableDedup. Zero members get their default
+type DedupConfig struct {
+	Window  time.Duration         // repeats this soon after the entry they repeat are suppressed, defaults to 10s
+	Key     DedupKey              // what makes entries repeats, defaults to DK_FACILITY_MESSAGE
+	KeyFunc func(e *Entry) string // key of an entry for DK_CUSTOM; entries with an empty key are never suppressed
+	// Windows overrides Window for the entries of a facility and the facilities below it, the most specific facility
+	// winning. A window of 0 never suppresses the entries, e.g. for intentional repeats such as heartbeat summaries
+	Windows map[Facility]time.Duration
+	MaxKeys int // number of keys remembered, defaults to 10000. When full, keys whose window has passed are forgotten
+}
+
+// deduplicator suppresses repeated entries
+type deduplicator struct {
+	config DedupConfig
+	mu     sync.Mutex
+	keys   map[string]*dedupState
+}
+
+// dedupState is the state of a key: when its window started, how long it lasts and how many repeats were suppressed
+type dedupState struct {
+	start      time.Time
+	window     time.Duration
+	suppressed int
+}
+
+// EnableDedup suppresses entries that repeat an entry logged less than the window before, e.g. the same error logged
+// in a tight retry loop. The first entry after the window has passed is logged again, with a repeated field holding
+// the number of entries suppressed since the previous one; repeats that are not followed by another one are only
+// counted by Stats as Deduplicated. Entries of audit facilities are never suppressed. EnableDedup must be called before
+// the Logger is used from multiple goroutines
+func (l *Logger) EnableDedup(config DedupConfig) error {
+	if l.dedup != nil {
+		return errors.New("deduplication is already enabled")
+	}
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.Key == 0 {
+		config.Key = DK_FACILITY_MESSAGE
+	}
+	if config.Key < DK_FACILITY_MESSAGE || config.Key > DK_CUSTOM {
+		return fmt.Errorf("unknown dedup key %d", config.Key)
+	}
+	if config.Key == DK_CUSTOM && config.KeyFunc == nil {
+		return errors.New("DK_CUSTOM requires a KeyFunc")
+	}
+	for facility, window := range config.Windows {
+		if window < 0 {
+			return fmt.Errorf("dedup window of %s too low (>=0)", facility)
+		}
+	}
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = 10000
+	}
+	l.dedup = &deduplicator{config: config, keys: make(map[string]*dedupState)}
+	return nil
+}
+
+// admit reports whether e is logged or suppressed as a repeat. An entry logged after suppressed repeats gets the
+// repeated field
+func (d *deduplicator) admit(e *Entry) bool {
+	window := d.window(Facility(e.Facility()))
+	if window == 0 {
+		return true
+	}
+	key := d.key(e)
+	if key == "" {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.keys[key]
+	if ok && e.Time.Sub(s.start) < s.window {
+		s.suppressed++
+		return false
+	}
+	if !ok {
+		if len(d.keys) >= d.config.MaxKeys {
+			d.forget(e.Time)
+		}
+		s = &dedupState{}
+		d.keys[key] = s
+	}
+	if s.suppressed > 0 {
+		e.SetField("repeated", s.suppressed)
+	}
+	*s = dedupState{start: e.Time, window: window}
+	return true
+}
+
+// window returns the window of the entries of facility
+func (d *deduplicator) window(facility Facility) time.Duration {
+	window, matched := d.config.Window, Facility("")
+	for f, w := range d.config.Windows {
+		if f.Contains(facility) && (matched == "" || len(f) > len(matched)) {
+			window, matched = w, f
+		}
+	}
+	return window
+}
+
+// key returns the key that repeats of e share
+func (d *deduplicator) key(e *Entry) string {
+	switch d.config.Key {
+	case DK_MESSAGE:
+		return e.Message
+	case DK_CUSTOM:
+		return d.config.KeyFunc(e)
+	}
+	return e.Facility() + "\x00" + e.Message
+}
+
+// forget removes the keys whose window has passed at now, or all keys when every window is still open. It must be
+// called with d.mu held
+func (d *deduplicator) forget(now time.Time) {
+	for key, s := range d.keys {
+		if now.Sub(s.start) >= s.window {
+			delete(d.keys, key)
+		}
+	}
+	if len(d.keys) >= d.config.MaxKeys {
+		d.keys = make(map[string]*dedupState)
+	}
+}
@@ -0,0 +1,54 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// deletedCheckInterval is how often the log file is checked for having been deleted or replaced
+const deletedCheckInterval = time.Second
+
+// checkDeleted recreates the log file when it has been deleted, e.g. by an operator cleaning up disk space, or replaced
+// by another file, since it was opened, so that the entries do not go to an unlinked file until the next rotation. It
+// checks at most once per deletedCheckInterval and is called with fileMu held. Shared log files are checked by
+// reopenRotated instead
+func (l *Logger) checkDeleted(now time.Time) {
+	if l.filehandle == nil || l.shared != nil || now.Sub(l.deletedChecked) < deletedCheckInterval {
+		return
+	}
+	l.deletedChecked = now
+	if err := l.recoverDeleted(); err != nil {
+		l.writeInternalLocked(LL_ERROR, "checkDeleted", fmt.Sprintf("Unable to recreate deleted log file %s: %s", l.filename, err.Error()))
+	}
+}
+
+// recoverDeleted reopens the log file when the file at its path is missing or is another file than the one being
+// written to. Entries still in the write buffer are written to the old file first
+func (l *Logger) recoverDeleted() error {
+	current, err := l.filehandle.Stat()
+	if err != nil {
+		return err
+	}
+	named, err := os.Stat(l.filename)
+	if err == nil && os.SameFile(current, named) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	l.flushBuffer()
+	old := l.filehandle
+	if err = l.openLogFile(); err != nil {
+		l.filehandle = old
+		return err
+	}
+	old.Close()
+	var indexErr error
+	if l.base, indexErr = l.newBase(); indexErr != nil {
+		l.writeInternalLocked(LL_ERROR, "checkDeleted", fmt.Sprintf("Unable to open log index: %s", indexErr.Error()))
+	}
+	l.writeInternalLocked(LL_WARN, "checkDeleted", fmt.Sprintf("Log file %s was deleted or replaced, recreated it", l.filename))
+	return nil
+}
@@ -0,0 +1,117 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailRule sends a digest when more than Threshold ERROR entries occur within Window in facilities starting with
+// Facility. Zero members get their default
+type EmailRule struct {
+	Facility  string        // facility prefix the rule applies to, all facilities when empty
+	Threshold int           // number of ERROR entries within the window that is tolerated, defaults to 10
+	Window    time.Duration // length of the sliding window, defaults to 5 minutes
+}
+
+// EmailConfig configures email alerting through an SMTP server
+type EmailConfig struct {
+	Server        string      // SMTP server as host:port
+	Username      string      // user for PLAIN authentication, no authentication when empty
+	Password      string      // password for PLAIN authentication
+	From          string      // sender address
+	To            []string    // recipient addresses
+	SubjectPrefix string      // prefix of the subject, defaults to "[servicelogger]"
+	Rules         []EmailRule // digest rules, a single default rule for all facilities when empty
+	Retry         RetryPolicy // retry policy of failed deliveries
+}
+
+// emailRule tracks the recent ERROR entries of an EmailRule
+type emailRule struct {
+	EmailRule
+	window *entryWindow
+}
+
+// EnableEmailAlerts sends an email digest of the recent ERROR entries whenever a rule's threshold is exceeded, and an
+// email for every FATAL entry right away. The digest holds the entries that exceeded the threshold, after which the
+// rule starts counting again
+func (l *Logger) EnableEmailAlerts(config EmailConfig) (*Notifier, error) {
+	if config.Server == "" || config.From == "" || len(config.To) == 0 {
+		return nil, errors.New("SMTP server, sender and recipients are required")
+	}
+	host, _, err := net.SplitHostPort(config.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server: %s", err.Error())
+	}
+	if config.SubjectPrefix == "" {
+		config.SubjectPrefix = "[servicelogger]"
+	}
+	if len(config.Rules) == 0 {
+		config.Rules = []EmailRule{{}}
+	}
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, host)
+	}
+	rules := make([]*emailRule, len(config.Rules))
+	for n, rule := range config.Rules {
+		if rule.Threshold <= 0 {
+			rule.Threshold = 10
+		}
+		if rule.Window <= 0 {
+			rule.Window = 5 * time.Minute
+		}
+		rules[n] = &emailRule{EmailRule: rule, window: newEntryWindow(rule.Threshold, rule.Window)}
+	}
+	n := l.newNotifier(0, config.Retry, func(no notification) error {
+		return smtp.SendMail(config.Server, auth, config.From, config.To, l.emailMessage(config, no))
+	})
+	l.AddBeforeWriteHook(func(e *Entry) {
+		if e.Level == LL_FATAL {
+			n.submit(notification{entries: []Entry{*e}})
+			return
+		}
+		if e.Level != LL_ERROR {
+			return
+		}
+		for _, rule := range rules {
+			if digest := rule.add(e); digest != nil {
+				n.submit(notification{entries: digest})
+			}
+		}
+	})
+	return n, nil
+}
+
+// add records an ERROR entry and returns the digest when the threshold of the rule is exceeded
+func (r *emailRule) add(e *Entry) []Entry {
+	if !facilityMatches([]string{r.Facility}, e.Facility()) {
+		return nil
+	}
+	return r.window.add(e)
+}
+
+func (l *Logger) emailMessage(config EmailConfig, no notification) []byte {
+	var subject string
+	if len(no.entries) == 1 {
+		e := no.entries[0]
+		subject = fmt.Sprintf("%s %s in %s: %s", config.SubjectPrefix, LogLevelToString(e.Level), e.Facility(), e.Message)
+	} else {
+		first, last := no.entries[0], no.entries[len(no.entries)-1]
+		subject = fmt.Sprintf("%s %d errors between %s and %s", config.SubjectPrefix, len(no.entries), first.Time.Format("15:04:05"), last.Time.Format("15:04:05"))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeString(SM_STRIP, subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, e := range no.entries {
+		e := e
+		b.WriteString(strings.TrimSuffix(l.formatLine(&e), "\n") + "\r\n")
+	}
+	return []byte(b.String())
+}
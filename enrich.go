@@ -0,0 +1,80 @@
+package servicelogger
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// enrichment holds the process details stamped on every entry by EnableEnrichment
+type enrichment struct {
+	host      string
+	pid       int
+	goroutine bool
+}
+
+// EnableEnrichment stamps every entry with the host name and process ID as the fields host and pid, so that entries
+// aggregated from many instances remain attributable. When goroutineID is set, the ID of the logging goroutine is added
+// as the field goroutine as well; determining it costs a few microseconds per entry. Fields passed by the caller take
+// precedence. EnableEnrichment must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableEnrichment(goroutineID bool) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	l.enrich = &enrichment{host: host, pid: os.Getpid(), goroutine: goroutineID}
+}
+
+// DisableEnrichment stops stamping entries with process details
+func (l *Logger) DisableEnrichment() {
+	l.enrich = nil
+}
+
+// SetGlobalFields sets fields that are added to every entry, such as the service name, version and environment, so that
+// downstream pipelines can route and filter on them. In LF_TEXT format they are part of the key=value suffix of every
+// line. Fields passed by the caller take precedence, and a nil or empty map removes the global fields.
+// SetGlobalFields must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetGlobalFields(fields map[string]string) {
+	if len(fields) == 0 {
+		l.globalFields = nil
+		return
+	}
+	l.globalFields = make(map[string]string, len(fields))
+	for key, value := range fields {
+		l.globalFields[key] = value
+	}
+}
+
+// FieldProvider returns a field that is added to an entry at log time, or an empty key to add nothing. It receives a
+// copy of the entry with the level, facility and message already set
+type FieldProvider func(e Entry) (string, interface{})
+
+// AddFieldProvider registers a provider that is called for every entry that passes the level filters, so that current
+// values such as queue depth or feature flag state can be attached to every entry without touching call sites.
+// Providers run before the interceptors and may be called from multiple goroutines at once; fields passed by the
+// caller take precedence. AddFieldProvider must be called before the Logger is used from multiple goroutines
+func (l *Logger) AddFieldProvider(provider FieldProvider) {
+	l.fieldProviders = append(l.fieldProviders, provider)
+}
+
+func (en *enrichment) apply(e *Entry) {
+	e.SetField("host", en.host)
+	e.SetField("pid", en.pid)
+	if en.goroutine {
+		e.SetField("goroutine", goroutineID())
+	}
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the first line of its stack trace, which reads
+// "goroutine 42 [running]:"
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,192 @@
+package servicelogger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a single log entry on its way through the Logger. Interceptors receive a pointer to the Entry and may change
+// any of its members before it is formatted
+type Entry struct {
+	Time     time.Time
+	Level    LogLevel
+	Prefix   string
+	Source   string
+	Function string
+	Message  string
+	Fields   map[string]interface{}
+	Context  context.Context // context of the child logger the entry was logged with, nil otherwise. It is never written
+	elapsed  time.Duration   // time since the start of the process, see SetTimestamps
+	delta    time.Duration   // time since the previous entry, see SetTimestamps
+}
+
+// Interceptor is called for every entry that passes the level filters, before it is formatted. It may enrich or modify
+// the entry, and returns false to drop it
+type Interceptor func(e *Entry) bool
+
+// Facility returns the facility of the entry in the form used by facility filters, prefix.source.function
+func (e *Entry) Facility() string {
+	return string(newFacility(e.Prefix, e.Source, e.Function))
+}
+
+// SetField sets a structured field on the entry
+func (e *Entry) SetField(key string, value interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[key] = value
+}
+
+// AddInterceptor appends an interceptor to the chain of the Logger. Interceptors run in the order they were added, and
+// the chain stops at the first interceptor that drops the entry. Interceptors must be added before the Logger is used
+// from multiple goroutines
+func (l *Logger) AddInterceptor(interceptor Interceptor) {
+	l.interceptors = append(l.interceptors, interceptor)
+}
+
+// Log logs a message at the provided level. Unlike LogFatal it never exits the application
+func (l *Logger) Log(level LogLevel, function string, source string, text string) {
+	l.log(level, function, source, text, nil)
+}
+
+// LogFields logs a message with structured fields at the provided level. In the log file the fields are appended to
+// the message as key=value pairs, separated from it by a tab. Unlike LogFatal it never exits the application
+func (l *Logger) LogFields(level LogLevel, function string, source string, text string, fields map[string]interface{}) {
+	l.log(level, function, source, text, fields)
+}
+
+// log builds an Entry for a message that passes the filters, runs it through the interceptors and writes it. It
+// returns whether the message passed the filters and the governor, even when an interceptor dropped it. Messages of
+// audit facilities always pass. A message logged by a callback of the Logger while it is logging on the same goroutine is
+// written by logNested instead
+func (l *Logger) log(level LogLevel, function string, source string, text string, fields map[string]interface{}) bool {
+	facility := string(newFacility(l.prefix, source, function))
+	audit := l.audit != nil && facilityMatches(l.audit.facilities, facility)
+	if !l.passesFilters(level, facility, source, function, text) && !audit {
+		if l.burst != nil {
+			id, ok := l.enterLog()
+			if ok {
+				l.burst.keep(facility, l.newEntry(level, function, source, text, fields))
+			}
+			l.leaveLog(id)
+		}
+		return false
+	}
+	if l.governor != nil && !l.governor.admit(level) && !audit {
+		return false
+	}
+	id, ok := l.enterLog()
+	if !ok {
+		l.logNested(level, function, source, text)
+		return true
+	}
+	defer l.leaveLog(id)
+	e := l.newEntry(level, function, source, text, fields)
+	if l.stackTraces != nil {
+		l.stackTraces.attach(e)
+	}
+	if l.crashDump != nil && level == LL_FATAL {
+		l.crashDump.attach(l, e)
+	}
+	if l.burst != nil && level.atLeast(l.burst.config.Trigger) {
+		l.flushBurst(facility)
+	}
+	if l.dedup != nil && !audit && !l.dedup.admit(e) {
+		if l.stats != nil {
+			l.stats.deduplicated.Add(1)
+		}
+		l.putEntry(e)
+		return true
+	}
+	if !l.intercept(e) {
+		l.putEntry(e)
+		return true
+	}
+	l.writeEntry(e)
+	if l.async == nil {
+		l.putEntry(e)
+	}
+	return true
+}
+
+// newEntry builds an Entry with the fields of the Logger and of the caller
+func (l *Logger) newEntry(level LogLevel, function string, source string, text string, fields map[string]interface{}) *Entry {
+	e := l.getEntry()
+	e.Time = l.now()
+	e.Level = level
+	e.Prefix = l.prefix
+	e.Source = source
+	e.Function = function
+	e.Message = text
+	e.Context = l.ctx
+	if l.timestamps != nil {
+		l.timestamps.stamp(e)
+	}
+	for key, value := range l.kubernetes {
+		e.SetField(key, value)
+	}
+	for key, value := range l.globalFields {
+		e.SetField(key, value)
+	}
+	if l.enrich != nil {
+		l.enrich.apply(e)
+	}
+	if l.ctx != nil {
+		if id, ok := CorrelationIDFromContext(l.ctx); ok {
+			e.SetField("correlation_id", id)
+		}
+		if t, ok := TraceparentFromContext(l.ctx); ok {
+			e.SetField("trace_id", t.TraceID)
+			e.SetField("span_id", t.SpanID)
+		}
+	}
+	for _, provider := range l.fieldProviders {
+		if key, value := provider(*e); key != "" {
+			e.SetField(key, value)
+		}
+	}
+	for key, value := range fields {
+		e.SetField(key, value)
+	}
+	return e
+}
+
+// intercept runs e through the interceptor chain and returns false when it was dropped
+func (l *Logger) intercept(e *Entry) bool {
+	for _, interceptor := range l.interceptors {
+		if !interceptor(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatFields renders fields as key=value pairs sorted by key, quoting values where needed
+func formatFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for n, key := range keys {
+		if n > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quoteFieldValue(fmt.Sprint(fields[key])))
+	}
+	return b.String()
+}
+
+func quoteFieldValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"") || needsSanitizing(v) {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+package servicelogger
+
+import "errors"
+
+// ErrorClass classifies an error, so that alert rules can select errors by category, code and retryability instead of
+// by message text
+type ErrorClass struct {
+	Category  string // broad area of the error, e.g. "payment" or "database"
+	Code      string // application specific code of the error, e.g. "card_declined"
+	Retryable bool   // whether retrying the failed operation may succeed
+}
+
+// ClassifiedError is an error that carries an ErrorClass
+type ClassifiedError interface {
+	error
+	ErrorClass() ErrorClass
+}
+
+// classifiedError attaches an ErrorClass to an error
+type classifiedError struct {
+	err   error
+	class ErrorClass
+}
+
+func (c *classifiedError) Error() string          { return c.err.Error() }
+func (c *classifiedError) Unwrap() error          { return c.err }
+func (c *classifiedError) ErrorClass() ErrorClass { return c.class }
+
+// Classify returns an error that wraps err and carries class. It returns nil when err is nil
+func Classify(err error, class ErrorClass) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: class}
+}
+
+// ClassOf returns the class of the first ClassifiedError in the chain of err, if any
+func ClassOf(err error) (ErrorClass, bool) {
+	var classified ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.ErrorClass(), true
+	}
+	return ErrorClass{}, false
+}
+
+// LogErrorCause logs a message at ERROR level with err as the field error. When err carries an ErrorClass, the fields
+// error_category, error_code and retryable are added as well; empty category and code are left out
+func (l *Logger) LogErrorCause(function string, source string, text string, err error) {
+	l.log(LL_ERROR, function, source, text, errorFields(err))
+}
+
+// errorFields returns the fields describing err
+func errorFields(err error) map[string]interface{} {
+	if err == nil {
+		return nil
+	}
+	fields := map[string]interface{}{"error": err.Error()}
+	if class, ok := ClassOf(err); ok {
+		if class.Category != "" {
+			fields["error_category"] = class.Category
+		}
+		if class.Code != "" {
+			fields["error_code"] = class.Code
+		}
+		fields["retryable"] = class.Retryable
+	}
+	return fields
+}
@@ -0,0 +1,84 @@
+package servicelogger
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+// ErrorFileConfig configures the errors-only file of a Logger. Zero members get their default
+type ErrorFileConfig struct {
+	Filename   string   // path of the file
+	MinLevel   LogLevel // lowest level written to the file, defaults to LL_WARN
+	Rotate     bool     // rotate the file, independently of the main log file
+	RotateSize string   // size at which the file is rotated, as for New, defaults to "10M"
+	Keep       int      // number of rotated files kept (>=2 when rotating)
+}
+
+// errorFile is the errors-only file of a Logger. It is written through a Logger of its own, which rotates it
+type errorFile struct {
+	mu       sync.Mutex
+	minLevel LogLevel
+	out      Logger
+}
+
+// EnableErrorFile writes the entries at or above config.MinLevel to a second file in addition to the log file, so that
+// operators can triage incidents from a small file instead of the full log. The file gets the same lines as the log
+// file and is rotated according to its own settings. EnableErrorFile must be called before the Logger is used from
+// multiple goroutines
+func (l *Logger) EnableErrorFile(config ErrorFileConfig) error {
+	if l.errorFile != nil {
+		return errors.New("errors file is already enabled")
+	}
+	if config.Filename == "" {
+		return errors.New("errors file needs a file name")
+	}
+	if config.MinLevel == 0 {
+		config.MinLevel = LL_WARN
+	}
+	if config.RotateSize == "" {
+		config.RotateSize = "10M"
+	}
+	if config.Rotate && config.Keep < 2 {
+		return errors.New("keep_rotated too low (>=2)")
+	}
+	size, err := logSizeStringToLogSizeInt64(config.RotateSize)
+	if err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(config.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	out := Logger{loggerState: &loggerState{
+		base:        log.New(fh, "", 0),
+		prefix:      l.prefix,
+		MinLoglevel: config.MinLevel,
+		filename:    config.Filename,
+		rotate:      config.Rotate,
+		rotatesize:  size,
+		keep:        config.Keep,
+		filehandle:  fh,
+		sanitize:    l.sanitize,
+		format:      l.format,
+	}}
+	l.errorFile = &errorFile{minLevel: config.MinLevel, out: out}
+	return nil
+}
+
+// write writes the line of an entry to the file when the entry is at or above the level of the file
+func (f *errorFile) write(e *Entry, line string) {
+	if !e.Level.atLeast(f.minLevel) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = f.out.writeLine(line)
+}
+
+func (f *errorFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.out.filehandle.Close()
+}
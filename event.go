@@ -0,0 +1,67 @@
+package servicelogger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EventCode is a stable numeric identifier of a kind of log message, rendered as E followed by at least four digits
+type EventCode int
+
+// String returns the code in its rendered form, e.g. E1042
+func (c EventCode) String() string {
+	return fmt.Sprintf("E%04d", int(c))
+}
+
+// EventDefinition describes a registered event
+type EventDefinition struct {
+	Code     EventCode
+	Level    LogLevel
+	Template string // fmt template of the message, e.g. "failed to connect to %s"
+}
+
+var (
+	eventsMu sync.RWMutex
+	events   = make(map[EventCode]EventDefinition)
+)
+
+// RegisterEvent adds an event to the catalog shared by all Loggers. Events are typically registered from init functions,
+// so that runbooks can key off the code instead of the message text. Registering a code twice is an error
+func RegisterEvent(code EventCode, level LogLevel, template string) error {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if _, ok := events[code]; ok {
+		return fmt.Errorf("event code %s is already registered", code)
+	}
+	events[code] = EventDefinition{Code: code, Level: level, Template: template}
+	return nil
+}
+
+// Events returns the catalog of registered events ordered by code, e.g. to generate operations documentation
+func Events() []EventDefinition {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	result := make([]EventDefinition, 0, len(events))
+	for _, def := range events {
+		result = append(result, def)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// LogEvent logs a registered event at its level, with the template filled in with args as the message and the code as
+// the field event_code. An unregistered code is logged at ERROR level with the arguments as the message, so that the
+// occurrence is not lost. Like Log it never exits the application, not even for FATAL events
+func (l *Logger) LogEvent(code EventCode, function string, source string, args ...interface{}) {
+	eventsMu.RLock()
+	def, ok := events[code]
+	eventsMu.RUnlock()
+	fields := map[string]interface{}{"event_code": code}
+	if !ok {
+		l.log(LL_ERROR, function, source, fmt.Sprintf("unregistered event %s: %s", code, strings.TrimSuffix(fmt.Sprintln(args...), "\n")), fields)
+		return
+	}
+	l.log(def.Level, function, source, fmt.Sprintf(def.Template, args...), fields)
+}
@@ -0,0 +1,112 @@
+package servicelogger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// exitFlush holds the Loggers that are flushed and closed by Exit and on receipt of a terminating signal
+var exitFlush struct {
+	mu      sync.Mutex
+	loggers []*Logger
+	signals chan os.Signal
+}
+
+// EnableExitFlush registers the Logger to be flushed and closed when the process exits through Exit. When
+// handleSignals is set, the Logger is also flushed and closed on receipt of SIGINT or SIGTERM, after which the signal is
+// raised again so the process terminates as it would have without the handler. Applications that handle these signals
+// themselves, e.g. to shut down gracefully, should pass false and end with Exit or Close instead.
+//
+// Go runs no code when main returns or os.Exit is called, so an asynchronous Logger can only be flushed when the process
+// exits through Exit, LogFatal or a handled signal. Entries logged while a signal is handled may be lost
+func (l *Logger) EnableExitFlush(handleSignals bool) {
+	exitFlush.mu.Lock()
+	defer exitFlush.mu.Unlock()
+	for _, registered := range exitFlush.loggers {
+		if registered.loggerState == l.loggerState {
+			return
+		}
+	}
+	exitFlush.loggers = append(exitFlush.loggers, l)
+	if handleSignals && exitFlush.signals == nil {
+		exitFlush.signals = make(chan os.Signal, 1)
+		signal.Notify(exitFlush.signals, os.Interrupt, syscall.SIGTERM)
+		go handleExitSignal(exitFlush.signals)
+	}
+}
+
+// Exit flushes and closes every Logger registered with EnableExitFlush, and exits the process with the provided code
+func Exit(code int) {
+	closeExitLoggers("")
+	os.Exit(code)
+}
+
+func handleExitSignal(signals chan os.Signal) {
+	sig := <-signals
+	closeExitLoggers(fmt.Sprintf("Received %s, flushing and closing the log", sig))
+	signal.Stop(signals)
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		// the signal cannot be raised again on this platform
+		os.Exit(1)
+	}
+}
+
+// closeExitLoggers logs reason, when set, to every registered Logger and then flushes and closes them
+func closeExitLoggers(reason string) {
+	exitFlush.mu.Lock()
+	defer exitFlush.mu.Unlock()
+	for _, l := range exitFlush.loggers {
+		if reason != "" {
+			l.LogInfo("closeExitLoggers", "servicelogger", reason)
+		}
+		_ = l.Close()
+	}
+	exitFlush.loggers = nil
+}
+
+// Close flushes the Logger and closes its log file, index, errors file, audit log, lock file and the sinks that
+// implement io.Closer. Entries logged after Close are counted as dropped. The background goroutine of an asynchronous
+// Logger is stopped after writing the queue. In-memory Loggers are only flushed
+func (l *Logger) Close() error {
+	if l.async != nil {
+		l.async.stop(context.Background())
+	}
+	l.Flush()
+	l.closeSinks(false)
+	return l.closeFiles()
+}
+
+// closeFiles closes the log file, index, errors file, audit log and lock file of the Logger
+func (l *Logger) closeFiles() error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if l.idle != nil {
+		l.idle.close()
+	}
+	if l.rotationCheck != nil {
+		l.rotationCheck.close()
+	}
+	if l.index != nil {
+		_ = l.index.idx.Close()
+		l.index = nil
+	}
+	if l.errorFile != nil {
+		_ = l.errorFile.close()
+	}
+	if l.audit != nil {
+		_ = l.audit.close()
+	}
+	if l.shared != nil {
+		_ = l.shared.close()
+	}
+	return l.closeLogFile()
+}
@@ -0,0 +1,98 @@
+package servicelogger
+
+import "strings"
+
+// Facility is a hierarchical facility name with dot separated segments, such as "delta.db.Query". The facility of an
+// entry is the prefix of its Logger, followed by its source and function. Facilities are built with Child instead of
+// string formatting:
+//
+//	Facility("delta").Child("db").Child("Query")
+type Facility string
+
+// newFacility returns the facility prefix.source.function of an entry
+func newFacility(prefix string, source string, function string) Facility {
+	return Facility(prefix + "." + source + "." + function)
+}
+
+// Child returns the facility of the segment name below f
+func (f Facility) Child(name string) Facility {
+	if f == "" {
+		return Facility(name)
+	}
+	return f + "." + Facility(name)
+}
+
+// Parent returns the facility f is a segment of, or an empty facility for a facility with a single segment
+func (f Facility) Parent() Facility {
+	if i := strings.LastIndexByte(string(f), '.'); i >= 0 {
+		return f[:i]
+	}
+	return ""
+}
+
+// Name returns the last segment of f
+func (f Facility) Name() string {
+	return string(f[strings.LastIndexByte(string(f), '.')+1:])
+}
+
+// Segments returns the segments of f, from the top down
+func (f Facility) Segments() []string {
+	if f == "" {
+		return nil
+	}
+	return strings.Split(string(f), ".")
+}
+
+// Contains reports whether other is f or one of the facilities below it. Unlike the prefixes of facility filters it
+// compares whole segments, so "delta.db" contains "delta.db.Query" but not "delta.dbx.Query"
+func (f Facility) Contains(other Facility) bool {
+	if f == "" || f == other {
+		return true
+	}
+	return len(other) > len(f) && other[len(f)] == '.' && other[:len(f)] == f
+}
+
+func (f Facility) String() string {
+	return string(f)
+}
+
+// Facility returns the facility of the Logger, its prefix, below which the facilities of its entries are built
+func (l *Logger) Facility() Facility {
+	return Facility(l.prefix)
+}
+
+// LogAt logs a message at the provided level to a facility below the facility of the Logger, such as
+// l.Facility().Child("db").Child("Query"). The last segment is the function of the entry and the segments between the
+// prefix and the function its source. A facility that is not below the facility of the Logger is taken as relative to
+// it. Unlike LogFatal it never exits the application
+func (l *Logger) LogAt(level LogLevel, facility Facility, text string) {
+	l.LogFieldsAt(level, facility, text, nil)
+}
+
+// LogFieldsAt logs a message with structured fields at the provided level to a facility below the facility of the
+// Logger, as LogAt does
+func (l *Logger) LogFieldsAt(level LogLevel, facility Facility, text string, fields map[string]interface{}) {
+	if l.prefix != "" && l.Facility().Contains(facility) {
+		facility = facility[len(l.prefix):]
+		if facility != "" {
+			facility = facility[1:]
+		}
+	}
+	l.log(level, facility.Name(), string(facility.Parent()), text, fields)
+}
+
+// SetFacilityLevel sets the lowest level logged for facility and every facility below it. Like AddFacilityFilter the
+// most specific filter wins, but whole segments are compared, so that a level for "delta.db" does not apply to
+// "delta.dbx". SetFacilityLevel must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetFacilityLevel(facility Facility, level LogLevel) {
+	l.filters.count++
+	l.filters.filters = append(l.filters.filters, FacilityFilter{filter: string(facility), level: level, segments: true})
+}
+
+// matches reports whether the filter applies to facility
+func (ff FacilityFilter) matches(facility string) bool {
+	if ff.segments {
+		return Facility(ff.filter).Contains(Facility(facility))
+	}
+	return strings.HasPrefix(facility, ff.filter)
+}
@@ -0,0 +1,147 @@
+package servicelogger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FacilityStatsConfig configures the counters of EnableFacilityStats. Zero members get their default
+type FacilityStatsConfig struct {
+	Summary time.Duration // interval of the summary entry, no summary when zero
+	Top     int           // number of facilities listed in the summary, defaults to 10
+}
+
+// FacilityCount holds the number of entries of a facility, per level and in total
+type FacilityCount struct {
+	Facility string
+	Levels   map[LogLevel]uint64
+	Total    uint64
+}
+
+// facilityStats counts the entries per facility and level
+type facilityStats struct {
+	counters sync.Map // facility -> *facilityCounter
+	config   FacilityStatsConfig
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type facilityCounter struct {
+	levels [highestLevel + 1]atomic.Uint64
+	window atomic.Uint64 // entries since the last summary
+}
+
+// EnableFacilityStats counts the entries written per facility and level, for FacilityStats. With a summary interval,
+// an INFO entry listing the facilities with the most entries in the last interval, and their share of all entries, is
+// logged at every interval, which shows which modules generate most of the log volume. The summary stops when the
+// Logger is shut down. EnableFacilityStats must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableFacilityStats(config FacilityStatsConfig) {
+	if config.Top <= 0 {
+		config.Top = 10
+	}
+	s := &facilityStats{config: config, stop: make(chan struct{})}
+	l.facilityStats = s
+	if config.Summary > 0 {
+		l.drainers = append(l.drainers, s)
+		go l.summarizeFacilities(s)
+	}
+}
+
+// FacilityStats returns the number of entries written per facility and level since EnableFacilityStats, the facility
+// with the most entries first
+func (l *Logger) FacilityStats() []FacilityCount {
+	if l.facilityStats == nil {
+		return nil
+	}
+	var counts []FacilityCount
+	l.facilityStats.counters.Range(func(key, value interface{}) bool {
+		c := value.(*facilityCounter)
+		count := FacilityCount{Facility: key.(string), Levels: make(map[LogLevel]uint64)}
+		for level := range c.levels {
+			if n := c.levels[level].Load(); n > 0 {
+				count.Levels[LogLevel(level)] = n
+				count.Total += n
+			}
+		}
+		counts = append(counts, count)
+		return true
+	})
+	sortFacilityCounts(counts)
+	return counts
+}
+
+func sortFacilityCounts(counts []FacilityCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total != counts[j].Total {
+			return counts[i].Total > counts[j].Total
+		}
+		return counts[i].Facility < counts[j].Facility
+	})
+}
+
+// count counts e for its facility and level
+func (s *facilityStats) count(e *Entry) {
+	facility := e.Facility()
+	value, ok := s.counters.Load(facility)
+	if !ok {
+		value, _ = s.counters.LoadOrStore(facility, &facilityCounter{})
+	}
+	c := value.(*facilityCounter)
+	if e.Level >= 0 && int(e.Level) < len(c.levels) {
+		c.levels[e.Level].Add(1)
+	}
+	c.window.Add(1)
+}
+
+// summarizeFacilities logs a summary of the facilities with the most entries at every summary interval, until the
+// Logger is shut down
+func (l *Logger) summarizeFacilities(s *facilityStats) {
+	ticker := time.NewTicker(s.config.Summary)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			l.summarize(s)
+		}
+	}
+}
+
+// summarize logs the facilities with the most entries since the last summary and resets their counts
+func (l *Logger) summarize(s *facilityStats) {
+	var counts []FacilityCount
+	var total uint64
+	s.counters.Range(func(key, value interface{}) bool {
+		if n := value.(*facilityCounter).window.Swap(0); n > 0 {
+			counts = append(counts, FacilityCount{Facility: key.(string), Total: n})
+			total += n
+		}
+		return true
+	})
+	if total == 0 {
+		return
+	}
+	sortFacilityCounts(counts)
+	if len(counts) > s.config.Top {
+		counts = counts[:s.config.Top]
+	}
+	top := make([]string, len(counts))
+	for n, count := range counts {
+		top[n] = fmt.Sprintf("%s %d (%.1f%%)", count.Facility, count.Total, float64(count.Total)*100/float64(total))
+	}
+	l.LogFields(LL_INFO, "summarize", "servicelogger", fmt.Sprintf("Top facilities in the last %s: %s", s.config.Summary, strings.Join(top, ", ")), map[string]interface{}{"entries": total})
+}
+
+// Drain stops the summaries. The counts of the last interval are not logged
+func (s *facilityStats) Drain(ctx context.Context) (int, error) {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	return 0, nil
+}
@@ -0,0 +1,118 @@
+package servicelogger
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	fifoBufferSize   = 1024 * 1024            // bytes kept for a FIFO while no reader is connected or keeping up
+	fifoWriteTimeout = 100 * time.Millisecond // time a write to a FIFO may block before the rest is kept
+	fifoRetry        = time.Second            // time between attempts to reconnect to a FIFO without a reader
+	fifoCloseTimeout = time.Second            // time Close waits for a reader to take the kept output
+)
+
+// fifoWriter writes log output to a named pipe. Writing to a FIFO blocks while the reader is not reading and fails once
+// it has gone away, so output that cannot be written right away is kept in a buffer and written when the reader
+// catches up or a new reader has connected
+type fifoWriter struct {
+	mu      sync.Mutex
+	name    string
+	fh      *os.File
+	pending []byte
+	retryAt time.Time
+}
+
+// isFIFO reports whether filename is an existing named pipe
+func isFIFO(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// Write keeps p and writes as much of the kept output as the reader takes. It only fails when the buffer is full, in
+// which case p is dropped
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush(fifoWriteTimeout)
+	if len(w.pending)+len(p) > fifoBufferSize {
+		return 0, errors.New("no reader is taking the output of FIFO " + w.name)
+	}
+	w.pending = append(w.pending, p...)
+	w.flush(fifoWriteTimeout)
+	return len(p), nil
+}
+
+// flush writes the kept output, connecting to the FIFO first when needed. It must be called with w.mu held
+func (w *fifoWriter) flush(timeout time.Duration) {
+	if len(w.pending) == 0 {
+		return
+	}
+	if w.fh == nil {
+		if time.Now().Before(w.retryAt) {
+			return
+		}
+		fh, err := openFIFO(w.name)
+		if err != nil {
+			w.retryAt = time.Now().Add(fifoRetry)
+			return
+		}
+		w.fh = fh
+	}
+	_ = w.fh.SetWriteDeadline(time.Now().Add(timeout))
+	n, err := w.fh.Write(w.pending)
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		// the reader has gone away: reconnect once a new one has opened the FIFO
+		w.fh.Close()
+		w.fh = nil
+		w.retryAt = time.Now().Add(fifoRetry)
+	}
+}
+
+// close writes the kept output when a reader takes it in time, and disconnects from the FIFO
+func (w *fifoWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.retryAt = time.Time{}
+	w.flush(fifoCloseTimeout)
+	if w.fh == nil {
+		return nil
+	}
+	err := w.fh.Close()
+	w.fh = nil
+	return err
+}
+
+// openLogFile opens the log file for writing. When the log file is a named pipe, a fifoWriter is used instead, so that
+// the Logger neither blocks until a reader opens the FIFO nor fails when the reader goes away
+func (l *Logger) openLogFile() (err error) {
+	l.filehandle, l.fifo, err = openLogTarget(l.filename)
+	return err
+}
+
+// openLogTarget opens filename for writing, or returns a fifoWriter for it when it is a named pipe
+func openLogTarget(filename string) (*os.File, *fifoWriter, error) {
+	if isFIFO(filename) {
+		return nil, &fifoWriter{name: filename}, nil
+	}
+	fh, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	return fh, nil, err
+}
+
+// closeLogFile flushes the write buffer and closes the log file, or disconnects from the FIFO. The handle is cleared,
+// so that it is closed exactly once
+func (l *Logger) closeLogFile() error {
+	if l.fifo != nil {
+		return l.fifo.close()
+	}
+	l.flushBuffer()
+	if l.filehandle == nil {
+		return nil
+	}
+	err := l.filehandle.Close()
+	l.filehandle = nil
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package servicelogger
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFIFO connects to a named pipe for writing. It does not block: without a reader it fails with ENXIO
+func openFIFO(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+}
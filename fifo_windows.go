@@ -0,0 +1,13 @@
+//go:build windows
+
+package servicelogger
+
+import (
+	"errors"
+	"os"
+)
+
+// openFIFO is never called on Windows, which has no FIFOs in the file system
+func openFIFO(name string) (*os.File, error) {
+	return nil, errors.New("FIFOs are not supported on Windows")
+}
@@ -0,0 +1,357 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// filterInput is what a filter expression is evaluated against: the message of a log call before its Entry is built
+type filterInput struct {
+	level    LogLevel
+	facility string
+	prefix   string
+	source   string
+	function string
+	message  string
+}
+
+// filterNode is a node of the syntax tree of a filter expression
+type filterNode interface {
+	eval(in *filterInput) bool
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) eval(in *filterInput) bool { return n.left.eval(in) && n.right.eval(in) }
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) eval(in *filterInput) bool { return n.left.eval(in) || n.right.eval(in) }
+
+type notNode struct{ operand filterNode }
+
+func (n notNode) eval(in *filterInput) bool { return !n.operand.eval(in) }
+
+// globNode matches the facility against a pattern in which * matches any text, dots included
+type globNode struct{ pattern string }
+
+func (n globNode) eval(in *filterInput) bool {
+	matched, _ := path.Match(n.pattern, in.facility)
+	return matched
+}
+
+// levelNode compares the level with a constant
+type levelNode struct {
+	op    string
+	level LogLevel
+}
+
+func (n levelNode) eval(in *filterInput) bool {
+	switch n.op {
+	case "==":
+		return in.level == n.level
+	case "!=":
+		return in.level != n.level
+	case ">=":
+		return in.level.Severity() >= n.level.Severity()
+	case ">":
+		return in.level.Severity() > n.level.Severity()
+	case "<=":
+		return in.level.Severity() <= n.level.Severity()
+	default:
+		return in.level.Severity() < n.level.Severity()
+	}
+}
+
+// textNode compares a text attribute with a string, or matches it against a regular expression
+type textNode struct {
+	attribute string
+	op        string
+	value     string
+	re        *regexp.Regexp
+}
+
+func (n textNode) eval(in *filterInput) bool {
+	var text string
+	switch n.attribute {
+	case "facility":
+		text = in.facility
+	case "prefix":
+		text = in.prefix
+	case "source":
+		text = in.source
+	case "function":
+		text = in.function
+	default:
+		text = in.message
+	}
+	switch n.op {
+	case "==":
+		return text == n.value
+	case "!=":
+		return text != n.value
+	case "~":
+		return n.re.MatchString(text)
+	default:
+		return !n.re.MatchString(text)
+	}
+}
+
+// filterRule is a filter expression with the minimum level of the entries it matches, or drop to discard them
+type filterRule struct {
+	expression string
+	node       filterNode
+	level      LogLevel
+	drop       bool
+}
+
+// AddFilterRule adds a rule that applies minLevel to the messages that match a filter expression, instead of the
+// level of the facility filters. Rules are checked in the order they were added, before the facility filters, and the
+// first rule that matches decides. An expression combines conditions with AND, OR and NOT, or &&, || and !, and
+// parentheses. A condition is a facility pattern, in which * matches any text, such as delta.db.*, or a comparison:
+//
+//	level >= WARN                    compares the level, with ==, !=, <, <=, > or >=
+//	function != 'Healthcheck'        compares facility, prefix, source, function or message with == or !=
+//	facility ~ 'http'                matches a regular expression, ~ or !~ for not matching
+//
+// For example "delta.db.* AND level >= WARN" or "facility ~ 'http' && function != 'Healthcheck'". AddFilterRule must
+// be called before the Logger is used from multiple goroutines
+func (l *Logger) AddFilterRule(expression string, minLevel LogLevel) error {
+	node, err := parseFilterExpression(expression)
+	if err != nil {
+		return err
+	}
+	l.filters.rules = append(l.filters.rules, filterRule{expression: expression, node: node, level: minLevel})
+	return nil
+}
+
+// AddDropRule adds a rule that discards the messages that match a filter expression, as described for AddFilterRule.
+// AddDropRule must be called before the Logger is used from multiple goroutines
+func (l *Logger) AddDropRule(expression string) error {
+	node, err := parseFilterExpression(expression)
+	if err != nil {
+		return err
+	}
+	l.filters.rules = append(l.filters.rules, filterRule{expression: expression, node: node, drop: true})
+	return nil
+}
+
+// passesFilters reports whether a message passes the filter rules or, when no rule matches, the facility filters
+func (l *Logger) passesFilters(level LogLevel, facility string, source string, function string, text string) bool {
+	if len(l.filters.rules) > 0 {
+		in := &filterInput{level: level, facility: facility, prefix: l.prefix, source: source, function: function, message: text}
+		for _, rule := range l.filters.rules {
+			if rule.node.eval(in) {
+				return !rule.drop && level.atLeast(rule.level)
+			}
+		}
+	}
+	return level.atLeast(l.getFilteredLogLevel(facility))
+}
+
+// isFilterExpression reports whether a key of a filter file is an expression rather than a facility prefix
+func isFilterExpression(key string) bool {
+	return strings.ContainsAny(key, " \t()!=<>~&|*")
+}
+
+// filterParser is a recursive descent parser of filter expressions
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseFilterExpression parses a filter expression into its syntax tree
+func parseFilterExpression(expression string) (filterNode, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty filter expression")
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("filter expression %q: %w", expression, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("filter expression %q: unexpected %q", expression, p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *filterParser) or() (filterNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); token == "||" || strings.EqualFold(token, "OR"); token = p.peek() {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (filterNode, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); token == "&&" || strings.EqualFold(token, "AND"); token = p.peek() {
+		p.pos++
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) not() (filterNode, error) {
+	if token := p.peek(); token == "!" || strings.EqualFold(token, "NOT") {
+		p.pos++
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *filterParser) primary() (filterNode, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end")
+	case token == "(":
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing )")
+		}
+		return node, nil
+	case isFilterOperator(p.peek()):
+		return p.comparison(strings.ToLower(token), p.next(), p.next())
+	case isQuoted(token):
+		return globNode{pattern: token[1 : len(token)-1]}, nil
+	case token == ")" || isFilterOperator(token):
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	return globNode{pattern: token}, nil
+}
+
+// comparison returns the node comparing attribute with value
+func (p *filterParser) comparison(attribute string, op string, value string) (filterNode, error) {
+	if value == "" {
+		return nil, errors.New("unexpected end")
+	}
+	if isQuoted(value) {
+		value = value[1 : len(value)-1]
+	}
+	switch attribute {
+	case "level":
+		level, ok := levelFromLabel(value)
+		if !ok {
+			level, ok = levelFromLabel(strings.ToUpper(value))
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", value)
+		}
+		if op == "~" || op == "!~" {
+			return nil, fmt.Errorf("level cannot be matched with %s", op)
+		}
+		return levelNode{op: op, level: level}, nil
+	case "facility", "prefix", "source", "function", "message":
+		node := textNode{attribute: attribute, op: op, value: value}
+		switch op {
+		case "==", "!=":
+		case "~", "!~":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, err
+			}
+			node.re = re
+		default:
+			return nil, fmt.Errorf("%s cannot be compared with %s", attribute, op)
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("unknown attribute %q", attribute)
+}
+
+func isFilterOperator(token string) bool {
+	switch token {
+	case "==", "!=", "<", "<=", ">", ">=", "~", "!~":
+		return true
+	}
+	return false
+}
+
+func isQuoted(token string) bool {
+	return len(token) >= 2 && (token[0] == '\'' || token[0] == '"') && token[len(token)-1] == token[0]
+}
+
+// tokenizeFilter splits a filter expression into words, quoted strings, parentheses and operators
+func tokenizeFilter(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("filter expression %q: unterminated string", expression)
+			}
+			tokens = append(tokens, expression[i:i+end+2])
+			i += end + 2
+		case strings.IndexByte("=!<>~&|", c) >= 0:
+			j := i + 1
+			if j < len(expression) && strings.IndexByte("=~&|", expression[j]) >= 0 {
+				j++
+			}
+			op := expression[i:j]
+			if op == "=" || op == "&" || op == "|" {
+				return nil, fmt.Errorf("filter expression %q: unknown operator %q", expression, op)
+			}
+			tokens = append(tokens, op)
+			i = j
+		default:
+			j := i
+			for j < len(expression) && strings.IndexByte(" \t()'\"=!<>~&|", expression[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, expression[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
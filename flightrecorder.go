@@ -0,0 +1,152 @@
+package servicelogger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// flightRecordLayout is the layout of the timestamp in the name of a diagnostics file
+const flightRecordLayout = "20060102T150405"
+
+// FlightRecorderConfig configures the flight recorder of EnableFlightRecorder. Zero members get their default
+type FlightRecorderConfig struct {
+	Dir    string    // directory of the diagnostics files, defaults to the directory of the log file or else the temp dir
+	Signal os.Signal // signal that writes a diagnostics file, defaults to SIGQUIT
+}
+
+// flightRecorder writes a diagnostics file on every signal it receives
+type flightRecorder struct {
+	dir      string
+	signals  chan os.Signal
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// EnableFlightRecorder writes a diagnostics file whenever the process receives the signal, SIGQUIT unless configured
+// otherwise, like the thread dump of a JVM. The file holds the counters of the Logger, the counts per facility when
+// EnableFacilityStats is used, the entries in the ring buffer when EnableRingBuffer is used, and the stacks of all
+// goroutines, and is named after the log file with a .flight suffix, a timestamp and the process ID. Handling SIGQUIT
+// replaces the default of Go to exit with a goroutine dump, so the process keeps running. The handler is removed when
+// the Logger is shut down. EnableFlightRecorder must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableFlightRecorder(config FlightRecorderConfig) error {
+	if l.flightRecorder != nil {
+		return fmt.Errorf("flight recorder is already enabled")
+	}
+	if config.Dir == "" {
+		config.Dir = os.TempDir()
+		if l.filename != "" {
+			config.Dir = filepath.Dir(l.filename)
+		}
+	}
+	if config.Signal == nil {
+		config.Signal = syscall.SIGQUIT
+	}
+	r := &flightRecorder{dir: config.Dir, signals: make(chan os.Signal, 1), stop: make(chan struct{})}
+	l.flightRecorder = r
+	l.drainers = append(l.drainers, r)
+	signal.Notify(r.signals, config.Signal)
+	go l.recordFlights(r)
+	return nil
+}
+
+// recordFlights writes a diagnostics file for every signal, until the Logger is shut down
+func (l *Logger) recordFlights(r *flightRecorder) {
+	for {
+		select {
+		case <-r.stop:
+			return
+		case sig := <-r.signals:
+			filename, err := l.WriteDiagnostics(r.dir)
+			if err != nil {
+				l.LogError("recordFlights", "servicelogger", fmt.Sprintf("Unable to write diagnostics on %s: %s", sig, err.Error()))
+			} else {
+				l.LogInfo("recordFlights", "servicelogger", fmt.Sprintf("Received %s, diagnostics written to %s", sig, filename))
+			}
+		}
+	}
+}
+
+// Drain removes the signal handler of the flight recorder
+func (r *flightRecorder) Drain(ctx context.Context) (int, error) {
+	r.stopOnce.Do(func() {
+		signal.Stop(r.signals)
+		close(r.stop)
+	})
+	return 0, nil
+}
+
+// WriteDiagnostics writes a diagnostics file as the flight recorder does into dir, and returns its name
+func (l *Logger) WriteDiagnostics(dir string) (string, error) {
+	now := time.Now()
+	base := l.prefix
+	if l.filename != "" {
+		base = filepath.Base(l.filename)
+	}
+	if base == "" {
+		base = "servicelogger"
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s.flight.%s.%d", base, now.UTC().Format(flightRecordLayout), os.Getpid()))
+	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(fh)
+	l.writeDiagnostics(w, now)
+	err = w.Flush()
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
+
+func (l *Logger) writeDiagnostics(w *bufio.Writer, now time.Time) {
+	fmt.Fprintf(w, "Flight recorder of %s, pid %d, %s, %s\n", l.prefix, os.Getpid(), runtime.Version(), now.Format(time.RFC3339Nano))
+	fmt.Fprintf(w, "Log file %q, minimum level %s", l.filename, LogLevelToString(l.MinLoglevel))
+	if level := l.GovernorLevel(); level != 0 {
+		fmt.Fprintf(w, ", raised to %s by the governor", LogLevelToString(level))
+	}
+	fmt.Fprintf(w, "\n\n== Stats ==\n%+v\n", l.Stats())
+	if l.async != nil {
+		fmt.Fprintf(w, "Queued: %d\n", l.async.pending())
+	}
+	if l.facilityStats != nil {
+		fmt.Fprintf(w, "\n== Facilities ==\n")
+		for _, count := range l.FacilityStats() {
+			fmt.Fprintf(w, "%s\t%d\n", count.Facility, count.Total)
+		}
+	}
+	fmt.Fprintf(w, "\n== Ring buffer ==\n")
+	if l.ring == nil {
+		fmt.Fprintf(w, "ring buffer is not enabled\n")
+	} else {
+		for _, e := range l.RecentEntries(len(l.ring.entries)) {
+			w.WriteString(formatEntry(&e, LF_TEXT, SM_ESCAPE))
+		}
+	}
+	fmt.Fprintf(w, "\n== Goroutines ==\n")
+	w.Write(goroutineStacks())
+}
+
+// goroutineStacks returns the stack traces of all goroutines
+func goroutineStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
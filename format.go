@@ -0,0 +1,173 @@
+package servicelogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type LogFormat int
+
+const (
+	LF_TEXT     LogFormat = 1 // one line of text per entry, fields appended as key=value pairs
+	LF_JSON     LogFormat = 2 // one JSON object per line
+	LF_LOGFMT   LogFormat = 3 // one line of key=value pairs per entry
+	LF_BINARY   LogFormat = 4 // compact length-prefixed frames, read back with EntryReader
+	LF_CLF      LogFormat = 5 // Common Log Format for the access entries of HTTPMiddleware, write only
+	LF_COMBINED LogFormat = 6 // Combined Log Format for the access entries of HTTPMiddleware, write only
+)
+
+// SetFormat sets the format of the lines written to the log file. The default is LF_TEXT. In LF_JSON format every line
+// is an object with the members time, level, prefix, source, function, facility and message, and the fields of the
+// entry as the object member fields. Control characters are escaped by JSON itself, so the sanitize mode does not
+// apply to it. In LF_LOGFMT format every line consists of the pairs time, level, prefix, source, function and msg
+// followed by the fields, with values quoted where needed. LF_BINARY trades readability for less CPU and disk use with
+// high volumes; use EntryReader, LogReader or Convert to read it. LF_CLF and LF_COMBINED write the access entries of
+// HTTPMiddleware with HTTPLogConfig.Access set as an Apache access log that existing analyzers understand; other
+// entries are written in LF_TEXT format. Use them for a Logger of its own that only receives access entries
+func (l *Logger) SetFormat(format LogFormat) {
+	l.format = format
+}
+
+// FormatEntry returns the line for an entry in the provided format, as the Logger would write it with the default
+// sanitize mode
+func FormatEntry(e *Entry, format LogFormat) string {
+	return formatEntry(e, format, SM_ESCAPE)
+}
+
+// formatJSON returns the line written to the log file for an entry in LF_JSON format, with the timestamps when not nil
+func formatJSON(e *Entry, ts *timestamps) string {
+	b := getBuffer()
+	defer putBuffer(b)
+	if ts.wallClock() {
+		var timestamp [40]byte
+		b.WriteString(`{"time":"`)
+		b.Write(ts.appendStructured(timestamp[:0], ts.structuredTime(e.Time)))
+		b.WriteString(`","level":`)
+		writeJSONString(b, LogLevelToString(e.Level))
+		if ts.dualUTC() {
+			b.WriteString(`,"local_time":"`)
+			b.Write(ts.appendStructured(timestamp[:0], ts.localTime(e.Time)))
+			b.WriteByte('"')
+		}
+		if ts.zone() {
+			name, _ := ts.localTime(e.Time).Zone()
+			b.WriteString(`,"zone":`)
+			writeJSONString(b, name)
+		}
+	} else {
+		b.WriteString(`{"level":`)
+		writeJSONString(b, LogLevelToString(e.Level))
+	}
+	if ts.elapsed() {
+		var seconds [32]byte
+		b.WriteString(`,"elapsed":`)
+		b.Write(ts.appendSeconds(seconds[:0], e.elapsed))
+		if ts.Delta {
+			b.WriteString(`,"delta":`)
+			b.Write(ts.appendSeconds(seconds[:0], e.delta))
+		}
+	}
+	b.WriteString(`,"prefix":`)
+	writeJSONString(b, e.Prefix)
+	b.WriteString(`,"source":`)
+	writeJSONString(b, e.Source)
+	b.WriteString(`,"function":`)
+	writeJSONString(b, e.Function)
+	b.WriteString(`,"facility":`)
+	writeJSONString(b, e.Facility())
+	b.WriteString(`,"message":`)
+	writeJSONString(b, e.Message)
+	if len(e.Fields) > 0 {
+		b.WriteString(`,"fields":`)
+		writeJSONFields(b, e.Fields)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeJSONFields writes fields as a JSON object with sorted keys
+func writeJSONFields(b *bytes.Buffer, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b.WriteByte('{')
+	for n, key := range keys {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		writeJSONString(b, key)
+		b.WriteByte(':')
+		b.Write(jsonFieldValue(fields[key]))
+	}
+	b.WriteByte('}')
+}
+
+// formatLogfmt returns the line written to the log file for an entry in LF_LOGFMT format, with the timestamps when not
+// nil
+func formatLogfmt(e *Entry, ts *timestamps) string {
+	b := getBuffer()
+	defer putBuffer(b)
+	if ts.wallClock() {
+		var timestamp [40]byte
+		b.WriteString("time=")
+		b.Write(ts.appendStructured(timestamp[:0], ts.structuredTime(e.Time)))
+		b.WriteByte(' ')
+	}
+	b.WriteString("level=" + LogLevelToString(e.Level))
+	if ts.wallClock() && ts.dualUTC() {
+		var timestamp [40]byte
+		b.WriteString(" local_time=")
+		b.Write(ts.appendStructured(timestamp[:0], ts.localTime(e.Time)))
+	}
+	if ts.wallClock() && ts.zone() {
+		name, _ := ts.localTime(e.Time).Zone()
+		b.WriteString(" zone=" + quoteFieldValue(name))
+	}
+	if ts.elapsed() {
+		var seconds [32]byte
+		b.WriteString(" elapsed=")
+		b.Write(ts.appendSeconds(seconds[:0], e.elapsed))
+		if ts.Delta {
+			b.WriteString(" delta=")
+			b.Write(ts.appendSeconds(seconds[:0], e.delta))
+		}
+	}
+	b.WriteString(" prefix=" + quoteFieldValue(e.Prefix))
+	b.WriteString(" source=" + quoteFieldValue(e.Source))
+	b.WriteString(" function=" + quoteFieldValue(e.Function))
+	b.WriteString(" msg=" + quoteFieldValue(e.Message))
+	if len(e.Fields) > 0 {
+		b.WriteString(" " + formatFields(e.Fields))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	encoded, _ := json.Marshal(s)
+	b.Write(encoded)
+}
+
+// jsonFieldValue encodes a field value. Strings, numbers and booleans keep their JSON type, a StackTrace is an array of
+// goroutines, errors and values with a String method are rendered as text, and anything that cannot be encoded falls
+// back to its fmt representation
+func jsonFieldValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case StackTrace:
+		encoded, _ := v.MarshalJSON()
+		return encoded
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return encoded
+}
@@ -0,0 +1,143 @@
+package servicelogger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GovernorConfig configures the governor of EnableGovernor. Zero members get their default
+type GovernorConfig struct {
+	MaxRate  int           // entries per second above which the load is high, defaults to 1000
+	MaxQueue int           // queued entries above which the load is high, for an asynchronous Logger, or 0
+	MaxLevel LogLevel      // highest minimum level the governor raises to, defaults to LL_WARN
+	Interval time.Duration // interval at which the load is measured, defaults to one second
+	Sustain  int           // intervals of high load before the level is raised, defaults to 3
+	Calm     int           // intervals of low load before the level is lowered again, defaults to 30
+}
+
+// governor raises the minimum level of a Logger while the load is high
+type governor struct {
+	config   GovernorConfig
+	offered  atomic.Uint64 // entries that passed the filters since the last measurement
+	floor    atomic.Int64  // minimum level imposed by the governor, 0 when not raised
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// EnableGovernor protects the disk during incident storms by raising the minimum level of the Logger while the load
+// stays high, one level at a time up to MaxLevel, e.g. from DEBUG to INFO. The load is high when more than MaxRate
+// entries per second pass the level and facility filters, or when the queue of an asynchronous Logger holds more than
+// MaxQueue entries, for Sustain intervals in a row. Entries suppressed by the governor still count towards the rate,
+// so the level is lowered again one level at a time only after the rate has stayed below half of MaxRate, and the
+// queue below half of MaxQueue, for Calm intervals. Every change is logged. Entries of audit facilities are never
+// suppressed. The governor stops when the Logger is shut down. EnableGovernor must be called before the Logger is used
+// from multiple goroutines
+func (l *Logger) EnableGovernor(config GovernorConfig) error {
+	if l.governor != nil {
+		return fmt.Errorf("governor is already enabled")
+	}
+	if config.MaxRate <= 0 {
+		config.MaxRate = 1000
+	}
+	if config.MaxLevel == 0 {
+		config.MaxLevel = LL_WARN
+	}
+	if !LL_ERROR.atLeast(config.MaxLevel) {
+		return fmt.Errorf("governor cannot raise the level above %s", LogLevelToString(LL_ERROR))
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.Sustain <= 0 {
+		config.Sustain = 3
+	}
+	if config.Calm <= 0 {
+		config.Calm = 30
+	}
+	g := &governor{config: config, stop: make(chan struct{})}
+	l.governor = g
+	l.drainers = append(l.drainers, g)
+	go l.govern(g)
+	return nil
+}
+
+// GovernorLevel returns the minimum level currently imposed by the governor, or 0 when it has not raised the level
+func (l *Logger) GovernorLevel() LogLevel {
+	if l.governor == nil {
+		return 0
+	}
+	return LogLevel(l.governor.floor.Load())
+}
+
+// admit counts an entry at level that passed the filters and reports whether the governor lets it through
+func (g *governor) admit(level LogLevel) bool {
+	g.offered.Add(1)
+	return level.atLeast(LogLevel(g.floor.Load()))
+}
+
+// govern measures the load at every interval and raises or lowers the level, until the Logger is shut down
+func (l *Logger) govern(g *governor) {
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+	high, low := 0, 0
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+		}
+		rate := float64(g.offered.Swap(0)) / g.config.Interval.Seconds()
+		queued := 0
+		if l.async != nil && g.config.MaxQueue > 0 {
+			queued = l.async.pending()
+		}
+		switch {
+		case rate > float64(g.config.MaxRate) || (g.config.MaxQueue > 0 && queued > g.config.MaxQueue):
+			high, low = high+1, 0
+		case rate < float64(g.config.MaxRate)/2 && (g.config.MaxQueue == 0 || queued < g.config.MaxQueue/2):
+			high, low = 0, low+1
+		default:
+			high, low = 0, 0
+		}
+		floor := LogLevel(g.floor.Load())
+		current := l.MinLoglevel
+		if !current.atLeast(floor) {
+			current = floor
+		}
+		if high >= g.config.Sustain && !current.atLeast(g.config.MaxLevel) {
+			high = 0
+			g.floor.Store(int64(current + 1))
+			l.logGovernor(current+1, fmt.Sprintf("Log volume is high (%.0f entries/s, %d queued), raising the minimum level to %s", rate, queued, LogLevelToString(current+1)))
+		} else if low >= g.config.Calm && floor != 0 {
+			low = 0
+			lowered := floor - 1
+			if l.MinLoglevel.atLeast(lowered) {
+				lowered = l.MinLoglevel
+				g.floor.Store(0)
+			} else {
+				g.floor.Store(int64(lowered))
+			}
+			l.logGovernor(floor, fmt.Sprintf("Log volume is back to normal (%.0f entries/s), lowering the minimum level to %s", rate, LogLevelToString(lowered)))
+		}
+	}
+}
+
+// logGovernor logs a change of level at WARN, or at the raised level when higher, so that it is never suppressed
+func (l *Logger) logGovernor(floor LogLevel, text string) {
+	level := LL_WARN
+	if !level.atLeast(floor) {
+		level = floor
+	}
+	l.Log(level, "govern", "servicelogger", text)
+}
+
+// Drain stops the governor. The level it imposed is kept
+func (g *governor) Drain(ctx context.Context) (int, error) {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+	})
+	return 0, nil
+}
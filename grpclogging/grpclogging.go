@@ -0,0 +1,124 @@
+// Package grpclogging - gRPC server and client interceptors that log calls through servicelogger
+package grpclogging
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// CorrelationIDMetadataKey is the metadata key used to pass correlation IDs between services
+const CorrelationIDMetadataKey = "x-correlation-id"
+
+// CodeToLevel returns the LogLevel used for a call that finished with code. Calls that fail because of the caller are
+// logged at WARN level, calls that fail because of the server at ERROR level
+func CodeToLevel(code codes.Code) servicelogger.LogLevel {
+	switch code {
+	case codes.OK:
+		return servicelogger.LL_INFO
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return servicelogger.LL_WARN
+	default:
+		return servicelogger.LL_ERROR
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that logs every unary call handled by the server. A correlation ID
+// received in the metadata is added to the context passed to the handler
+func UnaryServerInterceptor(l *servicelogger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = incomingCorrelationID(ctx)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, l, "server", "unary", info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that logs every streaming call handled by the server when the stream
+// ends
+func StreamServerInterceptor(l *servicelogger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := incomingCorrelationID(ss.Context())
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, l, "server", "stream", info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor that logs every unary call made by the client. A correlation ID carried
+// by the context is passed on to the server in the metadata
+func UnaryClientInterceptor(l *servicelogger.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = outgoingCorrelationID(ctx)
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logCall(ctx, l, "client", "unary", method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that logs the setup of every streaming call made by the client
+func StreamClientInterceptor(l *servicelogger.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = outgoingCorrelationID(ctx)
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		logCall(ctx, l, "client", "stream", method, start, err)
+		return cs, err
+	}
+}
+
+func logCall(ctx context.Context, l *servicelogger.Logger, side string, kind string, fullMethod string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := map[string]interface{}{
+		"code":     code.String(),
+		"duration": time.Since(start),
+		"kind":     kind,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields["peer"] = p.Addr.String()
+	}
+	if err != nil {
+		fields["error"] = status.Convert(err).Message()
+	}
+	l.WithContext(ctx).LogFields(CodeToLevel(code), path.Base(fullMethod), "grpc."+side, fullMethod, fields)
+}
+
+func incomingCorrelationID(ctx context.Context) context.Context {
+	if _, ok := servicelogger.CorrelationIDFromContext(ctx); ok {
+		return ctx
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(CorrelationIDMetadataKey); len(ids) > 0 && ids[0] != "" {
+			return servicelogger.ContextWithCorrelationID(ctx, ids[0])
+		}
+	}
+	return ctx
+}
+
+func outgoingCorrelationID(ctx context.Context) context.Context {
+	if id, ok := servicelogger.CorrelationIDFromContext(ctx); ok {
+		return metadata.AppendToOutgoingContext(ctx, CorrelationIDMetadataKey, id)
+	}
+	return ctx
+}
+
+// serverStream replaces the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
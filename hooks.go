@@ -0,0 +1,51 @@
+package servicelogger
+
+// BeforeWriteHook is called for every entry that is about to be formatted and written, after the interceptors ran. Hooks
+// observe entries and must not modify them; use an Interceptor for that
+type BeforeWriteHook func(e *Entry)
+
+// AfterWriteHook is called after an entry has been written, with the error of the write or nil when it succeeded. For
+// an asynchronous Logger it is called from the background goroutine
+type AfterWriteHook func(e *Entry, err error)
+
+// RotationHook is called after the log file has been rotated, with the name of the segment the old log file was renamed
+// to. It runs on the goroutine that triggered the rotation, before the next entry is written; hooks that do slow work,
+// such as archiving the segment, should open it and continue in a goroutine of their own, since the next rotation
+// renames it again
+type RotationHook func(segment string)
+
+// AddBeforeWriteHook registers a hook that is called before every entry is formatted. Hooks must be added before the
+// Logger is used from multiple goroutines
+func (l *Logger) AddBeforeWriteHook(hook BeforeWriteHook) {
+	l.beforeHooks = append(l.beforeHooks, hook)
+}
+
+// AddAfterWriteHook registers a hook that is called after every entry is written. Hooks must be added before the Logger
+// is used from multiple goroutines
+func (l *Logger) AddAfterWriteHook(hook AfterWriteHook) {
+	l.afterHooks = append(l.afterHooks, hook)
+}
+
+func (l *Logger) beforeWrite(e *Entry) {
+	for _, hook := range l.beforeHooks {
+		hook(e)
+	}
+}
+
+func (l *Logger) afterWrite(e *Entry, err error) {
+	for _, hook := range l.afterHooks {
+		hook(e, err)
+	}
+}
+
+// AddRotationHook registers a hook that is called after every rotation of the log file. Hooks must be added before the
+// Logger is used from multiple goroutines
+func (l *Logger) AddRotationHook(hook RotationHook) {
+	l.rotationHooks = append(l.rotationHooks, hook)
+}
+
+func (l *Logger) rotated(segment string) {
+	for _, hook := range l.rotationHooks {
+		hook(segment)
+	}
+}
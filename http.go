@@ -0,0 +1,112 @@
+package servicelogger
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPLogConfig configures the entries written by HTTPMiddleware. Zero members get their default
+type HTTPLogConfig struct {
+	Source           string   // source of the entries, defaults to "http"
+	SuccessLevel     LogLevel // level for responses with a status below 400, defaults to LL_INFO
+	ClientErrorLevel LogLevel // level for 4xx responses, defaults to LL_WARN
+	ServerErrorLevel LogLevel // level for 5xx responses, defaults to LL_ERROR
+	Access           bool     // add the fields used by LF_CLF and LF_COMBINED: method, uri, proto, user, referer and user_agent
+}
+
+// CorrelationIDHeader is the HTTP header HTTPMiddleware takes a correlation ID from
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// HTTPMiddleware wraps an http.Handler and logs one entry per request with the method and path as message, and the
+// status, duration, response size, remote address and, when the request carries one, correlation ID as fields. A
+// correlation ID received in the X-Correlation-ID header is added to the request context passed to next, so handlers
+// can log with l.WithContext(r.Context()) and have their entries carry it too. The same goes for the trace and span IDs
+// of a W3C traceparent header, as set by front proxies and OpenTelemetry clients. With config.Access set, a Logger in
+// LF_CLF or LF_COMBINED format writes the entries as an access log
+func (l *Logger) HTTPMiddleware(next http.Handler, config HTTPLogConfig) http.Handler {
+	if config.Source == "" {
+		config.Source = "http"
+	}
+	if config.SuccessLevel == 0 {
+		config.SuccessLevel = LL_INFO
+	}
+	if config.ClientErrorLevel == 0 {
+		config.ClientErrorLevel = LL_WARN
+	}
+	if config.ServerErrorLevel == 0 {
+		config.ServerErrorLevel = LL_ERROR
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := CorrelationIDFromContext(r.Context()); !ok {
+			if id := r.Header.Get(CorrelationIDHeader); id != "" {
+				r = r.WithContext(ContextWithCorrelationID(r.Context(), id))
+			}
+		}
+		if _, ok := r.Context().Value(traceparentKey).(string); !ok {
+			if value := r.Header.Get(TraceparentHeader); value != "" {
+				r = r.WithContext(ContextWithTraceparent(r.Context(), value))
+			}
+		}
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		level := config.SuccessLevel
+		if rw.status >= 500 {
+			level = config.ServerErrorLevel
+		} else if rw.status >= 400 {
+			level = config.ClientErrorLevel
+		}
+		fields := map[string]interface{}{
+			"status":   rw.status,
+			"duration": time.Since(start),
+			"bytes":    rw.bytes,
+			"remote":   r.RemoteAddr,
+		}
+		if config.Access {
+			fields["method"] = r.Method
+			fields["uri"] = r.URL.RequestURI()
+			fields["proto"] = r.Proto
+			fields["referer"] = r.Referer()
+			fields["user_agent"] = r.UserAgent()
+			if user, _, ok := r.BasicAuth(); ok {
+				fields["user"] = user
+			}
+		}
+		l.WithContext(r.Context()).log(level, "ServeHTTP", config.Source, r.Method+" "+r.URL.RequestURI(), fields)
+	})
+}
+
+// responseRecorder captures the status and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes on to the wrapped ResponseWriter when it supports them
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped ResponseWriter, for use by http.ResponseController
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,107 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenMargin is how long before its expiry a token from HTTPAuth.Token is replaced
+const tokenMargin = 30 * time.Second
+
+// HTTPAuth configures how an HTTPSink authenticates. Bearer token, basic auth and token callback exclude each other;
+// an API key can be combined with any of them
+type HTTPAuth struct {
+	BearerToken  string // static token sent as "Authorization: Bearer"
+	Username     string // user name of basic auth
+	Password     string // password of basic auth
+	APIKeyHeader string // header the API key is sent in, such as "X-API-Key"
+	APIKey       string // value of the API key header
+	// Token returns a short-lived bearer token and when it expires, e.g. from an OAuth2 token source or a cloud
+	// credential provider. It is called for the first request, shortly before the token expires and after the endpoint
+	// answered 401 Unauthorized. A zero expiry keeps the token until it is rejected
+	Token func(ctx context.Context) (string, time.Time, error)
+}
+
+// httpAuth applies HTTPAuth to requests, caching the token of the callback
+type httpAuth struct {
+	HTTPAuth
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newHTTPAuth validates auth and returns nil when it does not authenticate at all
+func newHTTPAuth(auth HTTPAuth) (*httpAuth, error) {
+	schemes := 0
+	for _, set := range []bool{auth.BearerToken != "", auth.Username != "" || auth.Password != "", auth.Token != nil} {
+		if set {
+			schemes++
+		}
+	}
+	if schemes > 1 {
+		return nil, errors.New("only one of bearer token, basic auth and token callback can be used")
+	}
+	if (auth.APIKeyHeader == "") != (auth.APIKey == "") {
+		return nil, errors.New("an API key requires both a header and a key")
+	}
+	if schemes == 0 && auth.APIKey == "" {
+		return nil, nil
+	}
+	return &httpAuth{HTTPAuth: auth}, nil
+}
+
+// apply sets the authentication headers of req
+func (a *httpAuth) apply(req *http.Request) error {
+	if a == nil {
+		return nil
+	}
+	if a.APIKey != "" {
+		req.Header.Set(a.APIKeyHeader, a.APIKey)
+	}
+	switch {
+	case a.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	case a.Username != "" || a.Password != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	case a.Token != nil:
+		token, err := a.current(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// current returns the cached token of the callback, fetching a new one when there is none or it is about to expire
+func (a *httpAuth) current(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && (a.expires.IsZero() || time.Until(a.expires) > tokenMargin) {
+		return a.token, nil
+	}
+	token, expires, err := a.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errors.New("token callback returned an empty token")
+	}
+	a.token, a.expires = token, expires
+	return token, nil
+}
+
+// refreshable reports whether a request rejected as unauthorized may succeed with a new token, and drops the cached
+// one if so
+func (a *httpAuth) refreshable() bool {
+	if a == nil || a.Token == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+	return true
+}
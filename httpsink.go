@@ -0,0 +1,386 @@
+package servicelogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Compression is the content coding of the payloads of an HTTPSink
+type Compression int
+
+const (
+	CP_GZIP    Compression = 1 // gzip, sent as Content-Encoding gzip
+	CP_DEFLATE Compression = 2 // zlib, sent as Content-Encoding deflate
+	CP_CUSTOM  Compression = 3 // the Encoder of the config, e.g. zstd from a third-party package
+)
+
+// Encoder compresses payloads in a content coding the standard library does not provide
+type Encoder struct {
+	Name      string                                    // content coding in the Content-Encoding header, such as "zstd"
+	NewWriter func(w io.Writer) (io.WriteCloser, error) // returns a writer that compresses to w, flushed by Close
+}
+
+// BatchConfig configures when a remote sink sends a batch: as soon as any of the limits is reached. Zero members get
+// their default
+type BatchConfig struct {
+	MaxEntries int           // entries in a batch, defaults to 100
+	MaxBytes   int           // bytes of the entries in a batch before compression, defaults to 1 MiB
+	MaxAge     time.Duration // time a batch waits for more entries after its first one, defaults to one second
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 100
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 1 << 20
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = time.Second
+	}
+	return c
+}
+
+// HTTPSinkConfig configures a sink returned by NewHTTPSink. Zero members get their default
+type HTTPSinkConfig struct {
+	URL         string            // endpoint the batches are posted to
+	Format      LogFormat         // format of the entries, one per line, defaults to LF_JSON. LF_BINARY cannot be used
+	ContentType string            // defaults to application/x-ndjson for LF_JSON and text/plain for the other formats
+	Headers     map[string]string // extra headers of every request
+	Compression Compression       // content coding of the batches, uncompressed when zero
+	Encoder     Encoder           // encoder used with CP_CUSTOM
+	Timeout     time.Duration     // timeout of a request, defaults to 10 seconds
+	TLS         *TLSConfig        // TLS settings of an https URL, those of net/http when nil
+	Auth        HTTPAuth          // authentication of the requests, none when zero
+	Proxy       string            // URL of the proxy, optionally with credentials, HTTP_PROXY and HTTPS_PROXY when empty
+	Retry       RetryPolicy       // retry policy of every batch
+	QueueSize   int               // entries waiting to be batched before new ones are dropped, defaults to 10000
+	Batch       BatchConfig       // when batches are sent
+}
+
+// HTTPSinkStats holds the counters of an HTTPSink
+type HTTPSinkStats struct {
+	Sent         uint64 // entries delivered
+	Batches      uint64 // batches delivered
+	Retried      uint64 // delivery attempts that were repeated after a failure
+	Dropped      uint64 // entries dropped because the queue was full or the sink was drained
+	Failed       uint64 // entries in batches that could not be delivered
+	Bytes        uint64 // payload bytes of the delivered batches before compression
+	SentBytes    uint64 // payload bytes of the delivered batches as sent, after compression
+	Uncompressed bool   // whether the endpoint rejected the content coding, so that batches are sent uncompressed
+}
+
+// HTTPSink posts entries in batches of lines to an HTTP endpoint from a background goroutine, such as a log collector
+type HTTPSink struct {
+	config    HTTPSinkConfig
+	client    *http.Client
+	auth      *httpAuth
+	encoding  string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	plain     atomic.Bool
+	retry     RetryPolicy
+	mu        sync.Mutex
+	closed    bool
+	queue     chan []byte
+	stopped   chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	sent      atomic.Uint64
+	batches   atomic.Uint64
+	retried   atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+	bytes     atomic.Uint64
+	sentBytes atomic.Uint64
+}
+
+// NewHTTPSink returns an HTTPSink that posts the entries to config.URL. A batch is sent as soon as it reaches the
+// entry count or size limit of config.Batch or its first entry reached the age limit, so that a busy service sends
+// few large requests while a quiet one still delivers its entries in time. An entry larger than the size limit is
+// sent in a batch of its own. With compression, every batch is compressed and sent with the
+// Content-Encoding header, which cuts the traffic of verbose services considerably. When the endpoint answers 415
+// Unsupported Media Type to a compressed batch, the batch is sent again uncompressed and so are all later batches, so
+// that a collector that does not understand the content coding still receives the entries. Batches are retried
+// according to the policy, and dropped when it gives up. With config.TLS, an https endpoint is verified against a
+// custom CA bundle and can require a client certificate. config.Auth adds a bearer token, basic auth or an API key to
+// every request, or a token from a callback that is refreshed before it expires and when the endpoint rejects it. The
+// proxy of the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY is used unless config.Proxy names one; the
+// user and password of its URL authenticate with the proxy
+func NewHTTPSink(config HTTPSinkConfig) (*HTTPSink, error) {
+	if config.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+	if config.Format == 0 {
+		config.Format = LF_JSON
+	}
+	if config.Format == LF_BINARY {
+		return nil, errors.New("an HTTP sink cannot send LF_BINARY entries")
+	}
+	if config.ContentType == "" {
+		config.ContentType = "text/plain; charset=utf-8"
+		if config.Format == LF_JSON {
+			config.ContentType = "application/x-ndjson"
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	config.Batch = config.Batch.withDefaults()
+	auth, err := newHTTPAuth(config.Auth)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != "" {
+		proxy, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if proxy.Scheme != "http" && proxy.Scheme != "https" && proxy.Scheme != "socks5" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if config.TLS != nil {
+		if transport.TLSClientConfig, err = config.TLS.config(); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &HTTPSink{
+		config:  config,
+		client:  &http.Client{Timeout: config.Timeout, Transport: transport},
+		auth:    auth,
+		retry:   config.Retry.withDefaults(),
+		queue:   make(chan []byte, config.QueueSize),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	switch config.Compression {
+	case 0:
+	case CP_GZIP:
+		s.encoding = "gzip"
+		s.newWriter = func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+	case CP_DEFLATE:
+		s.encoding = "deflate"
+		s.newWriter = func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil }
+	case CP_CUSTOM:
+		if config.Encoder.Name == "" || config.Encoder.NewWriter == nil {
+			cancel()
+			return nil, errors.New("CP_CUSTOM requires an encoder with a name and a writer")
+		}
+		s.encoding = config.Encoder.Name
+		s.newWriter = config.Encoder.NewWriter
+	default:
+		cancel()
+		return nil, errors.New("unknown compression")
+	}
+	go s.run()
+	return s, nil
+}
+
+// WriteEntry queues e for the next batch. It returns an error when the entry had to be dropped
+func (s *HTTPSink) WriteEntry(e *Entry) error {
+	line := []byte(formatEntry(e, s.config.Format, SM_ESCAPE))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return errors.New("HTTP sink is drained")
+	}
+	select {
+	case s.queue <- line:
+		return nil
+	default:
+		s.dropped.Add(1)
+		return errors.New("HTTP sink queue is full")
+	}
+}
+
+// Stats returns the counters of the sink
+func (s *HTTPSink) Stats() HTTPSinkStats {
+	return HTTPSinkStats{
+		Sent:         s.sent.Load(),
+		Batches:      s.batches.Load(),
+		Retried:      s.retried.Load(),
+		Dropped:      s.dropped.Load(),
+		Failed:       s.failed.Load(),
+		Bytes:        s.bytes.Load(),
+		SentBytes:    s.sentBytes.Load(),
+		Uncompressed: s.plain.Load(),
+	}
+}
+
+// Drain sends the queued entries and stops the sink, giving up when ctx is done. Retries that are waiting when ctx is
+// done are abandoned. It returns the number of queued entries that were not sent, which are counted as dropped
+func (s *HTTPSink) Drain(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.stopped:
+		return 0, nil
+	case <-ctx.Done():
+		s.cancel()
+		left := len(s.queue)
+		s.dropped.Add(uint64(left))
+		return left, ctx.Err()
+	}
+}
+
+// Close sends the queued entries and stops the sink
+func (s *HTTPSink) Close() error {
+	_, err := s.Drain(context.Background())
+	return err
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.stopped)
+	var batch bytes.Buffer
+	count := 0
+	limits := s.config.Batch
+	timer := time.NewTimer(limits.MaxAge)
+	timer.Stop()
+	flush := func() {
+		if count > 0 {
+			s.send(batch.Bytes(), count)
+		}
+		batch.Reset()
+		count = 0
+	}
+	for s.ctx.Err() == nil {
+		var due <-chan time.Time
+		if count > 0 {
+			due = timer.C
+		}
+		select {
+		case line, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if count > 0 && batch.Len()+len(line) > limits.MaxBytes {
+				stopTimer(timer)
+				flush()
+			}
+			if count == 0 {
+				timer.Reset(limits.MaxAge)
+			}
+			batch.Write(line)
+			count++
+			if count >= limits.MaxEntries || batch.Len() >= limits.MaxBytes {
+				stopTimer(timer)
+				flush()
+			}
+		case <-due:
+			flush()
+		}
+	}
+	// abandoned by Drain, which counted the queued entries
+	s.dropped.Add(uint64(count))
+}
+
+// stopTimer stops timer and empties its channel, so that it can be reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+// send delivers a batch of count entries
+func (s *HTTPSink) send(body []byte, count int) {
+	attempts, err := s.retry.retry(s.ctx, func() error {
+		return s.post(body, false)
+	})
+	s.retried.Add(uint64(attempts - 1))
+	if err != nil {
+		s.failed.Add(uint64(count))
+		return
+	}
+	s.sent.Add(uint64(count))
+	s.batches.Add(1)
+}
+
+// post sends a batch once, compressed unless the endpoint rejected the content coding before. A batch rejected as
+// unauthorized is sent again with a new token from the callback, unless the token was just refreshed
+func (s *HTTPSink) post(body []byte, refreshed bool) error {
+	payload := body
+	encoding := ""
+	if s.newWriter != nil && !s.plain.Load() {
+		var err error
+		if payload, err = s.compress(body); err != nil {
+			return Permanent(err)
+		}
+		encoding = s.encoding
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", s.config.ContentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if err = s.auth.apply(req); err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnsupportedMediaType && encoding != "" {
+		// the endpoint does not understand the content coding: fall back to uncompressed batches
+		s.plain.Store(true)
+		return s.post(body, refreshed)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && !refreshed && s.auth.refreshable() {
+		return s.post(body, true)
+	}
+	if err = responseError(resp); err == nil {
+		s.bytes.Add(uint64(len(body)))
+		s.sentBytes.Add(uint64(len(payload)))
+	}
+	return err
+}
+
+// compress returns body in the content coding of the sink
+func (s *HTTPSink) compress(body []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := s.newWriter(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(body); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
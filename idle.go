@@ -0,0 +1,132 @@
+package servicelogger
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// idleFile closes the log file of a Logger that has not written for a while, and opens it again on the next write
+type idleFile struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	active  int       // writes in progress, during which the log file stays open
+	last    time.Time // end of the last write
+	stop    chan struct{}
+	closed  bool
+}
+
+// EnableIdleClose closes the log file when nothing has been written to it for the timeout, and opens it again
+// transparently on the next write, so that many mostly idle Loggers, e.g. one per tenant, do not keep a file
+// descriptor each. The log file is closed right away and only opened on the first write. A log file that cannot be
+// opened again counts the entries as dropped until it can. FIFOs cannot be closed when idle. EnableIdleClose must be
+// called after the other options that concern the log file, such as EnableIndex and EnableWriteBuffer, and before the
+// Logger is used from multiple goroutines
+func (l *Logger) EnableIdleClose(timeout time.Duration) error {
+	if l.idle != nil {
+		return errors.New("idle close is already enabled")
+	}
+	if l.memory != nil || l.fifo != nil || l.filehandle == nil {
+		return errors.New("only a log file can be closed when idle")
+	}
+	if timeout <= 0 {
+		return errors.New("idle timeout too low (>0)")
+	}
+	l.idle = &idleFile{timeout: timeout, stop: make(chan struct{})}
+	l.idle.mu.Lock()
+	l.closeIdle()
+	l.idle.mu.Unlock()
+	go l.watchIdle(l.idle)
+	return nil
+}
+
+// acquireFile opens the log file when it was closed for being idle, and keeps it open until releaseFile. It is called
+// with fileMu held. Calls may be nested
+func (l *Logger) acquireFile() error {
+	f := l.idle
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return errIdleClosed
+	}
+	var indexErr error
+	if l.filehandle == nil {
+		if err := l.openLogFile(); err != nil {
+			f.mu.Unlock()
+			return err
+		}
+		l.base, indexErr = l.newBase()
+	}
+	f.active++
+	f.mu.Unlock()
+	if indexErr != nil {
+		l.writeInternalLocked(LL_ERROR, "acquireFile", "Unable to open log index: "+indexErr.Error())
+	}
+	return nil
+}
+
+func (l *Logger) releaseFile() {
+	f := l.idle
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+	f.last = time.Now()
+}
+
+// watchIdle closes the log file once it has been idle for the timeout, until the Logger is closed
+func (l *Logger) watchIdle(f *idleFile) {
+	interval := f.timeout / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		l.fileMu.Lock()
+		f.mu.Lock()
+		if f.active == 0 && l.filehandle != nil && time.Since(f.last) >= f.timeout {
+			l.closeIdle()
+		}
+		f.mu.Unlock()
+		l.fileMu.Unlock()
+	}
+}
+
+// close stops watching the log file, which is closed by the caller
+func (f *idleFile) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.stop)
+	}
+}
+
+// closeIdle closes the log file and its index. It must be called with l.idle.mu held and no write in progress
+func (l *Logger) closeIdle() {
+	if l.index != nil {
+		_ = l.index.idx.Close()
+		l.index = nil
+	}
+	_ = l.closeLogFile()
+	l.filehandle = nil
+	l.base = log.New(errorWriter{errIdleClosed}, "", 0)
+}
+
+// errIdleClosed is returned by writes that bypass acquireFile while the log file is closed for being idle
+var errIdleClosed = errors.New("log file is closed while idle")
+
+// errorWriter fails every write with err
+type errorWriter struct {
+	err error
+}
+
+func (w errorWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
@@ -0,0 +1,251 @@
+package servicelogger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// indexHeader starts every index file, followed by the offset in the log file at which indexing started. Only an index
+// that started at offset 0 covers the whole log file and is used by LogReader
+const indexHeader = "SLIDX1"
+
+// indexSuffix is appended to the name of a log file or rotated segment to get the name of its index
+const indexSuffix = ".idx"
+
+// indexWriter passes lines on to the log file and records in the index, for every minute, the offset of the first line
+// of each level within that minute. Each record is a line holding the Unix minute, the level and the offset. Write is
+// called with the mutex of the log.Logger held, so lines and their offsets are seen in file order
+type indexWriter struct {
+	out      io.Writer
+	idx      *os.File
+	offset   int64
+	minute   int64
+	levels   uint8
+	location *time.Location // time zone of LF_TEXT timestamps
+}
+
+// indexRecord is a single record of an index
+type indexRecord struct {
+	minute int64
+	level  LogLevel
+	offset int64
+}
+
+// EnableIndex maintains a small index next to the log file, named after it with an .idx suffix, holding the offsets of
+// the first entry of every level in every minute. LogReader uses it to seek straight to the requested time range and
+// level instead of scanning the whole file. Indexes are rotated together with the log file; an index created for a
+// log file that already had content is not used until the next rotation. EnableIndex must be called before the Logger
+// is used from multiple goroutines
+func (l *Logger) EnableIndex() error {
+	if l.memory != nil {
+		return fmt.Errorf("an in-memory logger cannot be indexed")
+	}
+	if l.fifo != nil {
+		return fmt.Errorf("a logger writing to a FIFO cannot be indexed")
+	}
+	if l.shared != nil {
+		return fmt.Errorf("a shared log file cannot be indexed")
+	}
+	if !l.timestamps.wallClock() {
+		return fmt.Errorf("a log file without the wall clock time cannot be indexed")
+	}
+	l.indexed = true
+	base, err := l.newBase()
+	if err != nil {
+		return err
+	}
+	l.base = base
+	return nil
+}
+
+// newBase returns a log.Logger writing to the current log file, through an indexWriter when indexing is enabled. When
+// the index cannot be opened, the log.Logger writes to the log file directly and the error is returned
+func (l *Logger) newBase() (*log.Logger, error) {
+	if l.index != nil {
+		l.index.idx.Close()
+		l.index = nil
+	}
+	if l.fifo != nil {
+		return log.New(l.fifo, "", 0), nil
+	}
+	if l.buffer != nil {
+		l.buffer.retarget(l.filehandle)
+	}
+	if !l.indexed {
+		return log.New(l.output(), "", 0), nil
+	}
+	w, err := newIndexWriter(l.filehandle, l.output(), l.filename+indexSuffix)
+	if err != nil {
+		return log.New(l.output(), "", 0), err
+	}
+	w.location = l.timestamps.location()
+	l.index = w
+	return log.New(w, "", 0), nil
+}
+
+// newIndexWriter returns an indexWriter that writes lines to out, which writes them to file, and records their offsets
+// in the index indexname
+func newIndexWriter(file *os.File, out io.Writer, indexname string) (*indexWriter, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := os.OpenFile(indexname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	idxinfo, err := idx.Stat()
+	if err == nil && idxinfo.Size() == 0 {
+		_, err = fmt.Fprintf(idx, "%s %d\n", indexHeader, info.Size())
+	}
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return &indexWriter{out: out, idx: idx, offset: info.Size(), minute: -1}, nil
+}
+
+func (w *indexWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	if n == len(p) {
+		if t, level, ok := lineHeader(p, w.location); ok {
+			minute := t.Unix() / 60
+			if minute != w.minute {
+				w.minute = minute
+				w.levels = 0
+			}
+			if w.levels&(1<<uint(level)) == 0 {
+				w.levels |= 1 << uint(level)
+				fmt.Fprintf(w.idx, "%d %d %d\n", minute, level, w.offset)
+			}
+		}
+	}
+	w.offset += int64(n)
+	return n, err
+}
+
+// lineHeader returns the time and level of a line or LF_BINARY frame without parsing all of it. LF_TEXT timestamps
+// without an offset are in location
+func lineHeader(p []byte, location *time.Location) (time.Time, LogLevel, bool) {
+	var stamp, label string
+	var t time.Time
+	var err error
+	if len(p) > 0 && p[0] == binaryMarker {
+		_, n := binary.Uvarint(p[1:])
+		if n <= 0 {
+			return t, 0, false
+		}
+		d := &binaryDecoder{b: p[1+n:]}
+		t = time.Unix(0, d.varint())
+		level := LogLevel(d.byte())
+		return t, level, d.err == nil && level >= LL_TRACE && level <= highestLevel
+	} else if bytes.HasPrefix(p, []byte(`{"time":"`)) {
+		rest := p[len(`{"time":"`):]
+		end := bytes.IndexByte(rest, '"')
+		if end < 0 || !bytes.HasPrefix(rest[end:], []byte(`","level":"`)) {
+			return t, 0, false
+		}
+		stamp = string(rest[:end])
+		rest = rest[end+len(`","level":"`):]
+		if end = bytes.IndexByte(rest, '"'); end < 0 {
+			return t, 0, false
+		}
+		label = string(rest[:end])
+		t, err = time.Parse(time.RFC3339Nano, stamp)
+	} else if bytes.HasPrefix(p, []byte("time=")) {
+		stamp, rest, _ := strings.Cut(string(p[len("time="):]), " ")
+		if !strings.HasPrefix(rest, "level=") {
+			return t, 0, false
+		}
+		label, _, _ = strings.Cut(rest[len("level="):], " ")
+		t, err = time.Parse(time.RFC3339Nano, stamp)
+	} else {
+		head := p
+		if len(head) > 160 {
+			head = head[:160]
+		}
+		var rest string
+		if t, rest, err = cutTextTime(string(head), location); err != nil {
+			return t, 0, false
+		}
+		words := strings.Fields(rest)
+		for len(words) > 0 && (words[0][0] == '+' || words[0][0] == '(') {
+			// elapsed time and delta
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			return t, 0, false
+		}
+		label = words[0]
+	}
+	level, ok := levelFromLabel(label)
+	return t, level, ok && err == nil
+}
+
+// readIndex reads the index of a log segment. It returns false when there is no usable index
+func readIndex(segment string) ([]indexRecord, bool) {
+	fh, err := os.Open(segment + indexSuffix)
+	if err != nil {
+		return nil, false
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	var start int64
+	if !scanner.Scan() {
+		return nil, false
+	}
+	if n, err := fmt.Sscanf(scanner.Text(), indexHeader+" %d", &start); n != 1 || err != nil || start != 0 {
+		return nil, false
+	}
+	var records []indexRecord
+	for scanner.Scan() {
+		var r indexRecord
+		if n, _ := fmt.Sscanf(scanner.Text(), "%d %d %d", &r.minute, &r.level, &r.offset); n == 3 {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err() == nil
+}
+
+// indexRange returns the part of a segment that can hold entries matching q according to its index, assuming the
+// entries are in time order. It returns false when no entry in the segment can match
+func indexRange(records []indexRecord, q Query) (int64, int64, bool) {
+	start, end := int64(-1), int64(-1)
+	for _, r := range records {
+		if !q.Until.IsZero() && r.minute > q.Until.Unix()/60 {
+			end = r.offset
+			break
+		}
+		if start < 0 && r.level.atLeast(q.MinLevel) && (q.Since.IsZero() || r.minute >= q.Since.Unix()/60) {
+			start = r.offset
+		}
+	}
+	return start, end, start >= 0
+}
+
+// seekIndexed positions fh at the start of the range of the segment that can hold entries matching q, and returns a
+// reader that stops at the end of that range. It returns false when the segment holds no matching entries
+func seekIndexed(fh *os.File, segment string, q Query) (io.Reader, bool, error) {
+	records, ok := readIndex(segment)
+	if !ok {
+		return fh, true, nil
+	}
+	start, end, ok := indexRange(records, q)
+	if !ok {
+		return nil, false, nil
+	}
+	if _, err := fh.Seek(start, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	if end >= 0 {
+		return io.LimitReader(fh, end-start), true, nil
+	}
+	return fh, true, nil
+}
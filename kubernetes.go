@@ -0,0 +1,127 @@
+package servicelogger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// serviceAccountNamespace is the file with the namespace of the pod in the service account token volume
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubernetesConfig configures where EnableKubernetesMetadata finds the pod metadata. Zero members get their default
+type KubernetesConfig struct {
+	PodNameEnv   string   // variable with the pod name (fieldRef metadata.name), defaults to "POD_NAME"
+	NamespaceEnv string   // variable with the namespace (fieldRef metadata.namespace), defaults to "POD_NAMESPACE"
+	NodeNameEnv  string   // variable with the node name (fieldRef spec.nodeName), defaults to "NODE_NAME"
+	PodInfoDir   string   // mount path of a Downward API volume, defaults to "/etc/podinfo"
+	Labels       []string // labels attached to entries, all labels in PodInfoDir/labels when empty
+}
+
+// EnableKubernetesMetadata stamps every entry with the pod it was logged in, read once from the Downward API, so that
+// entries shipped from the log file are attributable without the collector adding metadata. The fields are k8s_pod,
+// k8s_namespace, k8s_node and k8s_label_<name> for every label. Every value is taken from the environment variable of
+// the config, or else from the file podname, namespace, nodename or labels in PodInfoDir. The namespace falls back to
+// the service account volume and the pod name to the host name. An error is returned when no pod name or namespace is
+// found, which means the process does not run in Kubernetes. Global fields and fields passed by the caller take
+// precedence. EnableKubernetesMetadata must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableKubernetesMetadata(config KubernetesConfig) error {
+	if config.PodNameEnv == "" {
+		config.PodNameEnv = "POD_NAME"
+	}
+	if config.NamespaceEnv == "" {
+		config.NamespaceEnv = "POD_NAMESPACE"
+	}
+	if config.NodeNameEnv == "" {
+		config.NodeNameEnv = "NODE_NAME"
+	}
+	if config.PodInfoDir == "" {
+		config.PodInfoDir = "/etc/podinfo"
+	}
+	fields := make(map[string]string)
+	namespace := downwardValue(config.NamespaceEnv, filepath.Join(config.PodInfoDir, "namespace"))
+	if namespace == "" {
+		namespace = readTrimmed(serviceAccountNamespace)
+	}
+	pod := downwardValue(config.PodNameEnv, filepath.Join(config.PodInfoDir, "podname"))
+	if pod == "" && namespace != "" {
+		// the host name of a pod is its name, unless the pod spec sets another one
+		pod, _ = os.Hostname()
+	}
+	if pod == "" || namespace == "" {
+		return errors.New("no Kubernetes pod metadata found")
+	}
+	fields["k8s_pod"] = pod
+	fields["k8s_namespace"] = namespace
+	if node := downwardValue(config.NodeNameEnv, filepath.Join(config.PodInfoDir, "nodename")); node != "" {
+		fields["k8s_node"] = node
+	}
+	labels, err := readDownwardLabels(filepath.Join(config.PodInfoDir, "labels"))
+	if err != nil {
+		return err
+	}
+	for name, value := range labels {
+		if len(config.Labels) == 0 || containsString(config.Labels, name) {
+			fields["k8s_label_"+name] = value
+		}
+	}
+	l.kubernetes = fields
+	return nil
+}
+
+// downwardValue returns the value of the environment variable env, or else the contents of file
+func downwardValue(env string, file string) string {
+	if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+		return value
+	}
+	return readTrimmed(file)
+}
+
+// readTrimmed returns the contents of file without surrounding white space, or an empty string when it cannot be read
+func readTrimmed(file string) string {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(contents))
+}
+
+// readDownwardLabels reads a labels file of a Downward API volume, with a line name="value" per label. A missing file
+// has no labels
+func readDownwardLabels(file string) (map[string]string, error) {
+	contents, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		name, quoted, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		labels[name] = value
+	}
+	return labels, scanner.Err()
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
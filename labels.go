@@ -0,0 +1,41 @@
+package servicelogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// levelLabels holds the labels set with SetLevelLabels
+var levelLabels map[LogLevel]string
+
+// SetLevelLabels overrides the labels of levels, e.g. "WARN" instead of "WARNING" in LF_TEXT lines, or the legacy or
+// localized labels existing parsers expect. The labels are used by every format, by LogLevelToString and by
+// StringToLogLevel, which matches them regardless of case, and levels without a label in labels keep their default.
+// LogReader and the other readers accept both the labels and the defaults, but only when the labels are set in the
+// reading process as well. Labels must be unique, must not contain white space or quotes, and must not start with the
+// characters + and ( that start elapsed times. A nil map restores the defaults. SetLevelLabels affects every Logger and
+// must be called before any Logger is used
+func SetLevelLabels(labels map[LogLevel]string) error {
+	if len(labels) == 0 {
+		levelLabels = nil
+		return nil
+	}
+	seen := make(map[string]LogLevel, len(labels))
+	for level, label := range labels {
+		if level < LL_TRACE || level > highestLevel {
+			return fmt.Errorf("unknown level %d", level)
+		}
+		if label == "" || strings.ContainsAny(label, " \t\r\n\"'=") || label[0] == '+' || label[0] == '(' {
+			return fmt.Errorf("invalid label %q for %s", label, defaultLevelLabel(level))
+		}
+		if other, ok := seen[strings.ToUpper(label)]; ok {
+			return fmt.Errorf("label %q is used for both %s and %s", label, defaultLevelLabel(other), defaultLevelLabel(level))
+		}
+		seen[strings.ToUpper(label)] = level
+	}
+	levelLabels = make(map[LogLevel]string, len(labels))
+	for level, label := range labels {
+		levelLabels[level] = label
+	}
+	return nil
+}
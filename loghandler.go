@@ -0,0 +1,125 @@
+package servicelogger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogHandlerConfig configures the handler returned by LogHandler. Zero members get their default
+type LogHandlerConfig struct {
+	Authorize func(r *http.Request) bool // decides whether a request may read the log, every request is allowed when nil
+	Limit     int                        // maximum number of entries served, defaults to 1000
+}
+
+// LogHandler returns an http.Handler that serves recent entries, e.g. when mounted at /debug/logs. Entries come from the
+// ring buffer when it is enabled, and from the log file and its rotated segments otherwise. The query parameters are:
+//   - level: lowest level served, e.g. error
+//   - since, until: a duration ago such as 10m, or an RFC3339 time
+//   - facility: facility prefix, e.g. delta.db
+//   - limit: maximum number of entries, capped at the configured limit
+//   - format: json for a JSON array of entries, text for the lines of the log file. Defaults to json when the Accept
+//     header asks for it, text otherwise
+func (l *Logger) LogHandler(config LogHandlerConfig) http.Handler {
+	if config.Limit <= 0 {
+		config.Limit = 1000
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.Authorize != nil && !config.Authorize(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		q, err := parseLogQuery(r, config.Limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var entries []Entry
+		if l.ring != nil {
+			for _, e := range l.RecentEntries(len(l.ring.entries)) {
+				if q.Matches(&e) {
+					entries = append(entries, e)
+				}
+			}
+			if len(entries) > q.Limit {
+				entries = entries[len(entries)-q.Limit:]
+			}
+		} else if l.filename != "" {
+			entries, err = l.Reader().Query(q)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" && strings.Contains(r.Header.Get("Accept"), "application/json") {
+			format = "json"
+		}
+		var b strings.Builder
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			b.WriteString("[")
+			for n, e := range entries {
+				if n > 0 {
+					b.WriteString(",\n")
+				}
+				b.WriteString(strings.TrimSuffix(formatJSON(&e, nil), "\n"))
+			}
+			b.WriteString("]\n")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, e := range entries {
+				b.WriteString(formatEntry(&e, LF_TEXT, SM_ESCAPE))
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte(b.String()))
+	})
+}
+
+// parseLogQuery builds a Query from the query parameters of a LogHandler request
+func parseLogQuery(r *http.Request, limit int) (Query, error) {
+	params := r.URL.Query()
+	q := Query{Facility: params.Get("facility"), Limit: limit}
+	if level := params.Get("level"); level != "" {
+		var ok bool
+		q.MinLevel, ok = levelFromLabel(strings.ToUpper(level))
+		if !ok {
+			return q, fmt.Errorf("unknown level %q", level)
+		}
+	}
+	var err error
+	if q.Since, err = parseQueryTime(params.Get("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseQueryTime(params.Get("until")); err != nil {
+		return q, err
+	}
+	if s := params.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("invalid limit %q", s)
+		}
+		if n < limit {
+			q.Limit = n
+		}
+	}
+	return q, nil
+}
+
+// parseQueryTime parses a duration ago, such as 10m, or an RFC3339 time. An empty string is the zero time
+func parseQueryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q, use a duration like 10m or an RFC3339 time", s)
+	}
+	return t, nil
+}
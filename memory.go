@@ -0,0 +1,70 @@
+package servicelogger
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+)
+
+// memoryBuffer is a goroutine-safe in-memory destination for log output
+type memoryBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (m *memoryBuffer) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buf.Write(p)
+}
+
+func (m *memoryBuffer) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buf.String()
+}
+
+func (m *memoryBuffer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buf.Reset()
+}
+
+// NewMemory returns a new Logger that writes to an in-memory buffer instead of a file. It never touches the filesystem
+// and is intended for unit tests of code that depends on *Logger
+func NewMemory(minloglevel LogLevel) *Logger {
+	l := &Logger{loggerState: &loggerState{
+		MinLoglevel: minloglevel,
+		memory:      &memoryBuffer{},
+		sanitize:    SM_ESCAPE,
+		format:      LF_TEXT,
+		stats:       &loggerStats{},
+	}}
+	l.base = log.New(l.memory, "", 0)
+	return l
+}
+
+// MemoryContents returns everything written to an in-memory Logger. For file based Loggers an empty string is returned
+func (l *Logger) MemoryContents() string {
+	if l.memory == nil {
+		return ""
+	}
+	return l.memory.String()
+}
+
+// MemoryLines returns the lines written to an in-memory Logger, without trailing newlines
+func (l *Logger) MemoryLines() []string {
+	contents := strings.TrimSuffix(l.MemoryContents(), "\n")
+	if contents == "" {
+		return nil
+	}
+	return strings.Split(contents, "\n")
+}
+
+// ResetMemory discards everything written to an in-memory Logger so far
+func (l *Logger) ResetMemory() {
+	if l.memory != nil {
+		l.memory.Reset()
+	}
+}
@@ -0,0 +1,60 @@
+package servicelogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+type MultilineMode int
+
+const (
+	MM_ESCAPE MultilineMode = 1 // newlines are escaped as \n according to the sanitize mode, the default
+	MM_INDENT MultilineMode = 2 // every further line of the message is written on a line of its own after a marker
+	MM_JSON   MultilineMode = 3 // an entry with a multi-line message is written as a single line in LF_JSON format
+)
+
+// defaultContinuation is the marker of continuation lines unless SetMultiline provides one
+const defaultContinuation = "\t| "
+
+// SetMultiline sets how messages that contain newlines, such as stack traces and SQL statements, are written in LF_TEXT
+// format, to the log file and to the outputs of AddOutput. MM_ESCAPE keeps every entry on one line. MM_INDENT writes
+// every further line of the message on a line of its own, sanitized and preceded by marker, which must start with a
+// space or a tab and defaults to "\t| "; fields follow the last line. MM_JSON writes these entries as LF_JSON lines
+// among the LF_TEXT lines. LogReader of the Logger reads all of them back as single entries; other readers only
+// recognize the default marker. SetMultiline must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetMultiline(mode MultilineMode, marker string) error {
+	if mode < MM_ESCAPE || mode > MM_JSON {
+		return fmt.Errorf("unknown multiline mode %d", mode)
+	}
+	if marker == "" {
+		marker = defaultContinuation
+	}
+	if marker[0] != ' ' && marker[0] != '\t' || strings.ContainsAny(marker, "\r\n") {
+		return fmt.Errorf("invalid continuation marker %q, it must start with a space or a tab", marker)
+	}
+	l.multiline = mode
+	l.continuation = marker
+	return nil
+}
+
+// formatMultiline returns the line for an entry with a multi-line message in LF_TEXT format and the multiline mode
+func (l *Logger) formatMultiline(e *Entry) string {
+	if l.multiline == MM_JSON {
+		return formatJSON(e, l.timestamps)
+	}
+	lines := strings.Split(e.Message, "\n")
+	first := *e
+	first.Message = strings.TrimSuffix(lines[0], "\r")
+	first.Fields = nil
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(formatText(&first, l.sanitize, l.layout(), l.timestamps), "\n"))
+	for _, line := range lines[1:] {
+		b.WriteString("\n" + l.continuation)
+		b.WriteString(sanitizeString(l.sanitize, strings.TrimSuffix(line, "\r")))
+	}
+	if len(e.Fields) > 0 {
+		b.WriteString("\t" + formatFields(e.Fields))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
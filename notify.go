@@ -0,0 +1,233 @@
+package servicelogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NotifierStats holds the counters of a Notifier
+type NotifierStats struct {
+	Sent      uint64 // notifications delivered
+	Retried   uint64 // delivery attempts that were repeated after a failure
+	Throttled uint64 // matching entries that were not notified because of throttling
+	Dropped   uint64 // notifications dropped because the queue was full
+	Failed    uint64 // notifications that could not be delivered
+}
+
+// Notifier delivers notifications about log entries from a background goroutine, so that a slow destination never
+// blocks logging. Notifications about FATAL entries are delivered before LogFatal exits
+type Notifier struct {
+	deliver   func(n notification) error
+	retry     RetryPolicy
+	queue     chan notification
+	stopped   chan struct{}
+	mu        sync.Mutex
+	closed    bool
+	sent      atomic.Uint64
+	retried   atomic.Uint64
+	throttled atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// notification is what a Notifier delivers: one or more entries, plus the number of similar entries that were
+// suppressed by throttling since the previous notification
+type notification struct {
+	entries    []Entry
+	suppressed int
+}
+
+// newNotifier starts a Notifier that is drained by Shutdown, retrying failed deliveries according to retry
+func (l *Logger) newNotifier(queueSize int, retry RetryPolicy, deliver func(n notification) error) *Notifier {
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	n := &Notifier{
+		deliver: deliver,
+		retry:   retry,
+		queue:   make(chan notification, queueSize),
+		stopped: make(chan struct{}),
+	}
+	go n.run(n.queue)
+	l.drainers = append(l.drainers, n)
+	return n
+}
+
+// Stats returns the counters of the Notifier
+func (n *Notifier) Stats() NotifierStats {
+	return NotifierStats{Sent: n.sent.Load(), Retried: n.retried.Load(), Throttled: n.throttled.Load(), Dropped: n.dropped.Load(), Failed: n.failed.Load()}
+}
+
+// Close delivers the queued notifications and stops the Notifier. Entries logged after Close are not notified
+func (n *Notifier) Close() {
+	_, _ = n.Drain(context.Background())
+}
+
+// Drain delivers the queued notifications and stops the Notifier like Close, but gives up when ctx is done. It returns
+// the number of notifications that were not delivered, which are counted as dropped
+func (n *Notifier) Drain(ctx context.Context) (int, error) {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+	select {
+	case <-n.stopped:
+		return 0, nil
+	case <-ctx.Done():
+		left := len(n.queue)
+		n.dropped.Add(uint64(left))
+		return left, ctx.Err()
+	}
+}
+
+func (n *Notifier) run(queue chan notification) {
+	defer close(n.stopped)
+	for no := range queue {
+		n.send(no)
+	}
+}
+
+func (n *Notifier) send(no notification) {
+	attempts, err := n.retry.retry(context.Background(), func() error {
+		return n.deliver(no)
+	})
+	n.retried.Add(uint64(attempts - 1))
+	if err != nil {
+		n.failed.Add(1)
+		return
+	}
+	n.sent.Add(1)
+}
+
+// submit queues a notification, or delivers it right away when it is about a FATAL entry
+func (n *Notifier) submit(no notification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		n.dropped.Add(1)
+		return
+	}
+	for _, e := range no.entries {
+		if e.Level == LL_FATAL {
+			n.send(no)
+			return
+		}
+	}
+	select {
+	case n.queue <- no:
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// throttle limits notifications to one per interval per key, counting what it suppressed in between
+type throttle struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+func newThrottle(interval time.Duration) *throttle {
+	return &throttle{interval: interval, last: make(map[string]time.Time), suppressed: make(map[string]int)}
+}
+
+// allow reports whether a notification for key may be sent now, and how many were suppressed since the previous one
+func (t *throttle) allow(key string, now time.Time) (bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.last[key]; ok && now.Sub(last) < t.interval {
+		t.suppressed[key]++
+		return false, 0
+	}
+	t.last[key] = now
+	suppressed := t.suppressed[key]
+	delete(t.suppressed, key)
+	return true, suppressed
+}
+
+// entryWindow collects the entries of a sliding time window and hands them out once there are more than threshold
+type entryWindow struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	entries   []Entry
+}
+
+func newEntryWindow(threshold int, window time.Duration) *entryWindow {
+	return &entryWindow{threshold: threshold, window: window}
+}
+
+// add records e and, when the window now holds more than threshold entries, returns them and starts over
+func (w *entryWindow) add(e *Entry) []Entry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	start := 0
+	for start < len(w.entries) && e.Time.Sub(w.entries[start].Time) > w.window {
+		start++
+	}
+	w.entries = append(w.entries[start:], *e)
+	if len(w.entries) <= w.threshold {
+		return nil
+	}
+	exceeded := w.entries
+	w.entries = nil
+	return exceeded
+}
+
+// facilityMatches reports whether facility starts with one of the prefixes. An empty list matches every facility
+func facilityMatches(prefixes []string, facility string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(facility, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// postJSON posts payload as JSON to url and returns an error unless the response status is 2xx. Errors for rejected
+// requests are permanent, except for 408 and 429 which ask the client to try again later
+func postJSON(client *http.Client, url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return responseError(resp)
+}
+
+// responseError returns nil for a 2xx response, and otherwise an error that is permanent when retrying cannot help
+func responseError(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err := fmt.Errorf("unexpected response status %s", resp.Status)
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
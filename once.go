@@ -0,0 +1,55 @@
+package servicelogger
+
+import (
+	"sync"
+	"time"
+)
+
+// onceKeys remembers the keys of LogOnce and LogEvery. Keys are never forgotten, so they should come from a small set
+// such as constants
+type onceKeys struct {
+	mu    sync.Mutex
+	once  map[string]struct{}
+	every map[string]time.Time
+}
+
+// LogOnce logs a message at the provided level the first time it is called with key, and ignores later calls with the
+// same key, e.g. for a condition that is checked in a tight loop. It reports whether the message was due. Unlike
+// LogFatal it never exits the application
+func (l *Logger) LogOnce(key string, level LogLevel, function string, source string, text string) bool {
+	l.onceKeys.mu.Lock()
+	_, seen := l.onceKeys.once[key]
+	if !seen {
+		if l.onceKeys.once == nil {
+			l.onceKeys.once = make(map[string]struct{})
+		}
+		l.onceKeys.once[key] = struct{}{}
+	}
+	l.onceKeys.mu.Unlock()
+	if seen {
+		return false
+	}
+	l.log(level, function, source, text, nil)
+	return true
+}
+
+// LogEvery logs a message at the provided level at most once per interval for every key, and ignores the calls in
+// between. It reports whether the message was due. Unlike LogFatal it never exits the application
+func (l *Logger) LogEvery(key string, interval time.Duration, level LogLevel, function string, source string, text string) bool {
+	now := l.now()
+	l.onceKeys.mu.Lock()
+	last, seen := l.onceKeys.every[key]
+	due := !seen || now.Sub(last) >= interval
+	if due {
+		if l.onceKeys.every == nil {
+			l.onceKeys.every = make(map[string]time.Time)
+		}
+		l.onceKeys.every[key] = now
+	}
+	l.onceKeys.mu.Unlock()
+	if !due {
+		return false
+	}
+	l.log(level, function, source, text, nil)
+	return true
+}
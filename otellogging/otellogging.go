@@ -0,0 +1,48 @@
+// Package otellogging - OpenTelemetry integration for servicelogger
+package otellogging
+
+import (
+	"github.com/quadtrix/servicelogger"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceInterceptor adds trace_id and span_id fields to entries logged through a child logger whose context carries a
+// valid OpenTelemetry span, so that log entries can be correlated with traces. Register it with AddInterceptor
+func TraceInterceptor(e *servicelogger.Entry) bool {
+	if e.Context == nil {
+		return true
+	}
+	sc := trace.SpanContextFromContext(e.Context)
+	if !sc.IsValid() {
+		return true
+	}
+	e.SetField("trace_id", sc.TraceID().String())
+	e.SetField("span_id", sc.SpanID().String())
+	return true
+}
+
+// BaggageInterceptor returns an interceptor that copies the OpenTelemetry baggage members named by keys, such as
+// tenant_id or session_id, from the context of entries logged through a child logger into fields of the same name, so
+// that identifiers propagated across services appear in the logs without every call site extracting them. Fields
+// already set on the entry are kept. Register it with AddInterceptor
+func BaggageInterceptor(keys ...string) servicelogger.Interceptor {
+	return func(e *servicelogger.Entry) bool {
+		if e.Context == nil {
+			return true
+		}
+		b := baggage.FromContext(e.Context)
+		if b.Len() == 0 {
+			return true
+		}
+		for _, key := range keys {
+			if _, ok := e.Fields[key]; ok {
+				continue
+			}
+			if value := b.Member(key).Value(); value != "" {
+				e.SetField(key, value)
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,540 @@
+package otellogging
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// exportMethod is the gRPC method of the OTLP logs service
+const exportMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// scopeName is the instrumentation scope of the exported log records
+const scopeName = "github.com/quadtrix/servicelogger"
+
+// severities maps the levels to OTLP severity numbers
+var severities = map[servicelogger.LogLevel]uint64{
+	servicelogger.LL_TRACE: 1,
+	servicelogger.LL_DEBUG: 5,
+	servicelogger.LL_INFO:  9,
+	servicelogger.LL_WARN:  13,
+	servicelogger.LL_ERROR: 17,
+	servicelogger.LL_PANIC: 21,
+	servicelogger.LL_FATAL: 24,
+}
+
+// OTLPConfig configures an exporter returned by NewOTLPExporter. Zero members get their default
+type OTLPConfig struct {
+	Endpoint  string                    // host and port of the collector, such as "otel-collector:4317"
+	Insecure  bool                      // connect without TLS, e.g. to a collector on the same host
+	TLS       *tls.Config               // TLS settings of the connection, the system roots when nil
+	Headers   map[string]string         // metadata sent with every export, e.g. an API key
+	Resource  map[string]interface{}    // resource attributes, service.name defaults to the name of the program
+	Gzip      bool                      // compress the export requests
+	Timeout   time.Duration             // timeout of an export attempt, defaults to 10 seconds
+	Retry     servicelogger.RetryPolicy // retry policy of every batch
+	Batch     servicelogger.BatchConfig // when batches are exported, with the defaults of the HTTP sink
+	QueueSize int                       // entries waiting to be batched before new ones are dropped, defaults to 10000
+}
+
+// OTLPStats holds the counters of an OTLPExporter
+type OTLPStats struct {
+	Exported uint64 // entries accepted by the collector
+	Batches  uint64 // export requests that succeeded
+	Retried  uint64 // export attempts that were repeated after a failure
+	Dropped  uint64 // entries dropped because the queue was full or the exporter was drained
+	Failed   uint64 // entries in batches that could not be exported
+	Rejected uint64 // entries the collector reported as rejected in a partial success
+}
+
+// OTLPExporter is a servicelogger.Sink that exports entries as OpenTelemetry log records to a collector over OTLP/gRPC
+type OTLPExporter struct {
+	config   OTLPConfig
+	conn     *grpc.ClientConn
+	options  []grpc.CallOption
+	metadata metadata.MD
+	resource []byte
+	mu       sync.Mutex
+	closed   bool
+	queue    chan []byte
+	stopped  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	exported atomic.Uint64
+	batches  atomic.Uint64
+	retried  atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewOTLPExporter returns an OTLPExporter that exports to the collector at config.Endpoint, so that a service can take
+// part in an OpenTelemetry Collector pipeline without a file-tailing receiver. Add it with AddSink. Entries are
+// exported in batches like those of servicelogger.HTTPSink, as log records with the message as body, the prefix,
+// source, function, facility and fields as attributes, and the trace and span of the span in the context of the entry,
+// or of its trace_id and span_id fields. Failed exports are retried according to the policy when the status code is
+// one the OTLP specification calls retryable. The connection is established in the background, so the collector does
+// not need to be up yet
+func NewOTLPExporter(config OTLPConfig) (*OTLPExporter, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.Batch.MaxEntries <= 0 {
+		config.Batch.MaxEntries = 100
+	}
+	if config.Batch.MaxBytes <= 0 {
+		config.Batch.MaxBytes = 1 << 20
+	}
+	if config.Batch.MaxAge <= 0 {
+		config.Batch.MaxAge = time.Second
+	}
+	creds := insecure.NewCredentials()
+	if !config.Insecure {
+		tlsConfig := config.TLS
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(config.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	x := &OTLPExporter{
+		config:   config,
+		conn:     conn,
+		options:  []grpc.CallOption{grpc.ForceCodec(rawCodec{})},
+		metadata: metadata.New(config.Headers),
+		resource: encodeResource(config.Resource),
+		queue:    make(chan []byte, config.QueueSize),
+		stopped:  make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	if config.Gzip {
+		x.options = append(x.options, grpc.UseCompressor(gzip.Name))
+	}
+	go x.run()
+	return x, nil
+}
+
+// WriteEntry queues e for the next batch. It returns an error when the entry had to be dropped
+func (x *OTLPExporter) WriteEntry(e *servicelogger.Entry) error {
+	record := encodeLogRecord(e)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.closed {
+		x.dropped.Add(1)
+		return errors.New("OTLP exporter is drained")
+	}
+	select {
+	case x.queue <- record:
+		return nil
+	default:
+		x.dropped.Add(1)
+		return errors.New("OTLP exporter queue is full")
+	}
+}
+
+// Stats returns the counters of the exporter
+func (x *OTLPExporter) Stats() OTLPStats {
+	return OTLPStats{
+		Exported: x.exported.Load(),
+		Batches:  x.batches.Load(),
+		Retried:  x.retried.Load(),
+		Dropped:  x.dropped.Load(),
+		Failed:   x.failed.Load(),
+		Rejected: x.rejected.Load(),
+	}
+}
+
+// Drain exports the queued entries and closes the connection, giving up when ctx is done. It returns the number of
+// queued entries that were not exported, which are counted as dropped
+func (x *OTLPExporter) Drain(ctx context.Context) (int, error) {
+	x.mu.Lock()
+	if !x.closed {
+		x.closed = true
+		close(x.queue)
+	}
+	x.mu.Unlock()
+	select {
+	case <-x.stopped:
+		return 0, nil
+	case <-ctx.Done():
+		x.cancel()
+		left := len(x.queue)
+		x.dropped.Add(uint64(left))
+		return left, ctx.Err()
+	}
+}
+
+// Close exports the queued entries and closes the connection
+func (x *OTLPExporter) Close() error {
+	_, err := x.Drain(context.Background())
+	return err
+}
+
+func (x *OTLPExporter) run() {
+	defer close(x.stopped)
+	defer x.conn.Close()
+	var records []byte
+	count := 0
+	limits := x.config.Batch
+	timer := time.NewTimer(limits.MaxAge)
+	timer.Stop()
+	flush := func() {
+		if count > 0 {
+			x.export(records, count)
+		}
+		records = records[:0]
+		count = 0
+	}
+	stop := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+	for x.ctx.Err() == nil {
+		var due <-chan time.Time
+		if count > 0 {
+			due = timer.C
+		}
+		select {
+		case record, ok := <-x.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if count > 0 && len(records)+len(record) > limits.MaxBytes {
+				stop()
+				flush()
+			}
+			if count == 0 {
+				timer.Reset(limits.MaxAge)
+			}
+			records = protowire.AppendTag(records, 2, protowire.BytesType)
+			records = protowire.AppendBytes(records, record)
+			count++
+			if count >= limits.MaxEntries || len(records) >= limits.MaxBytes {
+				stop()
+				flush()
+			}
+		case <-due:
+			flush()
+		}
+	}
+	// abandoned by Drain, which counted the queued entries
+	x.dropped.Add(uint64(count))
+}
+
+// export sends the log records of a batch of count entries
+func (x *OTLPExporter) export(records []byte, count int) {
+	request := encodeRequest(x.resource, records)
+	attempts, err := x.config.Retry.Do(x.ctx, func() error {
+		ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(x.ctx, x.metadata), x.config.Timeout)
+		defer cancel()
+		var response []byte
+		err := x.conn.Invoke(ctx, exportMethod, request, &response, x.options...)
+		if err != nil {
+			return classify(err)
+		}
+		x.rejected.Add(rejectedRecords(response))
+		return nil
+	})
+	x.retried.Add(uint64(attempts - 1))
+	if err != nil {
+		x.failed.Add(uint64(count))
+		return
+	}
+	x.exported.Add(uint64(count))
+	x.batches.Add(1)
+}
+
+// classify marks the errors of status codes the OTLP specification does not call retryable as permanent
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss,
+		codes.ResourceExhausted:
+		return err
+	default:
+		return servicelogger.Permanent(err)
+	}
+}
+
+// rawCodec passes already encoded protobuf messages to gRPC and returns responses undecoded
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// encodeRequest returns an ExportLogsServiceRequest with a single resource and scope holding the encoded records
+func encodeRequest(resource []byte, records []byte) []byte {
+	var scope []byte
+	scope = appendString(scope, 1, scopeName)
+	var scopeLogs []byte
+	scopeLogs = appendMessage(scopeLogs, 1, scope)
+	scopeLogs = append(scopeLogs, records...)
+	var resourceLogs []byte
+	resourceLogs = appendMessage(resourceLogs, 1, resource)
+	resourceLogs = appendMessage(resourceLogs, 2, scopeLogs)
+	return appendMessage(nil, 1, resourceLogs)
+}
+
+// encodeResource returns the Resource message for the attributes, with service.name set to the name of the program
+// unless they hold one
+func encodeResource(attributes map[string]interface{}) []byte {
+	if _, ok := attributes["service.name"]; !ok {
+		withName := make(map[string]interface{}, len(attributes)+1)
+		for key, value := range attributes {
+			withName[key] = value
+		}
+		withName["service.name"] = filepath.Base(os.Args[0])
+		attributes = withName
+	}
+	return appendAttributes(nil, 1, attributes)
+}
+
+// encodeLogRecord returns the LogRecord message for e
+func encodeLogRecord(e *servicelogger.Entry) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(e.Time.UnixNano()))
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, severities[e.Level])
+	b = appendString(b, 3, servicelogger.LogLevelToString(e.Level))
+	b = appendMessage(b, 5, anyValue(e.Message))
+	attributes := map[string]interface{}{
+		"prefix":   e.Prefix,
+		"source":   e.Source,
+		"function": e.Function,
+		"facility": e.Facility(),
+	}
+	for key, value := range e.Fields {
+		attributes[key] = value
+	}
+	traceID, spanID, flags := entryTrace(e)
+	if traceID != nil {
+		delete(attributes, "trace_id")
+		delete(attributes, "span_id")
+	}
+	b = appendAttributes(b, 6, attributes)
+	if traceID != nil {
+		b = protowire.AppendTag(b, 8, protowire.Fixed32Type)
+		b = protowire.AppendFixed32(b, flags)
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendBytes(b, traceID)
+		if spanID != nil {
+			b = protowire.AppendTag(b, 10, protowire.BytesType)
+			b = protowire.AppendBytes(b, spanID)
+		}
+	}
+	b = protowire.AppendTag(b, 11, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(time.Now().UnixNano()))
+}
+
+// entryTrace returns the trace ID, span ID and trace flags of the span in the context of e, or else of its trace_id
+// and span_id fields. The trace ID is nil when there is neither
+func entryTrace(e *servicelogger.Entry) ([]byte, []byte, uint32) {
+	if e.Context != nil {
+		if sc := trace.SpanContextFromContext(e.Context); sc.IsValid() {
+			traceID, spanID := sc.TraceID(), sc.SpanID()
+			return traceID[:], spanID[:], uint32(sc.TraceFlags())
+		}
+	}
+	traceID := hexField(e.Fields, "trace_id", 16)
+	if traceID == nil {
+		return nil, nil, 0
+	}
+	return traceID, hexField(e.Fields, "span_id", 8), 0
+}
+
+// hexField decodes a field holding size bytes in hexadecimal, returning nil when it does not
+func hexField(fields map[string]interface{}, key string, size int) []byte {
+	text, ok := fields[key].(string)
+	if !ok || len(text) != 2*size {
+		return nil
+	}
+	b, err := hex.DecodeString(text)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// rejectedRecords returns the rejected_log_records of the partial success in an ExportLogsServiceResponse
+func rejectedRecords(response []byte) uint64 {
+	partial := field(response, 1)
+	for len(partial) > 0 {
+		num, typ, n := protowire.ConsumeTag(partial)
+		if n < 0 {
+			return 0
+		}
+		partial = partial[n:]
+		if num == 1 && typ == protowire.VarintType {
+			v, _ := protowire.ConsumeVarint(partial)
+			return v
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, partial); n < 0 {
+			return 0
+		}
+		partial = partial[n:]
+	}
+	return 0
+}
+
+// field returns the contents of the first length-delimited field num of a message, nil when there is none
+func field(message []byte, num protowire.Number) []byte {
+	for len(message) > 0 {
+		n, typ, length := protowire.ConsumeTag(message)
+		if length < 0 {
+			return nil
+		}
+		message = message[length:]
+		if n == num && typ == protowire.BytesType {
+			v, _ := protowire.ConsumeBytes(message)
+			return v
+		}
+		if length = protowire.ConsumeFieldValue(n, typ, message); length < 0 {
+			return nil
+		}
+		message = message[length:]
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// appendAttributes appends the attributes as KeyValue messages of field num, sorted by key
+func appendAttributes(b []byte, num protowire.Number, attributes map[string]interface{}) []byte {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var kv []byte
+		kv = appendString(kv, 1, key)
+		kv = appendMessage(kv, 2, anyValue(attributes[key]))
+		b = appendMessage(b, num, kv)
+	}
+	return b
+}
+
+// anyValue returns the AnyValue message for a value. Strings, booleans, numbers and byte slices keep their type,
+// errors and values with a String method are rendered as text, and anything else falls back to its fmt representation
+func anyValue(value interface{}) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case string:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case int:
+		return appendInt(b, int64(v))
+	case int8:
+		return appendInt(b, int64(v))
+	case int16:
+		return appendInt(b, int64(v))
+	case int32:
+		return appendInt(b, int64(v))
+	case int64:
+		return appendInt(b, v)
+	case uint8:
+		return appendInt(b, int64(v))
+	case uint16:
+		return appendInt(b, int64(v))
+	case uint32:
+		return appendInt(b, int64(v))
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return appendInt(b, int64(v))
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return appendInt(b, int64(v))
+		}
+	case float32:
+		return appendDouble(b, float64(v))
+	case float64:
+		return appendDouble(b, v)
+	case []byte:
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	case error:
+		return anyValue(v.Error())
+	case fmt.Stringer:
+		return anyValue(v.String())
+	}
+	return anyValue(fmt.Sprint(value))
+}
+
+func appendInt(b []byte, v int64) []byte {
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendDouble(b []byte, v float64) []byte {
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
@@ -0,0 +1,99 @@
+package servicelogger
+
+import (
+	"io"
+	"sync"
+)
+
+// redirect is a writer that replaces the log file after SetOutput
+type redirect struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetOutput writes the lines of the Logger to w instead of to the log file from now on, e.g. to os.Stderr during live
+// troubleshooting, and SetOutput(nil) switches back to the log file. Lines being written when SetOutput is called end
+// up in the old destination, and every later line, including the queued lines of an asynchronous Logger, in the new
+// one, so no entries are lost. While redirected the log file is neither written nor rotated, and the index, errors
+// file, audit log and sinks are not affected. Writes to w are serialized. SetOutput may be called while the Logger is
+// in use
+func (l *Logger) SetOutput(w io.Writer) {
+	if w == nil {
+		l.redirect.Store(nil)
+		return
+	}
+	l.flushBuffer()
+	l.redirect.Store(&redirect{w: w})
+}
+
+// writeRedirected writes line to the writer of SetOutput and reports whether the Logger is redirected
+func (l *Logger) writeRedirected(line string) (bool, error) {
+	r := l.redirect.Load()
+	if r == nil {
+		return false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := io.WriteString(r.w, line)
+	return true, err
+}
+
+// OutputConfig configures an output added with AddOutput. Zero members get their default
+type OutputConfig struct {
+	Format   LogFormat   // format of the lines, defaults to LF_TEXT
+	MinLevel LogLevel    // lowest level written, every level that passes the filters of the Logger when zero
+	Fields   FieldFilter // fields written
+}
+
+// output is an additional destination of the lines of a Logger
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	config OutputConfig
+}
+
+// AddOutput adds a destination that receives the line of every entry written to the log file, in a format of its own,
+// e.g. JSON to stdout next to a text log file. Every entry is encoded once per format: outputs with the same format
+// and no field filter share the line, and share the line of the log file when it has that format and no field filter
+// either. Lines are written after the log file and before the sinks, so every destination sees the entries in the same
+// order. Writes to w are serialized, and failed writes are counted in Stats. AddOutput must be called before the Logger
+// is used from multiple goroutines
+func (l *Logger) AddOutput(w io.Writer, config OutputConfig) {
+	if config.Format == 0 {
+		config.Format = LF_TEXT
+	}
+	l.outputs = append(l.outputs, &output{w: w, config: config})
+}
+
+// writeOutputs writes e to every output that accepts its level, encoding it once per format. line is the line written
+// to the log file
+func (l *Logger) writeOutputs(e *Entry, line string) {
+	var lines map[LogFormat]string
+	for _, o := range l.outputs {
+		if !e.Level.atLeast(o.config.MinLevel) {
+			continue
+		}
+		var text string
+		if filtered := o.config.Fields; len(filtered.Allow) > 0 || len(filtered.Deny) > 0 {
+			text = l.formatEntryAs(filtered.apply(e), o.config.Format)
+		} else {
+			if lines == nil {
+				lines = make(map[LogFormat]string, 2)
+				if len(l.fileFields.Allow) == 0 && len(l.fileFields.Deny) == 0 {
+					lines[l.format] = line
+				}
+			}
+			var ok bool
+			if text, ok = lines[o.config.Format]; !ok {
+				text = l.formatEntryAs(e, o.config.Format)
+				lines[o.config.Format] = text
+			}
+		}
+		o.mu.Lock()
+		_, err := io.WriteString(o.w, text)
+		o.mu.Unlock()
+		if err != nil && l.stats != nil {
+			l.stats.outputFailed.Add(1)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package servicelogger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// PagerDutyConfig configures incidents triggered through the PagerDuty Events API v2. Zero members get their default
+type PagerDutyConfig struct {
+	RoutingKey     string        // integration key of the PagerDuty service
+	URL            string        // Events API endpoint, defaults to https://events.pagerduty.com/v2/enqueue
+	Source         string        // source reported with the incidents, defaults to the host name
+	Facilities     []string      // facility prefixes that can trigger incidents, all facilities when empty
+	ErrorThreshold int           // number of ERROR entries of a facility within ErrorWindow that is tolerated, 0 only pages on FATAL
+	ErrorWindow    time.Duration // length of the sliding window for ErrorThreshold, defaults to 5 minutes
+	Throttle       time.Duration // minimum time between two triggers with the same dedup key, defaults to 5 minutes
+	Timeout        time.Duration // timeout of a single request, defaults to 10 seconds
+	Retry          RetryPolicy   // retry policy of failed requests
+}
+
+// EnablePagerDuty triggers a PagerDuty incident for every FATAL entry, and for every facility that logs more than
+// ErrorThreshold ERROR entries within ErrorWindow. Dedup keys are derived from the facility and message, so PagerDuty
+// groups repeated triggers for the same problem into one incident
+func (l *Logger) EnablePagerDuty(config PagerDutyConfig) (*Notifier, error) {
+	if config.RoutingKey == "" {
+		return nil, errors.New("PagerDuty routing key is required")
+	}
+	if config.URL == "" {
+		config.URL = "https://events.pagerduty.com/v2/enqueue"
+	}
+	if config.Source == "" {
+		config.Source, _ = os.Hostname()
+	}
+	if config.ErrorWindow <= 0 {
+		config.ErrorWindow = 5 * time.Minute
+	}
+	if config.Throttle <= 0 {
+		config.Throttle = 5 * time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: config.Timeout}
+	n := l.newNotifier(0, config.Retry, func(no notification) error {
+		return postPagerDuty(client, config, no)
+	})
+	t := newThrottle(config.Throttle)
+	var mu sync.Mutex
+	windows := make(map[string]*entryWindow)
+	l.AddBeforeWriteHook(func(e *Entry) {
+		if !e.Level.atLeast(LL_ERROR) || !facilityMatches(config.Facilities, e.Facility()) {
+			return
+		}
+		var entries []Entry
+		if e.Level == LL_FATAL {
+			entries = []Entry{*e}
+		} else if config.ErrorThreshold > 0 {
+			mu.Lock()
+			w, ok := windows[e.Facility()]
+			if !ok {
+				w = newEntryWindow(config.ErrorThreshold, config.ErrorWindow)
+				windows[e.Facility()] = w
+			}
+			mu.Unlock()
+			entries = w.add(e)
+		}
+		if entries == nil {
+			return
+		}
+		ok, suppressed := t.allow(pagerDutyDedupKey(entries), e.Time)
+		if !ok {
+			n.throttled.Add(1)
+			return
+		}
+		n.submit(notification{entries: entries, suppressed: suppressed})
+	})
+	return n, nil
+}
+
+// pagerDutyDedupKey returns the dedup key of an incident: a hash of the facility and message of a single entry, or of
+// the facility alone for an error rate incident
+func pagerDutyDedupKey(entries []Entry) string {
+	e := entries[0]
+	key := e.Facility() + "\n"
+	if len(entries) == 1 {
+		key += e.Message
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func postPagerDuty(client *http.Client, config PagerDutyConfig, no notification) error {
+	e := no.entries[len(no.entries)-1]
+	severity := "critical"
+	summary := fmt.Sprintf("%s in %s: %s", LogLevelToString(e.Level), e.Facility(), e.Message)
+	details := make(map[string]interface{}, len(e.Fields)+2)
+	for key, value := range e.Fields {
+		details[key] = fmt.Sprint(value)
+	}
+	if len(no.entries) > 1 {
+		severity = "error"
+		summary = fmt.Sprintf("%d errors in %s since %s, last: %s", len(no.entries), e.Facility(), no.entries[0].Time.Format("15:04:05"), e.Message)
+		details["errors"] = len(no.entries)
+	}
+	if no.suppressed > 0 {
+		details["suppressed"] = no.suppressed
+	}
+	if len(summary) > 1024 {
+		summary = summary[:1024]
+	}
+	payload := map[string]interface{}{
+		"routing_key":  config.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    pagerDutyDedupKey(no.entries),
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         config.Source,
+			"severity":       severity,
+			"timestamp":      e.Time.UTC().Format(time.RFC3339Nano),
+			"component":      e.Facility(),
+			"group":          e.Prefix,
+			"class":          LogLevelToString(e.Level),
+			"custom_details": details,
+		},
+	}
+	return postJSON(client, config.URL, payload, nil)
+}
@@ -0,0 +1,164 @@
+// Package parquetexport - exports rotated servicelogger segments to Parquet files for long-term analytics
+package parquetexport
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/quadtrix/servicelogger"
+)
+
+// Row is a single entry in an exported Parquet file. Field values are stored as text, so that files written from
+// segments in different formats share one schema
+type Row struct {
+	Time     time.Time         `parquet:"time,timestamp(microsecond)"`
+	Level    string            `parquet:"level,dict"`
+	Facility string            `parquet:"facility,dict"`
+	Message  string            `parquet:"message"`
+	Fields   map[string]string `parquet:"fields"`
+}
+
+// Config configures the rotation hook returned by RotationHook. Zero members get their default
+type Config struct {
+	Dir           string // directory the Parquet files are written to, defaults to the directory of the segment
+	Prefix        string // prefix of the Logger that wrote the segments, needed when it contains dots
+	RemoveSegment bool   // remove the segment after it has been exported, e.g. when the Parquet files are the archive
+}
+
+// Export writes the entries of a log segment to a Parquet file at target, compressed with zstd. The segment may be in
+// any of the formats a Logger writes and may be gzip compressed when its name ends in .gz. Lines that are not entries
+// are skipped. The file is written under a hidden temporary name and renamed when complete, so that query engines
+// watching the directory never read a partial file. Export returns the number of entries exported
+func Export(segment string, target string, prefix string) (int, error) {
+	fh, err := os.Open(segment)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+	return export(fh, segment, target, prefix)
+}
+
+// RotationHook returns a hook for Logger.AddRotationHook that exports every rotated segment to a Parquet file named
+// after the segment and the time of the rotation, such as service.log.20261015T120000.123456789Z.parquet. The segment
+// is opened when the hook is called and exported in the background, so the export does not hold up logging. Failures
+// are logged to l at LL_ERROR
+func RotationHook(l *servicelogger.Logger, config Config) servicelogger.RotationHook {
+	return func(segment string) {
+		dir := config.Dir
+		if dir == "" {
+			dir = filepath.Dir(segment)
+		}
+		base := strings.TrimSuffix(filepath.Base(segment), ".1")
+		target := filepath.Join(dir, fmt.Sprintf("%s.%s.parquet", base, time.Now().UTC().Format("20060102T150405.000000000Z")))
+		fh, err := os.Open(segment)
+		go func() {
+			if err != nil {
+				l.LogError("RotationHook", "parquetexport", fmt.Sprintf("Unable to open segment %s: %s", segment, err.Error()))
+				return
+			}
+			defer fh.Close()
+			count, err := export(fh, segment, target, config.Prefix)
+			if err != nil {
+				l.LogError("RotationHook", "parquetexport", fmt.Sprintf("Unable to export segment %s: %s", segment, err.Error()))
+				return
+			}
+			if config.RemoveSegment {
+				// the segment may have been renamed by another rotation by now, so remove the file that was exported
+				removeOpenFile(fh, segment)
+			}
+			l.LogDebug("RotationHook", "parquetexport", fmt.Sprintf("Exported %d entries of %s to %s", count, segment, target))
+		}()
+	}
+}
+
+// export writes the entries read from in to a Parquet file at target
+func export(in io.Reader, segment string, target string, prefix string) (int, error) {
+	if strings.HasSuffix(segment, ".gz") {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", segment, err.Error())
+		}
+		defer gz.Close()
+		in = gz
+	}
+	out, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return 0, err
+	}
+	count, err := writeRows(in, out, prefix)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return count, fmt.Errorf("%s: %s", segment, err.Error())
+	}
+	return count, os.Rename(out.Name(), target)
+}
+
+func writeRows(in io.Reader, out io.Writer, prefix string) (int, error) {
+	w := parquet.NewGenericWriter[Row](out, parquet.Compression(&parquet.Zstd))
+	r := servicelogger.NewEntryReader(in, prefix)
+	rows := make([]Row, 0, 1024)
+	count := 0
+	for {
+		e, err := r.Next()
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+		if err == nil {
+			rows = append(rows, newRow(&e))
+		}
+		if len(rows) == cap(rows) || (err == io.EOF && len(rows) > 0) {
+			if _, werr := w.Write(rows); werr != nil {
+				return count, werr
+			}
+			count += len(rows)
+			rows = rows[:0]
+		}
+		if err == io.EOF {
+			return count, w.Close()
+		}
+	}
+}
+
+func newRow(e *servicelogger.Entry) Row {
+	row := Row{Time: e.Time, Level: servicelogger.LogLevelToString(e.Level), Facility: e.Facility(), Message: e.Message}
+	if len(e.Fields) > 0 {
+		row.Fields = make(map[string]string, len(e.Fields))
+		for key, value := range e.Fields {
+			switch v := value.(type) {
+			case string:
+				row.Fields[key] = v
+			case error:
+				row.Fields[key] = v.Error()
+			default:
+				row.Fields[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	return row
+}
+
+// removeOpenFile removes the file fh was opened from, looking for it under name and the names later rotations give it
+func removeOpenFile(fh *os.File, name string) {
+	open, err := fh.Stat()
+	if err != nil {
+		return
+	}
+	candidates, _ := filepath.Glob(strings.TrimSuffix(name, ".1") + ".[0-9]*")
+	for _, candidate := range candidates {
+		current, err := os.Stat(candidate)
+		if err == nil && os.SameFile(open, current) {
+			_ = os.Remove(candidate)
+			_ = os.Remove(candidate + ".idx")
+			return
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package servicelogger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+type PIIKind int
+
+const (
+	PII_EMAIL       PIIKind = 1 // email addresses
+	PII_IP          PIIKind = 2 // IPv4 and IPv6 addresses
+	PII_CARD        PIIKind = 3 // payment card numbers that pass the Luhn check
+	PII_NATIONAL_ID PIIKind = 4 // US social security numbers, UK national insurance numbers and Dutch BSNs
+)
+
+type MaskMode int
+
+const (
+	MM_ASTERISKS MaskMode = 1 // replace every character of a match with an asterisk
+	MM_HASH      MaskMode = 2 // replace a match with a keyed hash, so equal values can still be correlated
+)
+
+// PIIMaskConfig configures the masking of personal data. Zero members get their default
+type PIIMaskConfig struct {
+	Kinds      []PIIKind // kinds of personal data masked, all kinds when empty
+	Facilities []string  // facility prefixes the masking applies to, all facilities when empty
+	Mode       MaskMode  // how matches are replaced, defaults to MM_ASTERISKS
+	HashKey    []byte    // key of the HMAC used in MM_HASH mode; without a key short values such as IP addresses can be recovered by brute force
+}
+
+// piiMasker is a PIIMaskConfig with its defaults applied
+type piiMasker struct {
+	PIIMaskConfig
+}
+
+var (
+	piiEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	piiIPv4Pattern  = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	piiIPv6Pattern  = regexp.MustCompile(`[0-9A-Fa-f:.]*:[0-9A-Fa-f:.]*:[0-9A-Fa-f:.]*`)
+	piiCardPattern  = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+	piiSSNPattern   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	piiNINOPattern  = regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)
+	piiBSNPattern   = regexp.MustCompile(`\b\d{9}\b`)
+	piiDefaultKinds = []PIIKind{PII_EMAIL, PII_IP, PII_CARD, PII_NATIONAL_ID}
+	piiValidators   = map[*regexp.Regexp]func(string) bool{
+		piiIPv4Pattern: func(s string) bool { return net.ParseIP(s) != nil },
+		piiIPv6Pattern: func(s string) bool { return net.ParseIP(s) != nil },
+		piiCardPattern: luhnValid,
+		piiBSNPattern:  bsnValid,
+	}
+)
+
+// AddPIIMasking masks personal data in the message and field values of every entry in the configured facilities,
+// before it reaches hooks, notifiers, the recorder or the log file. It can be called more than once to mask different
+// kinds of data in different facilities. Masking runs after redaction. AddPIIMasking must be called before the Logger
+// is used from multiple goroutines
+func (l *Logger) AddPIIMasking(config PIIMaskConfig) {
+	if len(config.Kinds) == 0 {
+		config.Kinds = piiDefaultKinds
+	}
+	if config.Mode == 0 {
+		config.Mode = MM_ASTERISKS
+	}
+	l.maskers = append(l.maskers, &piiMasker{PIIMaskConfig: config})
+}
+
+// apply masks the personal data in e when e is in one of the facilities of the masker
+func (m *piiMasker) apply(e *Entry) {
+	if !facilityMatches(m.Facilities, e.Facility()) {
+		return
+	}
+	e.Message = m.maskString(e.Message)
+	for key, value := range e.Fields {
+		s, ok := value.(string)
+		if !ok {
+			s = fmt.Sprint(value)
+		}
+		if masked := m.maskString(s); ok || masked != s {
+			e.Fields[key] = masked
+		}
+	}
+}
+
+func (m *piiMasker) maskString(s string) string {
+	for _, kind := range m.Kinds {
+		switch kind {
+		case PII_EMAIL:
+			s = m.replace(piiEmailPattern, s)
+		case PII_IP:
+			s = m.replace(piiIPv6Pattern, s)
+			s = m.replace(piiIPv4Pattern, s)
+		case PII_CARD:
+			s = m.replace(piiCardPattern, s)
+		case PII_NATIONAL_ID:
+			s = m.replace(piiSSNPattern, s)
+			s = m.replace(piiNINOPattern, s)
+			s = m.replace(piiBSNPattern, s)
+		}
+	}
+	return s
+}
+
+func (m *piiMasker) replace(pattern *regexp.Regexp, s string) string {
+	valid := piiValidators[pattern]
+	return pattern.ReplaceAllStringFunc(s, func(match string) string {
+		if valid != nil && !valid(match) {
+			return match
+		}
+		if m.Mode == MM_HASH {
+			mac := hmac.New(sha256.New, m.HashKey)
+			mac.Write([]byte(match))
+			return "sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+		}
+		return strings.Repeat("*", len(match))
+	})
+}
+
+// luhnValid reports whether the digits in s pass the Luhn check used by payment card numbers
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// bsnValid reports whether a nine digit number passes the eleven check of Dutch citizen service numbers
+func bsnValid(s string) bool {
+	sum := 0
+	for n := 0; n < 8; n++ {
+		sum += int(s[n]-'0') * (9 - n)
+	}
+	sum -= int(s[8] - '0')
+	return sum != 0 && sum%11 == 0
+}
@@ -0,0 +1,96 @@
+package servicelogger
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// entryPools recycles the entries and builders of a Logger that uses EnablePooling
+type entryPools struct {
+	entries   sync.Pool
+	builders  sync.Pool
+	allocated atomic.Uint64 // entries and builders allocated because the pool was empty
+	reused    atomic.Uint64 // entries and builders taken from the pool
+}
+
+// EnablePooling recycles the Entry of every log call, and the EntryBuilder of every builder call, once it has been
+// written, so that steady logging at high rates produces little garbage; Stats reports how many were allocated and
+// reused. The format buffers are recycled by every Logger. Interceptors and hooks must not keep an Entry after they
+// return, nor must sinks, which already is the contract of Sink; copy the Entry instead. EnablePooling must be called
+// before the Logger is used from multiple goroutines
+func (l *Logger) EnablePooling() {
+	l.pools = &entryPools{}
+}
+
+// getEntry returns a zero Entry, from the pool when pooling is enabled
+func (l *Logger) getEntry() *Entry {
+	if l.pools == nil {
+		return &Entry{}
+	}
+	if e, ok := l.pools.entries.Get().(*Entry); ok {
+		l.pools.reused.Add(1)
+		return e
+	}
+	l.pools.allocated.Add(1)
+	return &Entry{}
+}
+
+// putEntry returns e to the pool when pooling is enabled. The fields map is not reused, since copies of the entry kept
+// by the ring buffer and the notifiers share it
+func (l *Logger) putEntry(e *Entry) {
+	if l.pools == nil {
+		return
+	}
+	*e = Entry{}
+	l.pools.entries.Put(e)
+}
+
+// getBuilder returns an EntryBuilder for level, from the pool when pooling is enabled
+func (l *Logger) getBuilder(level LogLevel) *EntryBuilder {
+	if l.pools == nil {
+		return &EntryBuilder{l: l, entry: Entry{Level: level}}
+	}
+	b, ok := l.pools.builders.Get().(*EntryBuilder)
+	if ok {
+		l.pools.reused.Add(1)
+	} else {
+		l.pools.allocated.Add(1)
+		b = &EntryBuilder{}
+	}
+	b.l = l
+	b.entry.Level = level
+	return b
+}
+
+// putBuilder returns b to the pool when pooling is enabled, keeping its emptied fields map for the next use
+func (l *Logger) putBuilder(b *EntryBuilder) {
+	if l.pools == nil {
+		return
+	}
+	fields := b.entry.Fields
+	for key := range fields {
+		delete(fields, key)
+	}
+	*b = EntryBuilder{entry: Entry{Fields: fields}}
+	l.pools.builders.Put(b)
+}
+
+// bufferPool recycles the buffers lines are formatted in
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the capacity above which a buffer is not recycled, so that a single huge entry does not keep its
+// memory alive
+const maxPooledBuffer = 64 * 1024
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
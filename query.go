@@ -0,0 +1,348 @@
+package servicelogger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query selects entries read by a LogReader. Zero members do not restrict the selection
+type Query struct {
+	Since    time.Time // first moment included
+	Until    time.Time // first moment no longer included
+	MinLevel LogLevel  // lowest level included
+	Facility string    // facility prefix, in the prefix.source.function form of facility filters
+	Limit    int       // maximum number of entries returned by Query, the most recent ones are kept
+}
+
+// LogReader reads the entries of a log file and its rotated segments, in any of the formats a Logger writes. Rotated
+// segments are the files filename.1, filename.2 and so on, optionally gzip compressed with a .gz suffix
+type LogReader struct {
+	filename     string
+	prefix       string
+	layout       *TextLayout    // layout of LF_TEXT lines, or nil for the default
+	continuation string         // marker of continuation lines, or empty for the default
+	location     *time.Location // time zone of LF_TEXT timestamps, or nil for time.Local
+}
+
+// NewLogReader returns a LogReader for the log file filename. Without knowing the prefix of the Logger that wrote the
+// file, the reader takes everything up to the first dot of prefix.source as the prefix; use Logger.Reader for files of
+// loggers with dots in their prefix
+func NewLogReader(filename string) *LogReader {
+	return &LogReader{filename: filename}
+}
+
+// Reader returns a LogReader for the log file of the Logger, which reads LF_TEXT lines in its text layout, multiline
+// mode and time zone
+func (l *Logger) Reader() *LogReader {
+	r := &LogReader{filename: l.filename, prefix: l.prefix, layout: l.textLayout, continuation: l.continuation}
+	if l.timestamps != nil {
+		r.location = l.timestamps.Location
+	}
+	return r
+}
+
+// Segments returns the files of the log, oldest first, ending with the active log file
+func (r *LogReader) Segments() []string {
+	var segments []string
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s.%d", r.filename, n)
+		if _, err := os.Stat(name); err != nil {
+			name += ".gz"
+			if _, err = os.Stat(name); err != nil {
+				break
+			}
+		}
+		segments = append([]string{name}, segments...)
+	}
+	if _, err := os.Stat(r.filename); err == nil {
+		segments = append(segments, r.filename)
+	}
+	return segments
+}
+
+// Query returns the entries matching q, oldest first
+func (r *LogReader) Query(q Query) ([]Entry, error) {
+	var result []Entry
+	err := r.Scan(q, func(e Entry) bool {
+		result = append(result, e)
+		if q.Limit > 0 && len(result) > q.Limit {
+			result = result[1:]
+		}
+		return true
+	})
+	return result, err
+}
+
+// Scan calls fn for every entry matching q, oldest first, until fn returns false. Lines that are not log entries, such
+// as those written by other programs, are skipped
+func (r *LogReader) Scan(q Query, fn func(e Entry) bool) error {
+	for _, segment := range r.Segments() {
+		more, err := r.scanSegment(segment, q, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Replay writes every entry matching q to each of the sinks, oldest first, e.g. to backfill a remote collector with the
+// entries that only reached the local log file during an outage. It stops at the first error of a sink and returns the
+// number of entries that were written to all sinks, so that a later call can resume with Since set to just after the
+// last of them
+func (r *LogReader) Replay(q Query, sinks ...Sink) (int, error) {
+	replayed := 0
+	var err error
+	scanErr := r.Scan(q, func(e Entry) bool {
+		for _, s := range sinks {
+			if err = s.WriteEntry(&e); err != nil {
+				return false
+			}
+		}
+		replayed++
+		return true
+	})
+	if err != nil {
+		return replayed, err
+	}
+	return replayed, scanErr
+}
+
+func (r *LogReader) scanSegment(segment string, q Query, fn func(e Entry) bool) (bool, error) {
+	fh, err := os.Open(segment)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// rotated away between listing and opening
+			return true, nil
+		}
+		return false, err
+	}
+	defer fh.Close()
+	var in io.Reader = fh
+	if !q.Since.IsZero() || !q.Until.IsZero() || q.MinLevel > LL_TRACE {
+		var matching bool
+		in, matching, err = seekIndexed(fh, segment, q)
+		if err != nil || !matching {
+			return err == nil, err
+		}
+	}
+	if strings.HasSuffix(segment, ".gz") {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", segment, err.Error())
+		}
+		defer gz.Close()
+		in = gz
+	}
+	er := NewEntryReader(in, r.prefix)
+	er.layout = r.layout
+	er.location = r.location
+	if r.continuation != "" {
+		er.continuation = r.continuation
+	}
+	return scanEntries(er, q, fn)
+}
+
+// scanEntries reads the entries from r and calls fn for every entry matching q, until fn returns false
+func scanEntries(r *EntryReader, q Query, fn func(e Entry) bool) (bool, error) {
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if q.Matches(&e) && !fn(e) {
+			return false, nil
+		}
+	}
+}
+
+// Matches reports whether e is selected by q. The Limit of q is not taken into account
+func (q Query) Matches(e *Entry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if !e.Level.atLeast(q.MinLevel) {
+		return false
+	}
+	return q.Facility == "" || strings.HasPrefix(e.Facility(), q.Facility)
+}
+
+// ParseLine parses a line written by a Logger in LF_TEXT, LF_JSON or LF_LOGFMT format. Field values of LF_TEXT and
+// LF_LOGFMT lines are returned as strings. prefix is the prefix of the Logger that wrote the line, or empty to take
+// everything up to the first dot of prefix.source as the prefix
+func ParseLine(line string, prefix string) (Entry, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLine(line)
+	}
+	if isLogfmtLine(line) {
+		return parseLogfmtLine(line)
+	}
+	return parseTextLine(line, prefix)
+}
+
+// parseLine parses a line as ParseLine does, with LF_TEXT lines in the layout and their timestamps in location when
+// not nil
+func parseLine(line string, prefix string, layout *TextLayout, location *time.Location) (Entry, error) {
+	if (layout == nil && location == nil) || strings.HasPrefix(line, "{") || isLogfmtLine(line) {
+		return ParseLine(line, prefix)
+	}
+	if layout == nil {
+		layout = &defaultTextLayout
+	}
+	if location == nil {
+		location = time.Local
+	}
+	return parseLayoutLine(strings.TrimRight(line, "\r\n"), prefix, layout, location)
+}
+
+// isLogfmtLine reports whether line is in LF_LOGFMT format, which starts with the time, or with the level when elapsed
+// times are written instead
+func isLogfmtLine(line string) bool {
+	return strings.HasPrefix(line, "time=") || strings.HasPrefix(line, "level=")
+}
+
+func parseTextLine(line string, prefix string) (Entry, error) {
+	return parseLayoutLine(line, prefix, &defaultTextLayout, time.Local)
+}
+
+// parseFields parses key=value pairs as written by formatFields
+func parseFields(s string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				break
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else if space := strings.IndexByte(rest, ' '); space >= 0 {
+			value, rest = rest[:space], rest[space:]
+		} else {
+			value, rest = rest, ""
+		}
+		fields[key] = value
+		s = strings.TrimLeft(rest, " ")
+	}
+	return fields
+}
+
+// parseLogfmtLine parses an LF_LOGFMT line. Pairs other than the ones written for every entry become fields
+func parseLogfmtLine(line string) (Entry, error) {
+	var e Entry
+	pairs := parseFields(line)
+	var err error
+	if stamp, ok := pairs["time"]; ok || pairs["elapsed"] == nil {
+		e.Time, err = time.Parse(time.RFC3339Nano, fmt.Sprint(stamp))
+		if err != nil {
+			return e, err
+		}
+	}
+	label := fmt.Sprint(pairs["level"])
+	var ok bool
+	e.Level, ok = levelFromLabel(label)
+	if !ok {
+		return e, fmt.Errorf("unknown level %q", label)
+	}
+	for key, value := range pairs {
+		switch key {
+		case "time", "level", "local_time", "zone":
+		case "elapsed":
+			if e.elapsed, err = parseSeconds(value.(string)); err != nil {
+				return e, err
+			}
+		case "delta":
+			if e.delta, err = parseSeconds(value.(string)); err != nil {
+				return e, err
+			}
+		case "prefix":
+			e.Prefix = value.(string)
+		case "source":
+			e.Source = value.(string)
+		case "function":
+			e.Function = value.(string)
+		case "msg":
+			e.Message = value.(string)
+		default:
+			e.SetField(key, value)
+		}
+	}
+	return e, nil
+}
+
+// jsonLine is the layout of an LF_JSON line
+type jsonLine struct {
+	Time     time.Time              `json:"time"`
+	Level    string                 `json:"level"`
+	Elapsed  json.Number            `json:"elapsed"`
+	Delta    json.Number            `json:"delta"`
+	Prefix   string                 `json:"prefix"`
+	Source   string                 `json:"source"`
+	Function string                 `json:"function"`
+	Message  string                 `json:"message"`
+	Fields   map[string]interface{} `json:"fields"`
+}
+
+func parseJSONLine(line string) (Entry, error) {
+	var j jsonLine
+	if err := json.Unmarshal([]byte(line), &j); err != nil {
+		return Entry{}, err
+	}
+	level, ok := levelFromLabel(j.Level)
+	if !ok {
+		return Entry{}, fmt.Errorf("unknown level %q", j.Level)
+	}
+	e := Entry{Time: j.Time, Level: level, Prefix: j.Prefix, Source: j.Source, Function: j.Function, Message: j.Message, Fields: j.Fields}
+	var err error
+	if j.Elapsed != "" {
+		if e.elapsed, err = parseSeconds(j.Elapsed.String()); err != nil {
+			return e, err
+		}
+	}
+	if j.Delta != "" {
+		if e.delta, err = parseSeconds(j.Delta.String()); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// levelFromLabel returns the LogLevel for a label written by levelLabel or LogLevelToString, with or without the labels
+// of SetLevelLabels
+func levelFromLabel(label string) (LogLevel, bool) {
+	for level, custom := range levelLabels {
+		if custom == label {
+			return level, true
+		}
+	}
+	if label == "WARNING" {
+		return LL_WARN, true
+	}
+	for level := LL_TRACE; level <= highestLevel; level++ {
+		if defaultLevelLabel(level) == label {
+			return level, true
+		}
+	}
+	return 0, false
+}
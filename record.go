@@ -0,0 +1,192 @@
+package servicelogger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordingMagic identifies a servicelogger recording file
+const recordingMagic = "SLREC1\n"
+
+// recorder appends every written entry to a recording file. The file starts with recordingMagic and the recording start
+// time, followed by one record per entry: the time since the previous entry in nanoseconds, the level, the prefix,
+// function, source and text, and the number of fields followed by their keys and values. Strings are prefixed by their
+// varint encoded length, field values are recorded in their string form
+type recorder struct {
+	mu         sync.Mutex
+	filehandle *os.File
+	last       time.Time
+	buf        []byte
+}
+
+// StartRecording starts recording every entry written by the Logger into filename, in a compact format that can be
+// replayed later with Replay. An existing recording file is overwritten
+func (l *Logger) StartRecording(filename string) error {
+	if l.recorder.Load() != nil {
+		return errors.New("a recording is already running")
+	}
+	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	r := &recorder{
+		filehandle: fh,
+		last:       l.now(),
+	}
+	r.buf = append(r.buf, recordingMagic...)
+	r.buf = binary.AppendVarint(r.buf, r.last.UnixNano())
+	_, err = fh.Write(r.buf)
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	if !l.recorder.CompareAndSwap(nil, r) {
+		fh.Close()
+		return errors.New("a recording is already running")
+	}
+	return nil
+}
+
+// StopRecording stops a running recording and closes the recording file. It may be called while other goroutines are
+// logging
+func (l *Logger) StopRecording() error {
+	r := l.recorder.Swap(nil)
+	if r == nil {
+		return errors.New("no recording is running")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.filehandle.Close()
+	r.filehandle = nil
+	return err
+}
+
+func (l *Logger) record(e *Entry) {
+	r := l.recorder.Load()
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.filehandle == nil {
+		// stopped after it was loaded
+		return
+	}
+	now := l.now()
+	delta := now.Sub(r.last)
+	if delta < 0 {
+		delta = 0
+	}
+	r.last = now
+	r.buf = binary.AppendUvarint(r.buf[:0], uint64(delta))
+	r.buf = append(r.buf, byte(e.Level))
+	r.buf = appendRecordString(r.buf, e.Prefix)
+	r.buf = appendRecordString(r.buf, e.Function)
+	r.buf = appendRecordString(r.buf, e.Source)
+	r.buf = appendRecordString(r.buf, e.Message)
+	r.buf = binary.AppendUvarint(r.buf, uint64(len(e.Fields)))
+	for key, value := range e.Fields {
+		r.buf = appendRecordString(r.buf, key)
+		r.buf = appendRecordString(r.buf, fmt.Sprint(value))
+	}
+	// A failing recording must never interfere with logging itself
+	_, _ = r.filehandle.Write(r.buf)
+}
+
+func appendRecordString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// Replay reads a recording made with StartRecording and writes its entries to target, subject to the facility filters
+// and minimum level of target. Entries are replayed at speed times the original pace, so 1 replays in real time and 10
+// replays ten times faster. A speed of 0 replays all entries without delay. FATAL entries are written but never exit
+func Replay(filename string, target *Logger, speed float64) error {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	r := bufio.NewReader(fh)
+	magic := make([]byte, len(recordingMagic))
+	_, err = io.ReadFull(r, magic)
+	if err != nil || string(magic) != recordingMagic {
+		return fmt.Errorf("%s is not a servicelogger recording", filename)
+	}
+	_, err = binary.ReadVarint(r)
+	if err != nil {
+		return fmt.Errorf("corrupt recording header: %s", err.Error())
+	}
+	for {
+		delta, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt recording: %s", err.Error())
+		}
+		level, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("corrupt recording: %s", err.Error())
+		}
+		var strs [4]string
+		for n := range strs {
+			strs[n], err = readRecordString(r)
+			if err != nil {
+				return fmt.Errorf("corrupt recording: %s", err.Error())
+			}
+		}
+		e := &Entry{
+			Level:    LogLevel(level),
+			Prefix:   strs[0],
+			Function: strs[1],
+			Source:   strs[2],
+			Message:  strs[3],
+		}
+		nfields, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("corrupt recording: %s", err.Error())
+		}
+		if nfields > 0 {
+			e.Fields = make(map[string]interface{}, nfields)
+		}
+		for ; nfields > 0; nfields-- {
+			key, err := readRecordString(r)
+			if err != nil {
+				return fmt.Errorf("corrupt recording: %s", err.Error())
+			}
+			e.Fields[key], err = readRecordString(r)
+			if err != nil {
+				return fmt.Errorf("corrupt recording: %s", err.Error())
+			}
+		}
+		if speed > 0 {
+			time.Sleep(time.Duration(float64(delta) / speed))
+		}
+		if e.Level.atLeast(target.getFilteredLogLevel(e.Facility())) {
+			e.Time = target.now()
+			if target.intercept(e) {
+				target.writeEntry(e)
+			}
+		}
+	}
+}
+
+func readRecordString(r *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
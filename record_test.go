@@ -0,0 +1,34 @@
+package servicelogger
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStopRecordingWhileLogging(t *testing.T) {
+	l, _ := newTestLogger(t, false, "10M", 1)
+	recording := filepath.Join(t.TempDir(), "test.rec")
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				l.LogInfo("TestRecording", "record", "entry")
+			}
+		}()
+	}
+	for n := 0; n < 100; n++ {
+		if err := l.StartRecording(recording); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.StopRecording(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+}
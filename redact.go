@@ -0,0 +1,79 @@
+package servicelogger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactionConfig configures the masking of secrets in entries. Zero members get their default
+type RedactionConfig struct {
+	Patterns    []*regexp.Regexp // matches in the message and in field values are replaced
+	Fields      []string         // names of fields whose values are replaced entirely, compared case-insensitively
+	Replacement string           // text that replaces secrets, defaults to "[REDACTED]"
+}
+
+// DefaultRedactedFields is a list of field names that usually hold credentials, for use in RedactionConfig.Fields
+var DefaultRedactedFields = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "api_key", "apikey", "authorization", "cookie"}
+
+// redactor is the compiled form of a RedactionConfig
+type redactor struct {
+	fieldValues *regexp.Regexp // matches name=value and name: value for the redacted field names
+	patterns    []*regexp.Regexp
+	fields      map[string]bool
+	replacement string
+}
+
+// SetRedaction masks secrets in every entry before it reaches hooks, notifiers, the recorder or the log file. Values of
+// the configured fields are replaced entirely, and so are the values following "name=" or "name:" for those names in
+// the message, e.g. password=hunter2 or Authorization: Bearer abc. Matches of the patterns are replaced in the message
+// and in all field values. Redaction runs after the interceptors, so fields they add are covered too. SetRedaction must
+// be called before the Logger is used from multiple goroutines
+func (l *Logger) SetRedaction(config RedactionConfig) error {
+	if config.Replacement == "" {
+		config.Replacement = "[REDACTED]"
+	}
+	r := &redactor{patterns: config.Patterns, fields: make(map[string]bool, len(config.Fields)), replacement: config.Replacement}
+	if len(config.Fields) > 0 {
+		names := make([]string, len(config.Fields))
+		for n, name := range config.Fields {
+			r.fields[strings.ToLower(name)] = true
+			names[n] = regexp.QuoteMeta(name)
+		}
+		var err error
+		r.fieldValues, err = regexp.Compile(`(?i)\b(` + strings.Join(names, "|") + `)(\s*[=:]\s*)((?:bearer|basic)\s+)?[^\s,;&"]+`)
+		if err != nil {
+			return fmt.Errorf("invalid redacted field name: %s", err.Error())
+		}
+	}
+	l.redact = r
+	return nil
+}
+
+// apply masks the secrets in e
+func (r *redactor) apply(e *Entry) {
+	e.Message = r.redactString(e.Message)
+	for key, value := range e.Fields {
+		if r.fields[strings.ToLower(key)] {
+			e.Fields[key] = r.replacement
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			s = fmt.Sprint(value)
+		}
+		if redacted := r.redactString(s); ok || redacted != s {
+			e.Fields[key] = redacted
+		}
+	}
+}
+
+func (r *redactor) redactString(s string) string {
+	if r.fieldValues != nil {
+		s = r.fieldValues.ReplaceAllString(s, "${1}${2}"+strings.ReplaceAll(r.replacement, "$", "$$"))
+	}
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllLiteralString(s, r.replacement)
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package servicelogger
+
+import (
+	"fmt"
+	"sync"
+)
+
+// reentrancyGuard tracks the goroutines that are logging to a Logger, so that a hook, interceptor, field provider or
+// sink that logs to the same Logger is detected instead of recursing without end
+type reentrancyGuard struct {
+	active sync.Map // goroutine ID -> struct{}
+}
+
+// hasCallbacks reports whether the Logger calls code of the application while logging, which could log in turn
+func (l *Logger) hasCallbacks() bool {
+	return len(l.interceptors) > 0 || len(l.beforeHooks) > 0 || len(l.afterHooks) > 0 || len(l.rotationHooks) > 0 ||
+		len(l.fieldProviders) > 0 || len(l.sinks) > 0
+}
+
+// enterLog marks the calling goroutine as logging and returns its ID, or reports false when it already is. The
+// goroutine ID, which costs about a microsecond to determine, is only needed when the Logger has callbacks; the ID is 0
+// otherwise
+func (l *Logger) enterLog() (uint64, bool) {
+	if !l.hasCallbacks() {
+		return 0, true
+	}
+	id := goroutineID()
+	if _, nested := l.reentry.active.LoadOrStore(id, struct{}{}); nested {
+		return id, false
+	}
+	return id, true
+}
+
+// leaveLog marks the goroutine as no longer logging
+func (l *Logger) leaveLog(id uint64) {
+	if id != 0 {
+		l.reentry.active.Delete(id)
+	}
+}
+
+// logNested writes a message logged from within a log call of the same goroutine, typically from a hook or sink,
+// straight to the log file with writeInternal, which calls no hooks, interceptors or sinks, so it can neither recurse
+// nor be lost. The text is redacted and masked as that of any other entry of its facility
+func (l *Logger) logNested(level LogLevel, function string, source string, text string) {
+	if l.stats != nil {
+		l.stats.nested.Add(1)
+	}
+	e := &Entry{Prefix: l.prefix, Source: source, Function: function, Message: text}
+	if l.redact != nil {
+		l.redact.apply(e)
+	}
+	for _, masker := range l.maskers {
+		masker.apply(e)
+	}
+	l.writeInternal(level, "log", fmt.Sprintf("Nested log call from %s: %s", e.Facility(), e.Message))
+}
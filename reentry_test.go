@@ -0,0 +1,39 @@
+package servicelogger
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// nestingSink logs a message of its own for every entry it receives
+type nestingSink struct {
+	l    *Logger
+	text string
+}
+
+func (s *nestingSink) WriteEntry(e *Entry) error {
+	s.l.LogWarn("WriteEntry", "sink", s.text)
+	return nil
+}
+
+func TestNestedLogCallsAreRedactedAndMasked(t *testing.T) {
+	l, filename := newTestLogger(t, false, "10M", 1)
+	if err := l.SetRedaction(RedactionConfig{Patterns: []*regexp.Regexp{regexp.MustCompile(`hunter2`)}}); err != nil {
+		t.Fatal(err)
+	}
+	l.AddPIIMasking(PIIMaskConfig{Kinds: []PIIKind{PII_EMAIL}})
+	l.AddSink(&nestingSink{l: l, text: "password hunter2 rejected for jane@example.com"}, SinkConfig{})
+	l.LogInfo("TestNested", "nested", "login")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "Nested log call from test.sink.WriteEntry") {
+		t.Fatalf("nested log call missing from %q", content)
+	}
+	if strings.Contains(string(content), "hunter2") || strings.Contains(string(content), "jane@example.com") {
+		t.Fatalf("nested log call not redacted and masked: %q", content)
+	}
+}
@@ -0,0 +1,448 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy configures how deliveries to a remote destination are retried. Zero members get their default
+type RetryPolicy struct {
+	MaxAttempts    int           // attempts per delivery including the first, defaults to 5. 1 disables retries
+	MaxAge         time.Duration // no retry is started this long after the first attempt, defaults to 5 minutes
+	InitialBackoff time.Duration // wait before the first retry, defaults to 500 milliseconds
+	MaxBackoff     time.Duration // upper bound of the wait, which doubles after every attempt, defaults to 30 seconds
+}
+
+// permanentError is an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks err as an error that retrying cannot fix, such as a request the destination rejected as invalid.
+// Sinks return it to stop a RetryPolicy from retrying a delivery
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 5
+	}
+	if p.MaxAge <= 0 {
+		p.MaxAge = 5 * time.Minute
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 500 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	return p
+}
+
+// retry calls deliver until it succeeds, returns a permanent error, the policy gives up or ctx is done. The waits are
+// randomized between half and all of the backoff, so that clients that failed together do not retry together. retry
+// returns the number of attempts and the last error
+func (p RetryPolicy) retry(ctx context.Context, deliver func() error) (int, error) {
+	p = p.withDefaults()
+	start := time.Now()
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := deliver()
+		if err == nil || IsPermanent(err) || attempt >= p.MaxAttempts || time.Since(start) >= p.MaxAge {
+			return attempt, err
+		}
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// Do retries deliver according to the policy like the sinks of the package do, for destinations implemented outside
+// of it. It returns the number of attempts and the last error; errors marked with Permanent are not retried
+func (p RetryPolicy) Do(ctx context.Context, deliver func() error) (int, error) {
+	return p.retry(ctx, deliver)
+}
+
+// RetrySinkConfig configures a sink returned by NewRetrySink. Zero members get their default
+type RetrySinkConfig struct {
+	Retry      RetryPolicy // retry policy of every delivery
+	QueueSize  int         // entries waiting for delivery before new ones are spooled or dropped, defaults to 1000
+	Spool      SpoolConfig // disk spool for the entries that do not fit in the queue, no spool when Spool.Dir is empty
+	DeadLetter string      // file the entries that cannot be delivered are appended to, none when empty
+}
+
+// RetrySinkStats holds the counters of a RetrySink
+type RetrySinkStats struct {
+	Delivered    uint64 // entries the wrapped sink accepted
+	Retried      uint64 // delivery attempts that were repeated after a failure
+	Spooled      uint64 // entries written to the disk spool
+	Dropped      uint64 // entries dropped because the queue or the spool was full, or the sink was drained
+	Failed       uint64 // entries the wrapped sink rejected, or still failed to write when the policy gave up
+	DeadLettered uint64 // entries counted as Dropped or Failed that were written to the dead-letter file
+}
+
+// RetrySink delivers entries to a remote sink from a background goroutine, retrying failed deliveries, so that a
+// transient outage of a collector neither blocks logging nor loses entries. With a spool, entries that do not fit in
+// the queue during a longer outage are written to disk and replayed in order once the sink accepts entries again
+type RetrySink struct {
+	sink         Sink
+	retry        RetryPolicy
+	mu           sync.Mutex
+	queue        chan *Entry
+	closed       bool
+	spool        *spool
+	dead         *deadLetter
+	wake         chan struct{}
+	stopped      chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	delivered    atomic.Uint64
+	retried      atomic.Uint64
+	spooled      atomic.Uint64
+	dropped      atomic.Uint64
+	failed       atomic.Uint64
+	deadLettered atomic.Uint64
+}
+
+// NewRetrySink returns a RetrySink that hands entries to s, retrying failed writes according to the policy. Entries
+// are delivered in the order they were logged; while a delivery is retried, the entries after it wait in the queue.
+// When the queue is full, or the policy gives up on an entry, the entry and everything queued go to the spool, and new
+// entries follow them there until the spool has been replayed. Entries spooled by a previous process are replayed
+// first. Entries that are dropped or rejected are written to the dead-letter file, in LF_JSON format with the reason,
+// from where RedriveDeadLetters can deliver them later
+func NewRetrySink(s Sink, config RetrySinkConfig) (*RetrySink, error) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &RetrySink{
+		sink:    s,
+		retry:   config.Retry.withDefaults(),
+		queue:   make(chan *Entry, config.QueueSize),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if config.DeadLetter != "" {
+		r.dead = &deadLetter{name: config.DeadLetter}
+	}
+	if config.Spool.Dir != "" {
+		var err error
+		if r.spool, err = openSpool(config.Spool); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	go r.run()
+	return r, nil
+}
+
+// WriteEntry queues a copy of e for delivery. It returns an error when the entry had to be dropped
+func (r *RetrySink) WriteEntry(e *Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		r.discard(e, "sink is drained", &r.dropped)
+		return errors.New("retry sink is drained")
+	}
+	c := copyEntry(e)
+	if r.spool != nil && !r.spool.empty() {
+		return r.spoolEntry(c)
+	}
+	select {
+	case r.queue <- c:
+		return nil
+	default:
+	}
+	if r.spool == nil {
+		r.discard(c, "queue is full", &r.dropped)
+		return errors.New("retry sink queue is full")
+	}
+	return r.spill(c)
+}
+
+// Stats returns the counters of the sink
+func (r *RetrySink) Stats() RetrySinkStats {
+	return RetrySinkStats{
+		Delivered:    r.delivered.Load(),
+		Retried:      r.retried.Load(),
+		Spooled:      r.spooled.Load(),
+		Dropped:      r.dropped.Load(),
+		Failed:       r.failed.Load(),
+		DeadLettered: r.deadLettered.Load(),
+	}
+}
+
+// Drain delivers the queued and spooled entries and stops the sink, giving up when ctx is done. Retries that are
+// waiting when ctx is done are abandoned. It returns the number of queued entries that were not delivered; they are
+// written to the spool, so that the next process delivers them, or otherwise to the dead-letter file
+func (r *RetrySink) Drain(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	select {
+	case <-r.stopped:
+		return 0, nil
+	case <-ctx.Done():
+		r.cancel()
+		left := len(r.queue)
+		if r.spool == nil {
+			r.dropped.Add(uint64(left))
+		}
+		return left, ctx.Err()
+	}
+}
+
+func (r *RetrySink) run() {
+	defer close(r.stopped)
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.spool != nil {
+			_ = r.spill(nil)
+			r.spool.close()
+			return
+		}
+		if r.dead != nil && r.closed {
+			// counted as dropped by Drain already
+			for e := range r.queue {
+				if r.dead.write(e, "not delivered before the sink was drained") == nil {
+					r.deadLettered.Add(1)
+				}
+			}
+		}
+	}()
+	drained := false
+	for r.ctx.Err() == nil {
+		if r.spooling() {
+			if !r.replay() {
+				timer := time.NewTimer(r.retry.MaxBackoff)
+				select {
+				case <-timer.C:
+				case <-r.ctx.Done():
+					timer.Stop()
+				}
+			}
+			continue
+		}
+		if drained {
+			return
+		}
+		select {
+		case e, ok := <-r.queue:
+			if !ok {
+				drained = true
+				continue
+			}
+			r.deliver(e)
+		case <-r.wake:
+		}
+	}
+}
+
+// deliver writes a queued entry to the sink, spooling it when the policy gives up
+func (r *RetrySink) deliver(e *Entry) {
+	attempts, err := r.retry.retry(r.ctx, func() error {
+		return r.sink.WriteEntry(e)
+	})
+	r.retried.Add(uint64(attempts - 1))
+	switch {
+	case err == nil:
+		r.delivered.Add(1)
+	case r.spool == nil || IsPermanent(err):
+		r.discard(e, err.Error(), &r.failed)
+	default:
+		// entries queued after e may have been spooled while it was retried, so it goes before them
+		r.mu.Lock()
+		if serr := r.spool.prepend(e); serr != nil {
+			r.discard(e, err.Error(), &r.dropped)
+		} else {
+			r.spooled.Add(1)
+		}
+		_ = r.spill(nil)
+		r.mu.Unlock()
+	}
+}
+
+func (r *RetrySink) spooling() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spool != nil && !r.spool.empty()
+}
+
+// spill moves the queued entries and then last to the spool, so that the spool holds every entry that was not delivered
+// yet in order. It must be called with r.mu held
+func (r *RetrySink) spill(last *Entry) error {
+	var err error
+	for queued := true; queued; {
+		select {
+		case e, ok := <-r.queue:
+			if !ok {
+				queued = false
+				break
+			}
+			if serr := r.spoolEntry(e); err == nil {
+				err = serr
+			}
+		default:
+			queued = false
+		}
+	}
+	if last != nil {
+		if serr := r.spoolEntry(last); err == nil {
+			err = serr
+		}
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// spoolEntry writes e to the spool. It must be called with r.mu held
+func (r *RetrySink) spoolEntry(e *Entry) error {
+	evicted, err := r.spool.append(e)
+	for _, seg := range evicted {
+		r.evict(seg)
+	}
+	if err != nil {
+		r.discard(e, "unable to spool: "+err.Error(), &r.dropped)
+		return err
+	}
+	r.spooled.Add(1)
+	return nil
+}
+
+// evict disposes of a segment evicted from the full spool, moving its undelivered entries to the dead-letter file. It
+// must be called with r.mu held
+func (r *RetrySink) evict(seg *spoolSegment) {
+	defer os.Remove(seg.name)
+	r.dropped.Add(uint64(seg.entries - seg.delivered))
+	if r.dead == nil {
+		return
+	}
+	fh, err := os.Open(seg.name)
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	reader := NewEntryReader(fh, "")
+	for n := 1; ; n++ {
+		e, err := reader.Next()
+		if err != nil {
+			return
+		}
+		if n > seg.delivered && r.dead.write(&e, "spool is full") == nil {
+			r.deadLettered.Add(1)
+		}
+	}
+}
+
+// discard counts an entry that could not be delivered in counter, and writes it to the dead-letter file
+func (r *RetrySink) discard(e *Entry, reason string, counter *atomic.Uint64) {
+	counter.Add(1)
+	if r.dead != nil && r.dead.write(e, reason) == nil {
+		r.deadLettered.Add(1)
+	}
+}
+
+// replay delivers the entries of the oldest segment of the spool and removes it. It returns false when the sink is
+// still failing, leaving the rest of the segment for the next attempt
+func (r *RetrySink) replay() bool {
+	r.mu.Lock()
+	seg := r.spool.oldest()
+	start := seg.delivered
+	r.mu.Unlock()
+	fh, err := os.Open(seg.name)
+	if err != nil {
+		r.mu.Lock()
+		r.dropped.Add(uint64(seg.entries - seg.delivered))
+		r.spool.remove(seg)
+		r.mu.Unlock()
+		return true
+	}
+	defer fh.Close()
+	reader := NewEntryReader(fh, "")
+	for n := 1; ; n++ {
+		e, err := reader.Next()
+		if err != nil {
+			// the end of the segment, or a frame cut short by a crash
+			break
+		}
+		if n <= start {
+			continue
+		}
+		attempts, err := r.retry.retry(r.ctx, func() error {
+			return r.sink.WriteEntry(&e)
+		})
+		r.retried.Add(uint64(attempts - 1))
+		if err != nil && !IsPermanent(err) {
+			r.mu.Lock()
+			seg.replaying = false
+			r.mu.Unlock()
+			return false
+		}
+		if err != nil {
+			r.discard(&e, err.Error(), &r.failed)
+		} else {
+			r.delivered.Add(1)
+		}
+		r.mu.Lock()
+		seg.delivered = n
+		r.mu.Unlock()
+	}
+	r.mu.Lock()
+	r.spool.remove(seg)
+	r.mu.Unlock()
+	return true
+}
+
+// copyEntry returns a copy of e that can be kept after e is reused, without its context
+func copyEntry(e *Entry) *Entry {
+	c := *e
+	c.Context = nil
+	if e.Fields != nil {
+		c.Fields = make(map[string]interface{}, len(e.Fields))
+		for key, value := range e.Fields {
+			c.Fields[key] = value
+		}
+	}
+	return &c
+}
@@ -0,0 +1,107 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// tailBuffer is the number of new entries a Tail channel buffers before entries are dropped for a slow reader
+const tailBuffer = 256
+
+// ringBuffer keeps the most recent entries of a Logger in memory and passes new entries on to Tail readers
+type ringBuffer struct {
+	mu          sync.Mutex
+	entries     []Entry
+	next        int
+	full        bool
+	subscribers map[chan Entry]struct{}
+}
+
+// EnableRingBuffer keeps the last size entries written by the Logger in memory, for RecentEntries and Tail. Calling it
+// again replaces the buffer and its contents. EnableRingBuffer must be called before the Logger is used from multiple
+// goroutines
+func (l *Logger) EnableRingBuffer(size int) error {
+	if size < 1 {
+		return errors.New("ring buffer size too low (>=1)")
+	}
+	l.ring = &ringBuffer{entries: make([]Entry, size), subscribers: make(map[chan Entry]struct{})}
+	return nil
+}
+
+// RecentEntries returns up to n of the most recent entries in the ring buffer, oldest first
+func (l *Logger) RecentEntries(n int) []Entry {
+	if l.ring == nil {
+		return nil
+	}
+	l.ring.mu.Lock()
+	defer l.ring.mu.Unlock()
+	return l.ring.last(n)
+}
+
+// Tail returns a channel that receives the last n entries in the ring buffer and then every new entry, until ctx is
+// done and the channel is closed. A reader that falls more than a few hundred entries behind misses entries rather
+// than blocking the Logger. Tail requires EnableRingBuffer
+func (l *Logger) Tail(ctx context.Context, n int) (<-chan Entry, error) {
+	r := l.ring
+	if r == nil {
+		return nil, errors.New("ring buffer is not enabled")
+	}
+	r.mu.Lock()
+	history := r.last(n)
+	ch := make(chan Entry, len(history)+tailBuffer)
+	for _, e := range history {
+		ch <- e
+	}
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		delete(r.subscribers, ch)
+		close(ch)
+		r.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// add stores a copy of e and passes it on to the Tail readers
+func (r *ringBuffer) add(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = *e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+	for ch := range r.subscribers {
+		select {
+		case ch <- *e:
+		default:
+		}
+	}
+}
+
+// last returns up to n of the most recent entries, oldest first. It must be called with r.mu held
+func (r *ringBuffer) last(n int) []Entry {
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	if n < count {
+		count = n
+	}
+	if count <= 0 {
+		return nil
+	}
+	result := make([]Entry, count)
+	start := r.next - count
+	if start < 0 {
+		start += len(r.entries)
+	}
+	for i := range result {
+		result[i] = r.entries[(start+i)%len(r.entries)]
+	}
+	return result
+}
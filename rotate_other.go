@@ -0,0 +1,29 @@
+//go:build !windows
+
+package servicelogger
+
+import (
+	"fmt"
+	"os"
+)
+
+// renameFile renames a rotated segment of the log file
+func renameFile(from string, to string) error {
+	return os.Rename(from, to)
+}
+
+// rotateCurrent moves the log file to segment and opens a new log file. Open files can be renamed on this platform, so
+// the log file is renamed and reopened. When the log file cannot be opened again, an error is returned and the entries
+// keep being written to segment through the old handle
+func (l *Logger) rotateCurrent(segment string) error {
+	if err := os.Rename(l.filename, segment); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open log file %s: %w", l.filename, err)
+	}
+	l.filehandle.Close()
+	l.filehandle = fh
+	return nil
+}
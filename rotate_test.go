@@ -0,0 +1,82 @@
+package servicelogger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// openDescriptors returns the number of file descriptors open in the process, skipping the test where they cannot be
+// listed
+func openDescriptors(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open file descriptors cannot be listed on this platform")
+	}
+	return len(entries)
+}
+
+func TestRotationKeepsDescriptorsFlat(t *testing.T) {
+	l, _ := newTestLogger(t, true, "1K", 3)
+	rotations := 0
+	l.AddRotationHook(func(segment string) { rotations++ })
+	message := strings.Repeat("x", 200)
+	for rotations < 10 {
+		l.LogInfo("TestRotation", "rotate", message)
+	}
+	before := openDescriptors(t)
+	for n := 0; rotations < 3000; n++ {
+		l.LogInfo("TestRotation", "rotate", fmt.Sprintf("%d %s", n, message))
+	}
+	if after := openDescriptors(t); after > before {
+		t.Fatalf("%d file descriptors open after %d rotations, %d before", after, rotations, before)
+	}
+	if dropped := l.Stats().Dropped; dropped != 0 {
+		t.Fatalf("%d entries could not be written", dropped)
+	}
+}
+
+func TestRotationUnderConcurrentLoad(t *testing.T) {
+	l, filename := newTestLogger(t, true, "1K", 3)
+	var rotations atomic.Int64
+	l.AddRotationHook(func(segment string) { rotations.Add(1) })
+	message := strings.Repeat("x", 200)
+	const goroutines = 8
+	var logged atomic.Uint64
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; rotations.Load() < 2000; n++ {
+				l.LogInfo("TestRotation", "rotate", fmt.Sprintf("%d %d %s", g, n, message))
+				logged.Add(1)
+			}
+		}(g)
+	}
+	wg.Wait()
+	stats := l.Stats()
+	if stats.Dropped != 0 {
+		t.Fatalf("%d of %d entries could not be written during %d rotations", stats.Dropped, logged.Load(), rotations.Load())
+	}
+	if stats.Written < logged.Load() {
+		t.Fatalf("%d of %d entries written during %d rotations", stats.Written, logged.Load(), rotations.Load())
+	}
+	l.LogInfo("TestRotation", "rotate", "after the load")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "after the load") {
+		t.Fatal("entry logged after the rotations is missing from the log file")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if strings.Contains(line, " test.rotate ") && !strings.HasSuffix(line, message) && !strings.HasSuffix(line, "after the load") {
+			t.Fatalf("garbled line %q", line)
+		}
+	}
+}
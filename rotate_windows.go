@@ -0,0 +1,113 @@
+//go:build windows
+
+package servicelogger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Windows refuses to rename a file that is open, in this process or in another one such as a tailer or a virus
+// scanner. Renames are retried this many times, with a backoff that starts at rotationBackoff and doubles
+const (
+	rotationAttempts = 5
+	rotationBackoff  = 20 * time.Millisecond
+)
+
+// renameFile renames a rotated segment of the log file, retrying while another process holds it open
+func renameFile(from string, to string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	return retryRotation(func() error {
+		return os.Rename(from, to)
+	})
+}
+
+// rotateCurrent moves the log file to segment and opens a new log file. The log file and its index are closed first,
+// because Windows cannot rename them while they are open. When another process keeps the log file open, its contents
+// are copied to segment and it is truncated instead. Rotation never exits the process: when the log file cannot be
+// opened again, an error is returned and the entries are written to segment, or counted as dropped when it cannot be
+// opened either, until the next rotation
+func (l *Logger) rotateCurrent(segment string) error {
+	l.filehandle.Close()
+	if l.index != nil {
+		_ = l.index.idx.Close()
+	}
+	renamed := renameFile(l.filename, segment)
+	var fh *os.File
+	err := retryRotation(func() error {
+		var err error
+		fh, err = os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0640)
+		return err
+	})
+	if err != nil {
+		l.reopenFallback(segment, renamed == nil)
+		return fmt.Errorf("unable to open log file %s: %w", l.filename, err)
+	}
+	l.filehandle = fh
+	if renamed == nil {
+		return nil
+	}
+	return copyTruncate(fh, segment)
+}
+
+// retryRotation calls f until it succeeds or the attempts are used up
+func retryRotation(f func() error) error {
+	backoff := rotationBackoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= rotationAttempts {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// copyTruncate copies the contents of the open log file to segment and truncates the log file
+func copyTruncate(fh *os.File, segment string) error {
+	out, err := os.OpenFile(segment, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Seek(0, io.SeekStart); err == nil {
+		_, err = io.Copy(out, fh)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(segment)
+		return err
+	}
+	return fh.Truncate(0)
+}
+
+// reopenFallback reopens segment when the log file was renamed to it, or else the log file, as the handle to write to
+// after the log file could not be opened again, so that the closed handle is not used. The index, closed by
+// rotateCurrent, is not reopened for it
+func (l *Logger) reopenFallback(segment string, renamed bool) {
+	name := l.filename
+	if renamed {
+		name = segment
+	}
+	fh, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		l.filehandle = nil
+		l.base = log.New(errorWriter{err}, "", 0)
+		return
+	}
+	l.filehandle = fh
+	l.index = nil
+	if l.buffer != nil {
+		l.buffer.retarget(fh)
+	}
+	l.base = log.New(l.output(), "", 0)
+}
@@ -0,0 +1,91 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// rotationCheck checks the rotation conditions of the log file in the background instead of on every write
+type rotationCheck struct {
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  bool // guarded by fileMu, set once the log file is closed
+}
+
+// EnableRotationCheck checks whether the log file must be rotated, or recreated because it was deleted, at every
+// interval on a background goroutine, instead of on every write, which saves the stat of the log file per entry. The
+// log file may grow past the rotation size by what is logged during one interval. Rotation hooks are called on the
+// background goroutine. Shared log files are checked by their writers, and FIFOs and memory logs are never rotated.
+// EnableRotationCheck must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableRotationCheck(interval time.Duration) error {
+	if l.rotationCheck != nil {
+		return errors.New("rotation check is already enabled")
+	}
+	if l.memory != nil || l.fifo != nil {
+		return errors.New("only a log file can be checked for rotation")
+	}
+	if l.shared != nil {
+		return errors.New("a shared log file is checked for rotation by its writers")
+	}
+	if interval <= 0 {
+		return errors.New("rotation check interval too low (>0)")
+	}
+	c := &rotationCheck{interval: interval, stop: make(chan struct{})}
+	l.rotationCheck = c
+	l.drainers = append(l.drainers, c)
+	go l.watchRotation(c)
+	return nil
+}
+
+// watchRotation checks the rotation conditions at every interval until the Logger is closed or shut down
+func (l *Logger) watchRotation(c *rotationCheck) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		if segment := l.checkRotation(c); segment != "" {
+			l.rotated(segment)
+		}
+	}
+}
+
+// checkRotation recreates a deleted log file and rotates the log file when needed, and returns the segment it was
+// rotated to or an empty string. A log file closed for being idle is left alone, as nothing is written to it
+func (l *Logger) checkRotation(c *rotationCheck) string {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if c.stopped || l.filehandle == nil {
+		return ""
+	}
+	if err := l.recoverDeleted(); err != nil {
+		l.writeInternalLocked(LL_ERROR, "checkRotation", "Unable to recreate deleted log file "+l.filename+": "+err.Error())
+	}
+	segment, err := l.logRotate()
+	if err != nil {
+		l.writeInternalLocked(LL_ERROR, "checkRotation", "Log rotation error: "+err.Error())
+	}
+	return segment
+}
+
+// close stops the checks. It is called with fileMu held, when the log file is closed
+func (c *rotationCheck) close() {
+	c.stopped = true
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// Drain stops the checks
+func (c *rotationCheck) Drain(ctx context.Context) (int, error) {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	return 0, nil
+}
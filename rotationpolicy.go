@@ -0,0 +1,137 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// RotationSchedule is a schedule at whose boundaries the log file is rotated
+type RotationSchedule int
+
+const (
+	RS_HOURLY  RotationSchedule = 1 // at the start of every hour
+	RS_DAILY   RotationSchedule = 2 // at midnight
+	RS_WEEKLY  RotationSchedule = 3 // at midnight between Sunday and Monday
+	RS_MONTHLY RotationSchedule = 4 // at midnight on the first day of the month
+)
+
+// RotationPolicy adds a schedule and retention limits to the size based rotation of New. Zero members do not apply
+type RotationPolicy struct {
+	Schedule     RotationSchedule // rotates at the boundaries of the schedule as well as at the rotation size
+	MaxAge       time.Duration    // rotated segments last written longer ago are removed
+	MaxTotalSize int64            // the oldest rotated segments are removed while the log takes more bytes than this
+}
+
+// rotationPolicy is the RotationPolicy of a Logger with the next boundary of its schedule, guarded by fileMu
+type rotationPolicy struct {
+	RotationPolicy
+	next time.Time
+}
+
+// SetRotationPolicy composes the rotation triggers of the Logger: the log file is rotated when it reaches the rotation
+// size or at the next boundary of the schedule, whichever comes first. An empty log file is not rotated at a boundary.
+// The retention limits apply after every rotation to the segments of either trigger alike, on top of the number of
+// segments to keep, always removing the oldest segments first. Boundaries are in local time and are checked on the next
+// write, or at the interval of EnableRotationCheck. A log file last written before the current period is rotated on the
+// first check. The policy applies while rotation is enabled; a zero RotationPolicy removes it
+func (l *Logger) SetRotationPolicy(policy RotationPolicy) error {
+	if policy.Schedule < 0 || policy.Schedule > RS_MONTHLY {
+		return fmt.Errorf("unknown rotation schedule %d", policy.Schedule)
+	}
+	if policy.MaxAge < 0 || policy.MaxTotalSize < 0 {
+		return errors.New("retention limits cannot be negative")
+	}
+	if l.memory != nil || l.fifo != nil {
+		return errors.New("only a log file can be rotated")
+	}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if policy == (RotationPolicy{}) {
+		l.rotationPolicy = nil
+		return nil
+	}
+	p := &rotationPolicy{RotationPolicy: policy}
+	if p.Schedule != 0 {
+		last := l.now()
+		if info, err := os.Stat(l.filename); err == nil && info.Size() > 0 {
+			last = info.ModTime()
+		}
+		p.next = nextBoundary(p.Schedule, last)
+	}
+	l.rotationPolicy = p
+	return nil
+}
+
+// due reports whether the boundary of the schedule has been reached at now
+func (p *rotationPolicy) due(now time.Time) bool {
+	return p != nil && p.Schedule != 0 && !now.Before(p.next)
+}
+
+// advance moves the next boundary past now
+func (p *rotationPolicy) advance(now time.Time) {
+	if p != nil && p.Schedule != 0 {
+		p.next = nextBoundary(p.Schedule, now)
+	}
+}
+
+// nextBoundary returns the first boundary of schedule after t
+func nextBoundary(schedule RotationSchedule, t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch schedule {
+	case RS_HOURLY:
+		return time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+	case RS_WEEKLY:
+		days := (8 - int(t.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(year, month, day+days, 0, 0, 0, 0, t.Location())
+	case RS_MONTHLY:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
+
+// pruneSegments removes the rotated segments beyond the retention limits of the policy, with their indexes. Segments
+// are numbered from new to old, so the limits always cut off the numbers from the first segment that exceeds them.
+// It is called with fileMu held, after rotating
+func (l *Logger) pruneSegments(now time.Time) {
+	p := l.rotationPolicy
+	if p == nil || (p.MaxAge == 0 && p.MaxTotalSize == 0) {
+		return
+	}
+	var total int64
+	if info, err := os.Stat(l.filename); err == nil {
+		total = info.Size()
+	}
+	cut := 0
+	for n := 1; n <= l.keep; n++ {
+		info, err := os.Stat(fmt.Sprintf("%s.%d", l.filename, n))
+		if err != nil {
+			break
+		}
+		total += info.Size()
+		if (p.MaxAge > 0 && now.Sub(info.ModTime()) > p.MaxAge) || (p.MaxTotalSize > 0 && total > p.MaxTotalSize) {
+			cut = n
+			break
+		}
+	}
+	if cut == 0 {
+		return
+	}
+	removed := 0
+	for n := cut; n <= l.keep; n++ {
+		segment := fmt.Sprintf("%s.%d", l.filename, n)
+		if err := os.Remove(segment); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				l.writeInternalLocked(LL_ERROR, "pruneSegments", fmt.Sprintf("Unable to remove %s: %s", segment, err.Error()))
+			}
+			continue
+		}
+		_ = os.Remove(segment + indexSuffix)
+		removed++
+	}
+	l.writeInternalLocked(LL_TRACE, "pruneSegments", fmt.Sprintf("Removed %d rotated segments beyond the retention limits", removed))
+}
@@ -0,0 +1,83 @@
+package servicelogger
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type SanitizeMode int
+
+const (
+	// SM_NONE writes messages as they are
+	SM_NONE SanitizeMode = 1
+	// SM_ESCAPE escapes newlines and all other control characters, e.g. "\n" and "\x1b"
+	SM_ESCAPE SanitizeMode = 2
+	// SM_STRIP escapes newlines, replaces tabs by spaces and removes all other control characters, including complete
+	// ANSI escape sequences
+	SM_STRIP SanitizeMode = 3
+)
+
+// SetSanitizeMode sets how control characters in the function, source and text of entries are handled. Without
+// sanitizing, a message containing a newline can forge additional log lines. The default is SM_ESCAPE
+func (l *Logger) SetSanitizeMode(mode SanitizeMode) {
+	l.sanitize = mode
+}
+
+// sanitizeString applies the SanitizeMode to s
+func sanitizeString(mode SanitizeMode, s string) string {
+	if mode == SM_NONE || !needsSanitizing(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t' && mode == SM_ESCAPE:
+			b.WriteString(`\t`)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r == 0x1b && mode == SM_STRIP:
+			size += ansiSequenceLength(s[i+size:])
+		case unicode.IsControl(r) && mode == SM_STRIP:
+			// dropped
+		case unicode.IsControl(r) && r < 0x80:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func needsSanitizing(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ansiSequenceLength returns the length of the remainder of an ANSI CSI escape sequence (ESC [ parameters final-byte)
+// at the start of s, not including the ESC itself
+func ansiSequenceLength(s string) int {
+	if len(s) == 0 || s[0] != '[' {
+		return 0
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,305 @@
+package servicelogger
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SentryConfig configures the forwarding of entries to Sentry. Zero members get their default
+type SentryConfig struct {
+	DSN         string        // Sentry DSN of the project, e.g. https://key@o1.ingest.sentry.io/42
+	Environment string        // environment reported with every event
+	Release     string        // release reported with every event
+	MinLevel    LogLevel      // lowest level forwarded, defaults to LL_ERROR
+	RateLimit   int           // maximum number of events sent per minute, defaults to 60
+	QueueSize   int           // number of events waiting to be sent before new ones are dropped, defaults to 100
+	Timeout     time.Duration // timeout of a single request to Sentry, defaults to 5 seconds
+	Retry       RetryPolicy   // retry policy of failed requests
+}
+
+// SentryStats holds the counters of a SentryForwarder
+type SentryStats struct {
+	Sent    uint64 // events accepted by Sentry
+	Retried uint64 // requests that were repeated after a failure
+	Dropped uint64 // events dropped because of the rate limit or a full queue
+	Failed  uint64 // events Sentry could not be reached for or did not accept
+}
+
+// SentryForwarder sends entries to Sentry as events from a background goroutine
+type SentryForwarder struct {
+	config   SentryConfig
+	endpoint string
+	auth     string
+	client   *http.Client
+	queue    chan []byte
+	stopped  chan struct{}
+	mu       sync.Mutex
+	tokens   float64
+	refilled time.Time
+	now      func() time.Time // clock of the Logger
+	sent     atomic.Uint64
+	retried  atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+// EnableSentry forwards entries at or above the configured level to Sentry, including the stack of the logging
+// goroutine and the fields of the entry. Events are sent asynchronously and rate limited, except for FATAL entries
+// which are sent before LogFatal exits
+func (l *Logger) EnableSentry(config SentryConfig) (*SentryForwarder, error) {
+	dsn, err := url.Parse(config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %s", err.Error())
+	}
+	project := strings.Trim(dsn.Path, "/")
+	if dsn.User == nil || dsn.User.Username() == "" || project == "" {
+		return nil, errors.New("invalid Sentry DSN: missing public key or project")
+	}
+	if config.MinLevel == 0 {
+		config.MinLevel = LL_ERROR
+	}
+	if config.RateLimit <= 0 {
+		config.RateLimit = 60
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	f := &SentryForwarder{
+		config:   config,
+		endpoint: fmt.Sprintf("%s://%s/api/%s/envelope/", dsn.Scheme, dsn.Host, project),
+		auth:     fmt.Sprintf("Sentry sentry_version=7, sentry_client=servicelogger/1.0, sentry_key=%s", dsn.User.Username()),
+		client:   &http.Client{Timeout: config.Timeout},
+		queue:    make(chan []byte, config.QueueSize),
+		stopped:  make(chan struct{}),
+		tokens:   float64(config.RateLimit),
+		refilled: l.now(),
+		now:      l.now,
+	}
+	go f.run(f.queue)
+	l.drainers = append(l.drainers, f)
+	l.AddBeforeWriteHook(f.forward)
+	return f, nil
+}
+
+// Stats returns the counters of the forwarder
+func (f *SentryForwarder) Stats() SentryStats {
+	return SentryStats{Sent: f.sent.Load(), Retried: f.retried.Load(), Dropped: f.dropped.Load(), Failed: f.failed.Load()}
+}
+
+// Close sends the queued events and stops the forwarder. Entries logged after Close are not forwarded
+func (f *SentryForwarder) Close() {
+	_, _ = f.Drain(context.Background())
+}
+
+// Drain sends the queued events and stops the forwarder like Close, but gives up when ctx is done. It returns the
+// number of events that were not sent, which are counted as dropped
+func (f *SentryForwarder) Drain(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	queue := f.queue
+	if queue != nil {
+		close(queue)
+		f.queue = nil
+	}
+	f.mu.Unlock()
+	select {
+	case <-f.stopped:
+		return 0, nil
+	case <-ctx.Done():
+		left := len(queue)
+		f.dropped.Add(uint64(left))
+		return left, ctx.Err()
+	}
+}
+
+func (f *SentryForwarder) run(queue chan []byte) {
+	defer close(f.stopped)
+	for envelope := range queue {
+		f.send(envelope)
+	}
+}
+
+func (f *SentryForwarder) forward(e *Entry) {
+	if !e.Level.atLeast(f.config.MinLevel) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queue == nil || !f.takeToken() {
+		f.dropped.Add(1)
+		return
+	}
+	envelope, err := f.envelope(e, captureStack())
+	if err != nil {
+		f.failed.Add(1)
+		return
+	}
+	if e.Level == LL_FATAL {
+		f.send(envelope)
+		return
+	}
+	select {
+	case f.queue <- envelope:
+	default:
+		f.dropped.Add(1)
+	}
+}
+
+// takeToken implements the rate limit as a token bucket that holds at most RateLimit tokens. It must be called with
+// f.mu held
+func (f *SentryForwarder) takeToken() bool {
+	now := f.now()
+	f.tokens += now.Sub(f.refilled).Minutes() * float64(f.config.RateLimit)
+	if f.tokens > float64(f.config.RateLimit) {
+		f.tokens = float64(f.config.RateLimit)
+	}
+	f.refilled = now
+	if f.tokens < 1 {
+		return false
+	}
+	f.tokens--
+	return true
+}
+
+func (f *SentryForwarder) send(envelope []byte) {
+	attempts, err := f.config.Retry.retry(context.Background(), func() error {
+		return f.post(envelope)
+	})
+	f.retried.Add(uint64(attempts - 1))
+	if err != nil {
+		f.failed.Add(1)
+		return
+	}
+	f.sent.Add(1)
+}
+
+func (f *SentryForwarder) post(envelope []byte) error {
+	req, err := http.NewRequest(http.MethodPost, f.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", f.auth)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return responseError(resp)
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// envelope builds a Sentry envelope holding a single event for e
+func (f *SentryForwarder) envelope(e *Entry, frames []sentryFrame) ([]byte, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	eventID := hex.EncodeToString(id)
+	level := "error"
+	if e.Level == LL_FATAL {
+		level = "fatal"
+	}
+	extra := make(map[string]string, len(e.Fields))
+	for key, value := range e.Fields {
+		extra[key] = fmt.Sprint(value)
+	}
+	event := map[string]interface{}{
+		"event_id":  eventID,
+		"timestamp": e.Time.UTC().Format(time.RFC3339Nano),
+		"level":     level,
+		"platform":  "go",
+		"logger":    e.Prefix,
+		"message":   map[string]string{"formatted": e.Message},
+		"tags":      map[string]string{"source": e.Source, "function": e.Function, "facility": e.Facility()},
+		"extra":     extra,
+	}
+	if f.config.Environment != "" {
+		event["environment"] = f.config.Environment
+	}
+	if f.config.Release != "" {
+		event["release"] = f.config.Release
+	}
+	if len(frames) > 0 {
+		event["threads"] = map[string]interface{}{
+			"values": []map[string]interface{}{{"current": true, "crashed": e.Level == LL_FATAL, "stacktrace": map[string]interface{}{"frames": frames}}},
+		}
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	err = enc.Encode(map[string]string{"event_id": eventID, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)})
+	if err == nil {
+		err = enc.Encode(map[string]string{"type": "event"})
+	}
+	if err == nil {
+		err = enc.Encode(event)
+	}
+	return b.Bytes(), err
+}
+
+// captureStack returns the stack of the calling goroutine without the frames of this package, oldest frame first as
+// Sentry expects
+func captureStack() []sentryFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var result []sentryFrame
+	for {
+		frame, more := frames.Next()
+		module, function := splitFunctionName(frame.Function)
+		if module != packagePath {
+			result = append(result, sentryFrame{
+				Function: function,
+				Module:   module,
+				Filename: frame.File[strings.LastIndex(frame.File, "/")+1:],
+				AbsPath:  frame.File,
+				Lineno:   frame.Line,
+				InApp:    !strings.HasPrefix(module, "runtime") && strings.Contains(module, "."),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// packagePath is the import path of this package, used to hide its own frames from stack traces
+const packagePath = "github.com/quadtrix/servicelogger"
+
+// splitFunctionName splits a fully qualified function name like example.com/pkg.(*T).Method into its package path and
+// the function name within the package
+func splitFunctionName(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
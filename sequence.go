@@ -0,0 +1,26 @@
+package servicelogger
+
+import "sync"
+
+// sequencer numbers the entries of a Logger. The mutex is held from numbering an entry until it is written or queued,
+// so that the numbers in the log file are strictly increasing
+type sequencer struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+// EnableSequenceNumbers stamps every written entry with the field seq, a number that increases by one for every entry
+// of the Logger and its child loggers, starting at 1. Entries dropped by filters or interceptors are not numbered, so
+// every gap in the sequence of a log file means entries were lost after they were logged. EnableSequenceNumbers must
+// be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableSequenceNumbers() {
+	if l.sequence == nil {
+		l.sequence = &sequencer{}
+	}
+}
+
+// next numbers e. It must be called with s.mu held
+func (s *sequencer) next(e *Entry) {
+	s.last++
+	e.SetField("seq", s.last)
+}
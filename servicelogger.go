@@ -2,6 +2,8 @@
 package servicelogger
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +11,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type LogLevel int
@@ -20,29 +25,118 @@ const (
 	LL_WARN  LogLevel = 4
 	LL_ERROR LogLevel = 5
 	LL_FATAL LogLevel = 6
+	LL_PANIC LogLevel = 7
 )
 
+// highestLevel is the LogLevel with the highest value, which is not the most severe one
+const highestLevel = LL_PANIC
+
+// Severity returns the rank of the level, from 1 for LL_TRACE up to 7 for LL_FATAL. LL_PANIC was added after LL_FATAL
+// and ranks below it, so levels must be ordered by their severity rather than by their value
+func (level LogLevel) Severity() int {
+	switch level {
+	case LL_PANIC:
+		return 6
+	case LL_FATAL:
+		return 7
+	}
+	return int(level)
+}
+
+// atLeast reports whether the level is as severe as threshold or more
+func (level LogLevel) atLeast(threshold LogLevel) bool {
+	return level.Severity() >= threshold.Severity()
+}
+
+// textTimeLayout is the layout of the timestamp at the start of LF_TEXT lines, which is followed by six decimals of the
+// seconds, or nine with TimestampConfig.Nanoseconds
+const textTimeLayout = "2006/01/02 15:04:05"
+
+// Logger writes entries to a log file. Copies of a Logger and the child loggers returned by WithContext share the log
+// file, filters and settings of the Logger they were made from
 type Logger struct {
-	base             *log.Logger
-	prefix           string
-	MinLoglevel      LogLevel
-	filename         string
-	rotate           bool
-	rotatesize       int64
-	keep             int
-	filehandle       *os.File
-	rotation_running bool
-	filters          FacilityFilters
+	*loggerState
+	ctx context.Context
+}
+
+// loggerState holds everything shared between a Logger, its copies and its child loggers
+type loggerState struct {
+	base           *log.Logger
+	prefix         string
+	MinLoglevel    LogLevel
+	filename       string
+	rotate         bool
+	rotatesize     int64
+	keep           int
+	filehandle     *os.File
+	filters        FacilityFilters
+	memory         *memoryBuffer
+	recorder       atomic.Pointer[recorder]
+	sanitize       SanitizeMode
+	format         LogFormat
+	textLayout     *TextLayout
+	multiline      MultilineMode
+	continuation   string
+	enrich         *enrichment
+	pools          *entryPools
+	stackTraces    *stackTraces
+	globalFields   map[string]string
+	fieldProviders []FieldProvider
+	sequence       *sequencer
+	redact         *redactor
+	maskers        []*piiMasker
+	sinks          []*sink
+	fileFields     FieldFilter
+	ring           *ringBuffer
+	indexed        bool
+	index          *indexWriter
+	stats          *loggerStats
+	async          *asyncWriter
+	backpressure   BackpressureConfig
+	interceptors   []Interceptor
+	beforeHooks    []BeforeWriteHook
+	afterHooks     []AfterWriteHook
+	rotationHooks  []RotationHook
+	drainers       []Drainer
+	wal            *wal
+	audit          *auditLog
+	errorFile      *errorFile
+	stderrLevel    LogLevel
+	onceKeys       onceKeys
+	slowOperation  time.Duration
+	kubernetes     map[string]string
+	fifo           *fifoWriter
+	shared         *sharedFile
+	facilityStats  *facilityStats
+	governor       *governor
+	burst          *burstBuffer
+	flightRecorder *flightRecorder
+	crashDump      *crashDump
+	buffer         *writeBuffer
+	idle           *idleFile
+	redirect       atomic.Pointer[redirect]
+	outputs        []*output
+	fileMu         sync.Mutex // serializes the writes to the log file with rotating and switching it
+	deletedChecked time.Time  // last check of checkDeleted, guarded by fileMu
+	rotationCheck  *rotationCheck
+	rotationPolicy *rotationPolicy // guarded by fileMu
+	clock          Clock
+	timestamps     *timestamps
+	dedup          *deduplicator
+	reentry        reentrancyGuard
+	shutdown       atomic.Bool
 }
 
 type FacilityFilter struct {
-	filter string
-	level  LogLevel
+	filter   string
+	level    LogLevel
+	segments bool
 }
 
 type FacilityFilters struct {
 	count   int
 	filters []FacilityFilter
+	rules   []filterRule
 }
 
 func logSizeStringToLogSizeInt64(lss string) (l int64, err error) {
@@ -66,8 +160,11 @@ func logSizeStringToLogSizeInt64(lss string) (l int64, err error) {
 	return l, nil
 }
 
-// New returns a new Logger object
+// New returns a new Logger object. When filename is an existing named pipe, the output is written to whichever reader
+// has it open; output is kept in a buffer of 1 MiB while there is no reader or it is not keeping up, and the FIFO is
+// never rotated
 func New(prefix string, filename string, minloglevel LogLevel, rotate bool, rotatesize string, keep int) (l Logger, err error) {
+	l.loggerState = &loggerState{}
 	l.filename = filename
 	l.rotate = rotate
 	if keep < 2 && rotate {
@@ -79,93 +176,257 @@ func New(prefix string, filename string, minloglevel LogLevel, rotate bool, rota
 	if err != nil {
 		log.Fatalf("FATAL: Incorrect log rotation size: %s", err.Error())
 	}
-	l.filehandle, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	err = l.openLogFile()
 	if err != nil {
 		log.Fatal("FATAL: Unable to open log file: " + err.Error())
 	}
 
 	l.MinLoglevel = minloglevel
-	l.base = log.New(l.filehandle, "", log.Ldate|log.Ltime|log.Lmicroseconds)
-	l.rotation_running = false
+	l.base, _ = l.newBase()
+	l.sanitize = SM_ESCAPE
+	l.format = LF_TEXT
+	l.stats = &loggerStats{}
 	return l, err
 }
 
 // LogTrace logs a message at TRACE level
 func (l *Logger) LogTrace(function string, source string, text string) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_TRACE {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogTrace", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("TRACE   [%s] %s.%s %s\n", function, l.prefix, source, text)
-	}
+	l.log(LL_TRACE, function, source, text, nil)
 }
 
 // LogDebug logs a message at DEBUG level
 func (l *Logger) LogDebug(function string, source string, text string) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_DEBUG {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogDebug", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("DEBUG   [%s] %s.%s %s\n", function, l.prefix, source, text)
-	}
+	l.log(LL_DEBUG, function, source, text, nil)
 }
 
 // LogInfo logs a message at INFO level
 func (l *Logger) LogInfo(function string, source string, text string) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_INFO {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogInfo", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("INFO    [%s] %s.%s %s\n", function, l.prefix, source, text)
-	}
+	l.log(LL_INFO, function, source, text, nil)
 }
 
 // LogWarn logs a message at WARNING level
 func (l *Logger) LogWarn(function string, source string, text string) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_WARN {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogWarn", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("WARNING [%s] %s.%s %s\n", function, l.prefix, source, text)
-	}
+	l.log(LL_WARN, function, source, text, nil)
 }
 
 // LogError logs a message at ERROR level
 func (l *Logger) LogError(function string, source string, text string) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_ERROR {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogError", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("ERROR   [%s] %s.%s %s\n", function, l.prefix, source, text)
+	l.log(LL_ERROR, function, source, text, nil)
+}
+
+// LogPanic logs a message at PANIC level and then panics with the message, so that deferred functions run and callers
+// can recover. The Logger is flushed before the panic. Unlike LogFatal it panics even when the message is filtered out
+func (l *Logger) LogPanic(function string, source string, text string) {
+	if l.log(LL_PANIC, function, source, text, nil) {
+		l.Flush()
 	}
+	panic(text)
 }
 
-// LogFata logs a message at FATAL level and exits the application with the provided exit code
+// LogFata logs a message at FATAL level and exits the application with the provided exit code. With EnableCrashDump
+// the stacks of all goroutines are captured with the entry
 func (l *Logger) LogFatal(function string, source string, text string, exitcode int) {
-	if l.getFilteredLogLevel(fmt.Sprintf("%s.%s.%s", l.prefix, source, function)) <= LL_FATAL {
-		newbase, err := l.logRotate()
-		if err != nil {
-			l.LogError("LogFatal", "servicelogger", fmt.Sprintf("Log rotation error: %s", err.Error()))
-		}
-		l.base = newbase
-		l.base.Printf("FATAL   [%s] %s.%s %s\n", function, l.prefix, source, text)
+	if l.log(LL_FATAL, function, source, text, nil) {
+		l.Flush()
 		fmt.Printf("FATAL: [%s] %s.%s %s\n", function, l.prefix, source, text)
 		os.Exit(exitcode)
 	}
 }
 
+// writeEntry formats a single entry and writes it to the log file, or queues it when the Logger is asynchronous. Level
+// filtering and interceptors are handled by the caller
+func (l *Logger) writeEntry(e *Entry) {
+	if l.shutdown.Load() {
+		l.countWrite(errShutdown)
+		return
+	}
+	if l.redact != nil {
+		l.redact.apply(e)
+	}
+	for _, masker := range l.maskers {
+		masker.apply(e)
+	}
+	if l.sequence != nil {
+		l.sequence.mu.Lock()
+		defer l.sequence.mu.Unlock()
+		l.sequence.next(e)
+	}
+	l.beforeWrite(e)
+	if l.facilityStats != nil {
+		l.facilityStats.count(e)
+	}
+	if l.wal != nil && l.wal.matches(e) {
+		if err := l.wal.append(e); err != nil && l.stats != nil {
+			l.stats.walFailed.Add(1)
+		}
+	}
+	if l.audit != nil && facilityMatches(l.audit.facilities, e.Facility()) {
+		if err := l.audit.write(e); err != nil && l.stats != nil {
+			l.stats.auditFailed.Add(1)
+		}
+	}
+	line := l.formatLine(l.fileFields.apply(e))
+	l.record(e)
+	if l.ring != nil {
+		l.ring.add(e)
+	}
+	if l.async != nil {
+		if dropped := l.async.enqueue(line, e, l.backpressurePolicy(e.Level)); dropped > 0 {
+			l.countQueueDrop(dropped)
+		}
+		return
+	}
+	l.writeFile(e, line)
+	l.writeSinks(e)
+}
+
+// writeFile writes the line of an entry to the log file, to the errors file and stderr when its level is high enough,
+// and to the outputs
+func (l *Logger) writeFile(e *Entry, line string) {
+	l.afterWrite(e, l.writeLine(line))
+	if l.errorFile != nil {
+		l.errorFile.write(e, line)
+	}
+	if l.stderrLevel != 0 && e.Level.atLeast(l.stderrLevel) {
+		_, _ = os.Stderr.WriteString(line)
+	}
+	if len(l.outputs) > 0 {
+		l.writeOutputs(e, line)
+	}
+}
+
+// MirrorToStderr writes the entries at or above level to stderr as well as to the log file, so that journald and
+// container runtimes capture serious problems even when nobody looks at the log file. LL_ERROR is a common choice, 0
+// turns mirroring off. MirrorToStderr must be called before the Logger is used from multiple goroutines
+func (l *Logger) MirrorToStderr(level LogLevel) {
+	l.stderrLevel = level
+}
+
+// formatLine returns the line written to the log file for an entry, including the timestamp
+func (l *Logger) formatLine(e *Entry) string {
+	return l.formatEntryAs(e, l.format)
+}
+
+// formatEntry returns the line for an entry in the provided format, sanitizing text in LF_TEXT format
+func formatEntry(e *Entry, format LogFormat, sanitize SanitizeMode) string {
+	switch format {
+	case LF_JSON:
+		return formatJSON(e, nil)
+	case LF_LOGFMT:
+		return formatLogfmt(e, nil)
+	case LF_BINARY:
+		return formatBinary(e)
+	case LF_CLF, LF_COMBINED:
+		return formatAccess(e, format == LF_COMBINED, sanitize)
+	}
+	return formatText(e, sanitize, &defaultTextLayout, nil)
+}
+
+// writeLine writes a formatted line to the log file, rotating it first when needed, and recreating it when it was
+// deleted, unless EnableRotationCheck moved these checks to the background. A log file closed for being idle is opened
+// first. For a shared log file the lock is held throughout, and the log file is reopened first when another process has
+// rotated it. Writes are serialized with rotation and switching the log file by fileMu; rotation hooks are called once
+// it is released, so that entries they log do not wait for it
+func (l *Logger) writeLine(line string) error {
+	if redirected, err := l.writeRedirected(line); redirected {
+		l.countWrite(err)
+		return err
+	}
+	l.fileMu.Lock()
+	segment, err := l.writeLineLocked(line)
+	l.fileMu.Unlock()
+	if segment != "" {
+		l.rotated(segment)
+	}
+	return err
+}
+
+// writeLineLocked writes line as writeLine does, with fileMu held, and returns the segment the log file was rotated to
+// or an empty string
+func (l *Logger) writeLineLocked(line string) (string, error) {
+	if l.idle != nil {
+		if err := l.acquireFile(); err != nil {
+			l.countWrite(err)
+			return "", err
+		}
+		defer l.releaseFile()
+	}
+	if l.shared != nil {
+		if err := l.shared.acquire(); err != nil {
+			l.countWrite(err)
+			return "", err
+		}
+		defer l.shared.release()
+		if err := l.reopenRotated(); err != nil {
+			l.writeInternalLocked(LL_ERROR, "writeLine", fmt.Sprintf("Unable to reopen shared log file: %s", err.Error()))
+		}
+	}
+	var segment string
+	if l.rotationCheck == nil {
+		l.checkDeleted(l.now())
+		var err error
+		segment, err = l.logRotate()
+		if err != nil {
+			l.writeInternalLocked(LL_ERROR, "writeLine", fmt.Sprintf("Log rotation error: %s", err.Error()))
+		}
+	}
+	err := l.base.Output(2, line)
+	l.countWrite(err)
+	return segment, err
+}
+
+// writeInternal writes a message about the Logger itself straight to the current log file. It is used from within
+// writeLine, where going through writeEntry again would queue the message behind the entry being written, and for
+// messages that must not go through the hooks, interceptors and sinks
+func (l *Logger) writeInternal(level LogLevel, function string, text string) {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	l.writeInternalLocked(level, function, text)
+}
+
+// writeInternalLocked writes a message as writeInternal does, with fileMu held
+func (l *Logger) writeInternalLocked(level LogLevel, function string, text string) {
+	if !level.atLeast(l.getFilteredLogLevel(string(newFacility(l.prefix, "servicelogger", function)))) {
+		return
+	}
+	e := &Entry{Time: l.now(), Level: level, Prefix: l.prefix, Source: "servicelogger", Function: function, Message: text}
+	if l.timestamps != nil {
+		l.timestamps.stamp(e)
+	}
+	line := l.formatLine(e)
+	if redirected, err := l.writeRedirected(line); redirected {
+		l.countWrite(err)
+		return
+	}
+	if l.idle != nil {
+		if err := l.acquireFile(); err != nil {
+			l.countWrite(err)
+			return
+		}
+		defer l.releaseFile()
+	}
+	err := l.base.Output(2, line)
+	l.countWrite(err)
+}
+
+// levelLabel returns the label used for the LogLevel in the log file
+func levelLabel(level LogLevel) string {
+	if label, ok := levelLabels[level]; ok {
+		return label
+	}
+	if level == LL_WARN {
+		return "WARNING"
+	}
+	return LogLevelToString(level)
+}
+
 // StringToLogLevel returns a LogLevel for a provided string. When the string cannot be recognised, LL_INFO is returned
 func StringToLogLevel(text string) LogLevel {
+	for level, label := range levelLabels {
+		if strings.EqualFold(label, text) {
+			return level
+		}
+	}
 	switch text {
 	case "TRACE", "Trace", "trace":
 		return LL_TRACE
@@ -177,6 +438,8 @@ func StringToLogLevel(text string) LogLevel {
 		return LL_WARN
 	case "ERROR", "Error", "error":
 		return LL_ERROR
+	case "PANIC", "Panic", "panic":
+		return LL_PANIC
 	case "FATAL", "Fatal", "fatal":
 		return LL_FATAL
 	default:
@@ -186,6 +449,14 @@ func StringToLogLevel(text string) LogLevel {
 
 // LogLevelToString returns a string representation of the LogLevel
 func LogLevelToString(level LogLevel) string {
+	if label, ok := levelLabels[level]; ok {
+		return label
+	}
+	return defaultLevelLabel(level)
+}
+
+// defaultLevelLabel returns the label of level when SetLevelLabels does not override it
+func defaultLevelLabel(level LogLevel) string {
 	switch level {
 	case LL_TRACE:
 		return "TRACE"
@@ -197,6 +468,8 @@ func LogLevelToString(level LogLevel) string {
 		return "WARN"
 	case LL_ERROR:
 		return "ERROR"
+	case LL_PANIC:
+		return "PANIC"
 	case LL_FATAL:
 		return "FATAL"
 	default:
@@ -204,85 +477,158 @@ func LogLevelToString(level LogLevel) string {
 	}
 }
 
-func (l *Logger) logRotate() (nbase *log.Logger, err error) {
-	if l.rotate && !l.rotation_running {
-		l.rotation_running = true
-		//l.LogTrace("logRotate", "servicelogger", "Starting log rotation check")
-		filestats, err := os.Stat(l.filename)
-		if err != nil {
-			return l.base, err
+// logRotate rotates the log file when it has reached the rotation size or a boundary of the schedule of the
+// RotationPolicy, applies the retention limits of the policy, and returns the segment the log file was rotated to, for
+// the rotation hooks, or an empty string. It is called with fileMu held, so that the size check, the renames and
+// reopening the log file happen as one step that no write can interleave with, and no second rotation can start before
+// the first has finished. Its diagnostics are written with writeInternalLocked, which never rotates, and
+// its errors are returned to writeLine, which reports them the same way, never through the Log methods
+func (l *Logger) logRotate() (segment string, err error) {
+	if !l.rotate {
+		return "", nil
+	}
+	filestats, err := os.Stat(l.filename)
+	if errors.Is(err, os.ErrNotExist) && l.filehandle != nil && l.shared == nil {
+		// deleted since the last check of checkDeleted
+		if err = l.recoverDeleted(); err != nil {
+			return "", err
 		}
-		if filestats.Size() >= l.rotatesize {
-			l.LogTrace("logRotate", "servicelogger", "Rotating log, closing logwriter")
-			_, err = os.Stat(fmt.Sprintf("%s.%d", l.filename, l.keep))
-			if err == nil {
-				_ = os.Remove(fmt.Sprintf("%s.%d", l.filename, l.keep))
-			}
-			for i := l.keep - 1; i > 0; i-- {
-				_, err = os.Stat(fmt.Sprintf("%s.%d", l.filename, i))
-				if err == nil {
-					err = os.Rename(fmt.Sprintf("%s.%d", l.filename, i), fmt.Sprintf("%s.%d", l.filename, i+1))
-					if err != nil {
-						return l.base, err
-					}
-				}
-			}
-			err = os.Rename(l.filename, fmt.Sprintf("%s.1", l.filename))
-			if err != nil {
-				return l.base, err
-			}
-			l.filehandle, err = os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+		filestats, err = os.Stat(l.filename)
+	}
+	if err != nil {
+		return "", err
+	}
+	size := filestats.Size()
+	if l.buffer != nil {
+		size += int64(l.buffer.buffered())
+	}
+	now := l.now()
+	scheduled := l.rotationPolicy.due(now)
+	if scheduled && size == 0 {
+		l.rotationPolicy.advance(now)
+		scheduled = false
+	}
+	if size < l.rotatesize && !scheduled {
+		return "", nil
+	}
+	l.writeInternalLocked(LL_TRACE, "logRotate", "Rotating log, closing logwriter")
+	_, err = os.Stat(fmt.Sprintf("%s.%d", l.filename, l.keep))
+	if err == nil {
+		_ = os.Remove(fmt.Sprintf("%s.%d", l.filename, l.keep))
+		_ = os.Remove(fmt.Sprintf("%s.%d%s", l.filename, l.keep, indexSuffix))
+	}
+	for i := l.keep - 1; i > 0; i-- {
+		_, err = os.Stat(fmt.Sprintf("%s.%d", l.filename, i))
+		if err == nil {
+			err = renameFile(fmt.Sprintf("%s.%d", l.filename, i), fmt.Sprintf("%s.%d", l.filename, i+1))
 			if err != nil {
-				log.Fatalf("FATAL: Unable to open log file %s: %s", l.filename, err.Error())
+				return "", err
 			}
-			l.base = log.New(l.filehandle, "", log.Ldate|log.Ltime|log.Lmicroseconds)
-			l.LogTrace("logRotate", "servicelogger", "Log rotated, reopened logwriter")
+			_ = os.Rename(fmt.Sprintf("%s.%d%s", l.filename, i, indexSuffix), fmt.Sprintf("%s.%d%s", l.filename, i+1, indexSuffix))
 		}
-		l.rotation_running = false
 	}
-	return l.base, nil
+	l.flushBuffer()
+	err = l.rotateCurrent(fmt.Sprintf("%s.1", l.filename))
+	if err != nil {
+		return "", err
+	}
+	_ = renameFile(l.filename+indexSuffix, fmt.Sprintf("%s.1%s", l.filename, indexSuffix))
+	if l.clock != nil {
+		// so that the retention limits measure the age of segments by the clock of the Logger
+		_ = os.Chtimes(fmt.Sprintf("%s.1", l.filename), now, now)
+	}
+	l.base, err = l.newBase()
+	if err != nil {
+		l.writeInternalLocked(LL_ERROR, "logRotate", fmt.Sprintf("Unable to open log index: %s", err.Error()))
+	}
+	l.rotationPolicy.advance(now)
+	l.pruneSegments(now)
+	l.writeInternalLocked(LL_TRACE, "logRotate", "Log rotated, reopened logwriter")
+	return fmt.Sprintf("%s.1", l.filename), nil
 }
 
+// ApplyNewSettings applies changed settings, as passed to New, and reports whether any of them changed. The log file
+// and the rotation settings are swapped under the same lock as the writes, so that every entry logged meanwhile is
+// written to either the old or the new log file. A new log file is opened before the old one is closed; when it cannot
+// be opened, the error is logged and the Logger keeps writing to the old log file while the other settings are
+// applied. An invalid rotation size exits the application
 func (slog *Logger) ApplyNewSettings(newFile string, newLevel LogLevel, newRotation bool, newRotSize string, newKeep int) bool {
 	nrs, err := logSizeStringToLogSizeInt64(newRotSize)
 	if err != nil {
 		slog.LogFatal("ApplyNewSettings", "servicelogger", fmt.Sprintf("Failed to apply new settings: %s", err.Error()), 222)
 	}
-	if newFile != slog.filename || newLevel != slog.MinLoglevel || newRotation != slog.rotate || nrs != slog.rotatesize || newKeep != slog.keep {
-		slog.LogInfo("ApplyNewSettings", "servicelogger", "Logging configuration has changed, applying new configuration")
-		if newFile != slog.filename {
-			slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Filename has changed. Closing %s and continuing logging in %s", slog.filename, newFile))
-			slog.filehandle.Close()
-			slog.filename = newFile
-			slog.filehandle, err = os.OpenFile(slog.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
-			if err != nil {
-				log.Fatal("FATAL: Unable to open log file: " + err.Error())
-			}
-			slog.base = log.New(slog.filehandle, "", log.Ldate|log.Ltime|log.Lmicroseconds)
+	if newFile == slog.filename && newLevel == slog.MinLoglevel && newRotation == slog.rotate && nrs == slog.rotatesize && newKeep == slog.keep {
+		return false
+	}
+	slog.LogInfo("ApplyNewSettings", "servicelogger", "Logging configuration has changed, applying new configuration")
+	if newFile != slog.filename {
+		slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Filename has changed. Closing %s and continuing logging in %s", slog.filename, newFile))
+	}
+	if newLevel != slog.MinLoglevel {
+		slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Log level has changed: %s --> %s", LogLevelToString(slog.MinLoglevel), LogLevelToString(newLevel)))
+	}
+	if newRotation != slog.rotate {
+		if newRotation {
+			slog.LogTrace("ApplyNewSettings", "servicelogger", "Log rotation has been enabled")
+		} else {
+			slog.LogTrace("ApplyNewSettings", "servicelogger", "Log rotation has been disabled")
 		}
-		if newLevel != slog.MinLoglevel {
-			slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Log level has changed: %s --> %s", LogLevelToString(slog.MinLoglevel), LogLevelToString(newLevel)))
-			slog.MinLoglevel = newLevel
+	}
+	if nrs != slog.rotatesize {
+		slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Log size limit has changed: %d bytes --> %d bytes", slog.rotatesize, nrs))
+	}
+	if newKeep != slog.keep {
+		slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Number of log files to keep has changed: %d --> %d", slog.keep, newKeep))
+	}
+	slog.fileMu.Lock()
+	if newFile != slog.filename {
+		err = slog.switchLogFile(newFile)
+	}
+	slog.rotate = newRotation
+	slog.rotatesize = nrs
+	slog.keep = newKeep
+	slog.fileMu.Unlock()
+	if newLevel != slog.MinLoglevel {
+		slog.MinLoglevel = newLevel
+	}
+	if err != nil {
+		slog.writeInternal(LL_ERROR, "ApplyNewSettings", fmt.Sprintf("Unable to open log file %s, continuing in %s: %s", newFile, slog.filename, err.Error()))
+	}
+	return true
+}
+
+// switchLogFile continues logging in filename, with fileMu held. The new log file, and the lock file of a shared log
+// file, are opened before the old ones are closed, so that a failure leaves the Logger writing to the old log file, and
+// every old handle is closed exactly once
+func (l *Logger) switchLogFile(filename string) error {
+	fh, fifo, err := openLogTarget(filename)
+	if err != nil {
+		return err
+	}
+	var lock *os.File
+	if l.shared != nil {
+		if fifo == nil {
+			lock, err = os.OpenFile(filename+lockSuffix, os.O_CREATE|os.O_RDWR, 0640)
+		} else {
+			err = errors.New("a FIFO cannot be shared")
 		}
-		if newRotation != slog.rotate {
-			if newRotation {
-				slog.LogTrace("ApplyNewSettings", "servicelogger", "Log rotation has been enabled")
-			} else {
-				slog.LogTrace("ApplyNewSettings", "servicelogger", "Log rotation has been disabled")
+		if err != nil {
+			if fh != nil {
+				fh.Close()
 			}
-			slog.rotate = newRotation
-		}
-		if nrs != slog.rotatesize {
-			slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Log size limit has changed: %d bytes --> %d bytes", slog.rotatesize, nrs))
-			slog.rotatesize = nrs
+			return err
 		}
-		if newKeep != slog.keep {
-			slog.LogTrace("ApplyNewSettings", "servicelogger", fmt.Sprintf("Number of log files to keep has changed: %d --> %d", slog.keep, newKeep))
-			slog.keep = newKeep
-		}
-		return true
 	}
-	return false
+	_ = l.closeLogFile()
+	l.filename, l.filehandle, l.fifo = filename, fh, fifo
+	if lock != nil {
+		l.shared.replaceLock(lock)
+	}
+	var indexErr error
+	if l.base, indexErr = l.newBase(); indexErr != nil {
+		l.writeInternalLocked(LL_ERROR, "switchLogFile", fmt.Sprintf("Unable to open log index: %s", indexErr.Error()))
+	}
+	return nil
 }
 
 func (slog *Logger) AddFacilityFilter(filtername string, filterlevel LogLevel) {
@@ -294,28 +640,49 @@ func (slog *Logger) AddFacilityFilter(filtername string, filterlevel LogLevel) {
 	slog.filters.filters = append(slog.filters.filters, ffilter)
 }
 
+// LoadFacilityFilters adds the filters of a JSON file holding an object whose members map a facility prefix to its
+// level, such as "delta.db": "DEBUG". Members whose name is a filter expression, such as "delta.db.* AND level >= WARN",
+// add a rule with AddFilterRule, or with AddDropRule when the value is "drop"; rules are added in file order
 func (slog *Logger) LoadFacilityFilters(filename string) error {
 	fcontents, err := os.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	var lfilters map[string]string
-	err = json.Unmarshal(fcontents, &lfilters)
-	if err != nil {
-		return err
+	// the members are read in file order, because the first matching filter rule decides
+	decoder := json.NewDecoder(bytes.NewReader(fcontents))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return errors.New("filter file must contain a JSON object")
 	}
-	for fname, flevel := range lfilters {
-		lflevel := StringToLogLevel(flevel)
-		slog.AddFacilityFilter(fname, lflevel)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		fname := token.(string)
+		var flevel string
+		if err = decoder.Decode(&flevel); err != nil {
+			return err
+		}
+		switch {
+		case !isFilterExpression(fname):
+			slog.AddFacilityFilter(fname, StringToLogLevel(flevel))
+		case strings.EqualFold(flevel, "drop"):
+			err = slog.AddDropRule(fname)
+		default:
+			err = slog.AddFilterRule(fname, StringToLogLevel(flevel))
+		}
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (slog Logger) getFilteredLogLevel(facility string) LogLevel {
+func (slog *Logger) getFilteredLogLevel(facility string) LogLevel {
 	//fmt.Println(fmt.Sprintf("Determining filtered level for facility %s", facility))
 	var foundfilter int = -1
 	for n, filter := range slog.filters.filters {
-		if strings.HasPrefix(facility, filter.filter) {
+		if filter.matches(facility) {
 			//fmt.Println(fmt.Sprintf("Filter %s matches", filter.filter))
 			if foundfilter > -1 {
 				if len(filter.filter) >= len(slog.filters.filters[foundfilter].filter) {
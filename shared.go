@@ -0,0 +1,104 @@
+package servicelogger
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// lockSuffix is appended to the name of a shared log file for the lock file used to coordinate the processes sharing it
+const lockSuffix = ".lock"
+
+// sharedFile holds the lock that serializes the writes and rotations of all processes sharing a log file. The lock is
+// taken on a separate lock file, because the log file itself is renamed by rotation
+type sharedFile struct {
+	mu   sync.Mutex
+	lock *os.File
+}
+
+// EnableSharedFile coordinates several processes, such as the workers of one service, that write to the same log
+// file. Every write and every rotation takes an advisory lock on a lock file next to the log file, named after it with
+// a .lock suffix, so that exactly one process rotates when the log file is full and the rotated files are never renamed
+// by two processes at once. Before writing, a process checks whether the log file was rotated by another process and
+// reopens it if so. Every process sharing the log file must enable it with the same rotation settings. Shared log
+// files cannot be indexed, and locking is not supported on Windows. EnableSharedFile must be called before the Logger
+// is used from multiple goroutines
+func (l *Logger) EnableSharedFile() error {
+	if l.memory != nil || l.fifo != nil || l.filehandle == nil {
+		return errors.New("only a log file can be shared")
+	}
+	if l.indexed {
+		return errors.New("an indexed log file cannot be shared")
+	}
+	if l.buffer != nil {
+		return errors.New("a buffered log file cannot be shared")
+	}
+	if l.shared != nil {
+		return nil
+	}
+	lock, err := os.OpenFile(l.filename+lockSuffix, os.O_CREATE|os.O_RDWR, 0640)
+	if err != nil {
+		return err
+	}
+	if err = lockFile(lock); err != nil {
+		lock.Close()
+		return err
+	}
+	_ = unlockFile(lock)
+	l.shared = &sharedFile{lock: lock}
+	return nil
+}
+
+// replaceLock switches to the lock file of another log file and closes the old lock file
+func (s *sharedFile) replaceLock(lock *os.File) {
+	s.mu.Lock()
+	old := s.lock
+	s.lock = lock
+	s.mu.Unlock()
+	old.Close()
+}
+
+// acquire takes the lock of the shared log file, for the goroutines of this process and for the other processes
+func (s *sharedFile) acquire() error {
+	s.mu.Lock()
+	if err := lockFile(s.lock); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *sharedFile) release() {
+	_ = unlockFile(s.lock)
+	s.mu.Unlock()
+}
+
+func (s *sharedFile) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lock.Close()
+}
+
+// reopenRotated reopens the log file when another process has rotated it since this process opened it. It must be
+// called with the lock of the shared log file held
+func (l *Logger) reopenRotated() error {
+	current, err := l.filehandle.Stat()
+	if err != nil {
+		return err
+	}
+	named, err := os.Stat(l.filename)
+	if err == nil && os.SameFile(current, named) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	old := l.filehandle
+	if err = l.openLogFile(); err != nil {
+		l.filehandle = old
+		return err
+	}
+	old.Close()
+	l.base, _ = l.newBase()
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package servicelogger
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoLocking = errors.New("shared log files are not supported on this platform")
+
+func lockFile(f *os.File) error {
+	return errNoLocking
+}
+
+func unlockFile(f *os.File) error {
+	return errNoLocking
+}
@@ -0,0 +1,45 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errShutdown is counted for entries logged after Shutdown
+var errShutdown = errors.New("logger is shut down")
+
+// Drainer is implemented by destinations that hold entries in memory before delivering them, such as notifiers and
+// batching sinks. Drain delivers what is held and stops the destination, giving up when ctx is done, and returns the
+// number of entries or notifications it could not deliver
+type Drainer interface {
+	Drain(ctx context.Context) (int, error)
+}
+
+// Shutdown stops the Logger for a clean exit, e.g. when a Kubernetes pod is terminated. Entries logged after the call
+// are dropped. The queue of an asynchronous Logger is written and its goroutine stopped, notifiers and sinks that
+// implement Drainer deliver what they hold, sinks that implement io.Closer are closed, and the log file is closed, all
+// within the deadline of ctx. Shutdown returns nil when nothing was lost, and otherwise an error with the number of
+// queued entries and notifications that were dropped
+func (l *Logger) Shutdown(ctx context.Context) error {
+	if l.shutdown.Swap(true) {
+		return errors.New("logger is already shut down")
+	}
+	dropped := 0
+	if l.async != nil {
+		dropped += l.async.stop(ctx)
+	}
+	for _, d := range l.drainers {
+		n, _ := d.Drain(ctx)
+		dropped += n
+	}
+	l.closeSinks(true)
+	err := l.closeFiles()
+	if dropped == 0 {
+		return err
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%d entries dropped during shutdown: %w", dropped, ctx.Err())
+	}
+	return fmt.Errorf("%d entries dropped during shutdown", dropped)
+}
@@ -0,0 +1,194 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Sink is a destination that receives the entries of a Logger in addition to its log file. WriteEntry may be called
+// from multiple goroutines at once, and must not keep e or modify it
+type Sink interface {
+	WriteEntry(e *Entry) error
+}
+
+// FieldFilter selects the structured fields a destination receives
+type FieldFilter struct {
+	Allow []string // only these fields are passed on, all fields when empty
+	Deny  []string // these fields are never passed on, even when allowed
+}
+
+// SinkConfig configures a sink added with AddSink
+type SinkConfig struct {
+	Name     string        // name of the sink in diagnostics, defaults to its position such as "sink 2"
+	MinLevel LogLevel      // lowest level the sink receives, every level that passes the filters of the Logger when zero
+	Fields   FieldFilter   // fields the sink receives
+	Breaker  BreakerConfig // circuit breaker that pauses the sink while it keeps failing
+}
+
+// sink is a Sink with its configuration. The Sink is only used with mu held, so that ReplaceSink can switch it while
+// entries are being written
+type sink struct {
+	mu sync.RWMutex
+	Sink
+	config  SinkConfig
+	breaker *breaker
+}
+
+// AddSink adds a destination that receives every entry written by the Logger, after it has been written to the log
+// file. The minimum level of the sink is applied after the level and facility filters of the Logger, so a sink can only
+// receive fewer entries than the log file, e.g. only WARN and above for a remote collector. For an asynchronous Logger
+// sinks are called from the background goroutine. Sinks that buffer entries should implement Drainer, so that Shutdown
+// delivers what they hold, and sinks that hold connections should implement io.Closer, so that Shutdown and Close
+// release them. With a circuit breaker, a sink that failed Threshold times in a row is skipped for the cool-down, after
+// which a single trial entry decides whether it is resumed; the other sinks are not affected, and every change is
+// written to the log file. AddSink must be called before the Logger is used from multiple goroutines
+func (l *Logger) AddSink(s Sink, config SinkConfig) {
+	if config.Name == "" {
+		config.Name = fmt.Sprintf("sink %d", len(l.sinks)+1)
+	}
+	added := &sink{Sink: s, config: config, breaker: newBreaker(config.Breaker)}
+	l.sinks = append(l.sinks, added)
+	l.drainers = append(l.drainers, added)
+}
+
+// ReplaceSink switches the destination of the sink added as old to replacement, keeping its configuration, e.g. to
+// redirect a sink during live troubleshooting. Entries being written to old when ReplaceSink is called are written
+// before it returns, and every later entry is written to replacement, so no entries are lost. Entries that old buffers
+// itself stay there until it is drained or closed by the caller. ReplaceSink may be called while the Logger is in use
+func (l *Logger) ReplaceSink(old Sink, replacement Sink) error {
+	if replacement == nil {
+		return errors.New("replacement sink is required")
+	}
+	for _, s := range l.sinks {
+		s.mu.Lock()
+		if s.Sink == old {
+			s.Sink = replacement
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+	}
+	return errors.New("sink was not added to the logger")
+}
+
+// write hands e to the Sink
+func (s *sink) write(e *Entry) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Sink.WriteEntry(e)
+}
+
+// Drain drains the Sink when it implements Drainer
+func (s *sink) Drain(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if d, ok := s.Sink.(Drainer); ok {
+		return d.Drain(ctx)
+	}
+	return 0, nil
+}
+
+// close closes the Sink when it implements io.Closer. When drained is set, Sinks that implement Drainer are skipped,
+// since draining them already stopped them
+func (s *sink) close(drained bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.Sink.(Drainer); ok && drained {
+		return nil
+	}
+	if c, ok := s.Sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// closeSinks closes the sinks that implement io.Closer, e.g. to release their connections
+func (l *Logger) closeSinks(drained bool) {
+	for _, s := range l.sinks {
+		_ = s.close(drained)
+	}
+}
+
+// SetFieldFilter selects the structured fields that are written to the log file. Sinks, hooks and the recorder still
+// receive every field. SetFieldFilter must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetFieldFilter(filter FieldFilter) {
+	l.fileFields = filter
+}
+
+// writeSinks hands e to every sink that accepts its level and whose breaker is not open, each with the fields it is
+// configured to receive
+func (l *Logger) writeSinks(e *Entry) {
+	for _, s := range l.sinks {
+		if !e.Level.atLeast(s.config.MinLevel) {
+			continue
+		}
+		if s.breaker != nil && !s.breaker.allow(l.now()) {
+			if l.stats != nil {
+				l.stats.sinkSkipped.Add(1)
+			}
+			continue
+		}
+		err := s.write(s.config.Fields.apply(e))
+		if err != nil && l.stats != nil {
+			l.stats.sinkFailed.Add(1)
+		}
+		if s.breaker == nil {
+			continue
+		}
+		if level, text := s.breaker.result(err, l.now()); text != "" {
+			if l.stats != nil && level == LL_WARN {
+				l.stats.breakerOpened.Add(1)
+			}
+			l.writeInternal(level, "writeSinks", fmt.Sprintf("Circuit breaker of %s: %s", s.config.Name, text))
+		}
+	}
+}
+
+// apply returns e when the filter passes all of its fields, and otherwise a copy of e with only the selected fields
+func (f FieldFilter) apply(e *Entry) *Entry {
+	if len(e.Fields) == 0 || (len(f.Allow) == 0 && len(f.Deny) == 0) {
+		return e
+	}
+	filtered := *e
+	filtered.Fields = make(map[string]interface{}, len(e.Fields))
+	if len(f.Allow) == 0 {
+		for key, value := range e.Fields {
+			filtered.Fields[key] = value
+		}
+	} else {
+		for _, key := range f.Allow {
+			if value, ok := e.Fields[key]; ok {
+				filtered.Fields[key] = value
+			}
+		}
+	}
+	for _, key := range f.Deny {
+		delete(filtered.Fields, key)
+	}
+	return &filtered
+}
+
+// writerSink writes entries as lines to an io.Writer
+type writerSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	format   LogFormat
+	sanitize SanitizeMode
+}
+
+// NewWriterSink returns a Sink that writes every entry as a line in format to w, e.g. to mirror the log to a second
+// file or to a network connection. Writes are serialized, so w does not need to be safe for concurrent use
+func NewWriterSink(w io.Writer, format LogFormat) Sink {
+	return &writerSink{w: w, format: format, sanitize: SM_ESCAPE}
+}
+
+func (s *writerSink) WriteEntry(e *Entry) error {
+	line := formatEntry(e, s.format, s.sanitize)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, line)
+	return err
+}
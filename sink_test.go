@@ -0,0 +1,42 @@
+package servicelogger
+
+import (
+	"context"
+	"testing"
+)
+
+// closingSink records whether it was closed
+type closingSink struct {
+	closed bool
+}
+
+func (s *closingSink) WriteEntry(e *Entry) error { return nil }
+
+func (s *closingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestShutdownClosesSinks(t *testing.T) {
+	l, _ := newTestLogger(t, false, "10M", 1)
+	sink := &closingSink{}
+	l.AddSink(sink, SinkConfig{})
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !sink.closed {
+		t.Fatal("sink not closed by Shutdown")
+	}
+}
+
+func TestCloseClosesSinks(t *testing.T) {
+	l, _ := newTestLogger(t, false, "10M", 1)
+	sink := &closingSink{}
+	l.AddSink(sink, SinkConfig{})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !sink.closed {
+		t.Fatal("sink not closed by Close")
+	}
+}
@@ -0,0 +1,207 @@
+package servicelogger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// spoolSuffix is the suffix of the segment files of a spool
+const spoolSuffix = ".spool"
+
+// SpoolConfig configures the disk spool of a RetrySink. Zero members get their default, an empty Dir disables the spool
+type SpoolConfig struct {
+	Dir     string // directory of the spool, created when missing. Use a separate directory for every sink
+	MaxSize int64  // bytes the spool may hold before its oldest entries are dropped, defaults to 100 MiB
+}
+
+// spool holds entries on disk, in segment files of LF_BINARY frames named after their sequence number, so that they
+// are replayed in order and survive a restart. A spool is used with the mutex of its RetrySink held
+type spool struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+	segments    []*spoolSegment
+	active      *os.File
+	size        int64
+	next        uint64
+}
+
+// spoolSegment is a segment file of a spool. The entries before delivered have been replayed already
+type spoolSegment struct {
+	seq       uint64
+	name      string
+	size      int64
+	entries   int
+	delivered int
+	replaying bool
+}
+
+// openSpool opens the spool in config.Dir, picking up the segments left by a previous process
+func openSpool(config SpoolConfig) (*spool, error) {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 100 * 1024 * 1024
+	}
+	if err := os.MkdirAll(config.Dir, 0750); err != nil {
+		return nil, err
+	}
+	// sequence numbers start in the middle of their range, so that prepend has room below the first segment
+	s := &spool{dir: config.Dir, maxSize: config.MaxSize, segmentSize: 1024 * 1024, next: 1 << 62}
+	if s.segmentSize > s.maxSize/8 {
+		s.segmentSize = s.maxSize / 8
+	}
+	names, err := filepath.Glob(filepath.Join(config.Dir, "*"+spoolSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), spoolSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		seg, err := scanSpoolSegment(name)
+		if err != nil {
+			return nil, err
+		}
+		seg.seq = seq
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.next = seq + 1
+	}
+	return s, nil
+}
+
+func scanSpoolSegment(name string) (*spoolSegment, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	seg := &spoolSegment{name: name}
+	r := NewEntryReader(fh, "")
+	for {
+		if _, err = r.Next(); err != nil {
+			// a frame cut short by a crash ends the segment
+			break
+		}
+		seg.entries++
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	seg.size = info.Size()
+	return seg, nil
+}
+
+func (s *spool) empty() bool {
+	return len(s.segments) == 0
+}
+
+// append adds e to the newest segment, starting a new one when it is full, and evicts the oldest segments that are not
+// being replayed while the spool is too large. Evicted segments are returned for the caller to dispose of
+func (s *spool) append(e *Entry) ([]*spoolSegment, error) {
+	if s.active == nil || s.segments[len(s.segments)-1].size >= s.segmentSize {
+		if err := s.roll(); err != nil {
+			return nil, err
+		}
+	}
+	frame := formatBinary(e)
+	if _, err := io.WriteString(s.active, frame); err != nil {
+		return nil, err
+	}
+	seg := s.segments[len(s.segments)-1]
+	seg.size += int64(len(frame))
+	seg.entries++
+	s.size += int64(len(frame))
+	var evicted []*spoolSegment
+	for n := 0; s.size > s.maxSize && n < len(s.segments)-1; {
+		if s.segments[n].replaying {
+			n++
+			continue
+		}
+		evicted = append(evicted, s.segments[n])
+		s.detach(s.segments[n])
+	}
+	return evicted, nil
+}
+
+// roll closes the active segment and starts a new one
+func (s *spool) roll() error {
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+	seg := &spoolSegment{seq: s.next, name: filepath.Join(s.dir, fmt.Sprintf("%016x%s", s.next, spoolSuffix))}
+	fh, err := os.OpenFile(seg.name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	s.next++
+	s.active = fh
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+// prepend adds e in a segment of its own before all other segments, for an entry that is older than everything in the
+// spool
+func (s *spool) prepend(e *Entry) error {
+	if s.empty() {
+		// an empty spool is never over its size
+		_, err := s.append(e)
+		return err
+	}
+	seq := s.segments[0].seq - 1
+	seg := &spoolSegment{seq: seq, name: filepath.Join(s.dir, fmt.Sprintf("%016x%s", seq, spoolSuffix)), entries: 1}
+	frame := formatBinary(e)
+	if err := os.WriteFile(seg.name, []byte(frame), 0640); err != nil {
+		return err
+	}
+	seg.size = int64(len(frame))
+	s.size += seg.size
+	s.segments = append([]*spoolSegment{seg}, s.segments...)
+	return nil
+}
+
+// oldest returns the oldest segment for replay, closing it first when it is the active segment
+func (s *spool) oldest() *spoolSegment {
+	seg := s.segments[0]
+	if len(s.segments) == 1 && s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+	seg.replaying = true
+	return seg
+}
+
+// remove deletes a segment from the spool and the disk
+func (s *spool) remove(seg *spoolSegment) {
+	if s.detach(seg) {
+		_ = os.Remove(seg.name)
+	}
+}
+
+// detach removes a segment from the spool, leaving its file, and reports whether it was part of the spool
+func (s *spool) detach(seg *spoolSegment) bool {
+	for n, candidate := range s.segments {
+		if candidate == seg {
+			s.segments = append(s.segments[:n], s.segments[n+1:]...)
+			s.size -= seg.size
+			return true
+		}
+	}
+	return false
+}
+
+// close closes the active segment, leaving the spool on disk for the next process
+func (s *spool) close() {
+	if s.active != nil {
+		s.active.Close()
+		s.active = nil
+	}
+}
@@ -0,0 +1,352 @@
+package servicelogger
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLLogConfig configures the entries written by a driver wrapped with WrapSQLDriver. Zero members get their default
+type SQLLogConfig struct {
+	Source        string                                  // source of the entries, defaults to "sql"
+	SlowThreshold time.Duration                           // statements taking at least this long are logged at WARN, 0 disables
+	LogArguments  bool                                    // include the statement arguments in the entries
+	Redact        func(arg driver.NamedValue) interface{} // returns the logged form of an argument, defaults to RedactSQLArgument
+}
+
+// RedactSQLArgument is the default argument redactor of SQLLogConfig. Only nil, numeric, boolean and time arguments are
+// logged as they are; strings and byte slices, which may hold credentials or personal data, are masked
+func RedactSQLArgument(arg driver.NamedValue) interface{} {
+	switch arg.Value.(type) {
+	case nil, int64, float64, bool, time.Time:
+		return arg.Value
+	default:
+		return "***"
+	}
+}
+
+// WrapSQLDriver returns a driver.Driver that logs all statements executed through d. Statements are logged at DEBUG
+// level with their duration and error, statements slower than the threshold at WARN level, and connection and
+// transaction events at TRACE level. Register the result with sql.Register under a new name to use it
+func (l *Logger) WrapSQLDriver(d driver.Driver, config SQLLogConfig) driver.Driver {
+	return &sqlDriver{Driver: d, sl: newSQLLogger(l, config)}
+}
+
+// WrapSQLConnector returns a driver.Connector that logs all statements executed through connections of c, for use with
+// sql.OpenDB
+func (l *Logger) WrapSQLConnector(c driver.Connector, config SQLLogConfig) driver.Connector {
+	return &sqlConnector{connector: c, sl: newSQLLogger(l, config)}
+}
+
+type sqlLogger struct {
+	l      *Logger
+	config SQLLogConfig
+}
+
+func newSQLLogger(l *Logger, config SQLLogConfig) *sqlLogger {
+	if config.Source == "" {
+		config.Source = "sql"
+	}
+	if config.Redact == nil {
+		config.Redact = RedactSQLArgument
+	}
+	return &sqlLogger{l: l, config: config}
+}
+
+func (s *sqlLogger) statement(ctx context.Context, function string, query string, args []driver.NamedValue, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	duration := time.Since(start)
+	level := LL_DEBUG
+	if s.config.SlowThreshold > 0 && duration >= s.config.SlowThreshold {
+		level = LL_WARN
+	}
+	fields := map[string]interface{}{"duration": duration}
+	if s.config.LogArguments && len(args) > 0 {
+		logged := make([]string, len(args))
+		for n, arg := range args {
+			logged[n] = fmt.Sprint(s.config.Redact(arg))
+		}
+		fields["args"] = "[" + strings.Join(logged, ", ") + "]"
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	s.l.WithContext(ctx).log(level, function, s.config.Source, query, fields)
+}
+
+func (s *sqlLogger) event(function string, text string, err error) {
+	var fields map[string]interface{}
+	if err != nil {
+		fields = map[string]interface{}{"error": err.Error()}
+	}
+	s.l.log(LL_TRACE, function, s.config.Source, text, fields)
+}
+
+type sqlDriver struct {
+	driver.Driver
+	sl *sqlLogger
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	d.sl.event("Open", "Opened connection", err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: c, sl: d.sl}, nil
+}
+
+func (d *sqlDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlConnector{connector: c, driver: d, sl: d.sl}, nil
+	}
+	return &sqlConnector{name: name, driver: d, sl: d.sl}, nil
+}
+
+type sqlConnector struct {
+	connector driver.Connector
+	name      string
+	driver    *sqlDriver
+	sl        *sqlLogger
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.connector == nil {
+		return c.driver.Open(c.name)
+	}
+	conn, err := c.connector.Connect(ctx)
+	c.sl.event("Connect", "Opened connection", err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn, sl: c.sl}, nil
+}
+
+func (c *sqlConnector) Driver() driver.Driver {
+	if c.driver != nil {
+		return c.driver
+	}
+	return &sqlDriver{Driver: c.connector.Driver(), sl: c.sl}
+}
+
+type sqlConn struct {
+	driver.Conn
+	sl *sqlLogger
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if cpc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = cpc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	c.sl.event("Prepare", query, err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{Stmt: stmt, query: query, sl: c.sl}, nil
+}
+
+func (c *sqlConn) Close() error {
+	err := c.Conn.Close()
+	c.sl.event("Close", "Closed connection", err)
+	return err
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if cbt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = cbt.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.sl.event("Begin", "Started transaction", err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, sl: c.sl}, nil
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		res, err = ec.ExecContext(ctx, query, args)
+	} else if e, ok := c.Conn.(driver.Execer); ok {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			res, err = e.Exec(query, values)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	c.sl.statement(ctx, "Exec", query, args, start, err)
+	return res, err
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if qc, ok := c.Conn.(driver.QueryerContext); ok {
+		rows, err = qc.QueryContext(ctx, query, args)
+	} else if q, ok := c.Conn.(driver.Queryer); ok {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			rows, err = q.Query(query, values)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	c.sl.statement(ctx, "Query", query, args, start, err)
+	return rows, err
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type sqlStmt struct {
+	driver.Stmt
+	query string
+	sl    *sqlLogger
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if sec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = sec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+	s.sl.statement(ctx, "Exec", s.query, args, start, err)
+	return res, err
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if sqc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = sqc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	s.sl.statement(ctx, "Query", s.query, args, start, err)
+	return rows, err
+}
+
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (s *sqlStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+type sqlTx struct {
+	driver.Tx
+	sl *sqlLogger
+}
+
+func (t *sqlTx) Commit() error {
+	err := t.Tx.Commit()
+	t.sl.event("Commit", "Committed transaction", err)
+	return err
+}
+
+func (t *sqlTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.sl.event("Rollback", "Rolled back transaction", err)
+	return err
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for n, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("driver does not support named arguments")
+		}
+		values[n] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for n, arg := range args {
+		named[n] = driver.NamedValue{Ordinal: n + 1, Value: arg}
+	}
+	return named
+}
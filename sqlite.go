@@ -0,0 +1,137 @@
+package servicelogger
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// SQLiteSinkConfig configures the sink returned by NewSQLiteSink. Zero members get their default
+type SQLiteSinkConfig struct {
+	Table      string // table the entries are written to, created when missing. Defaults to "log_entries"
+	MaxSize    int64  // bytes the entries may occupy before the oldest are pruned, 0 for no limit
+	PruneEvery int    // number of entries written between checks of the size, defaults to 1000
+}
+
+// sqliteTimeLayout is the layout of the time column. It is UTC with a fixed width, so that times sort as text and work
+// with the SQLite date and time functions
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqliteSink writes entries to a table of a SQLite database
+type sqliteSink struct {
+	mu      sync.Mutex
+	db      *sql.DB
+	config  SQLiteSinkConfig
+	insert  string
+	written int
+}
+
+// NewSQLiteSink returns a Sink that writes entries to a SQLite database, so that a service can run ad-hoc SQL over its
+// own log without any external stack. db must be opened with a SQLite driver, such as modernc.org/sqlite or
+// github.com/mattn/go-sqlite3; the core package does not depend on one. The database is switched to WAL mode, so that
+// queries do not block the sink. Every entry is a row with the columns id, time (UTC text that sorts in time order),
+// level, facility, prefix, source, function, message and fields (a JSON object, for use with json_extract). When
+// MaxSize is set, the oldest tenth of the entries is deleted whenever the entries occupy more; SQLite reuses the freed
+// pages, so the file stops growing once it reaches the limit
+func NewSQLiteSink(db *sql.DB, config SQLiteSinkConfig) (Sink, error) {
+	if config.Table == "" {
+		config.Table = "log_entries"
+	}
+	if !sqlIdentifier.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table name %q", config.Table)
+	}
+	if config.PruneEvery <= 0 {
+		config.PruneEvery = 1000
+	}
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode=WAL").Scan(&mode); err != nil {
+		return nil, err
+	}
+	if mode != "wal" {
+		return nil, fmt.Errorf("unable to enable WAL mode, journal mode is %s", mode)
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TEXT NOT NULL,
+			level TEXT NOT NULL,
+			facility TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			source TEXT NOT NULL,
+			function TEXT NOT NULL,
+			message TEXT NOT NULL,
+			fields TEXT
+		)`, config.Table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_time ON %s (time)", config.Table, config.Table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_level ON %s (level, time)", config.Table, config.Table),
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, err
+		}
+	}
+	s := &sqliteSink{
+		db:     db,
+		config: config,
+		insert: fmt.Sprintf("INSERT INTO %s (time, level, facility, prefix, source, function, message, fields) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", config.Table),
+	}
+	if err := s.prune(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteSink) WriteEntry(e *Entry) error {
+	var fields interface{}
+	if len(e.Fields) > 0 {
+		var b bytes.Buffer
+		writeJSONFields(&b, e.Fields)
+		fields = b.String()
+	}
+	// SQLite allows a single writer; serializing here avoids busy errors between the sink's own connections
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(s.insert, e.Time.UTC().Format(sqliteTimeLayout), LogLevelToString(e.Level), e.Facility(), e.Prefix, e.Source, e.Function, e.Message, fields)
+	if err != nil {
+		return err
+	}
+	s.written++
+	if s.written%s.config.PruneEvery == 0 {
+		return s.prune()
+	}
+	return nil
+}
+
+// prune deletes the oldest tenth of the entries while they occupy more than MaxSize
+func (s *sqliteSink) prune() error {
+	if s.config.MaxSize <= 0 {
+		return nil
+	}
+	for {
+		var pages, free, pageSize int64
+		if err := s.db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+			return err
+		}
+		if err := s.db.QueryRow("PRAGMA freelist_count").Scan(&free); err != nil {
+			return err
+		}
+		if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+			return err
+		}
+		if (pages-free)*pageSize <= s.config.MaxSize {
+			return nil
+		}
+		result, err := s.db.Exec(fmt.Sprintf("DELETE FROM %[1]s WHERE id <= (SELECT MIN(id) + (MAX(id) - MIN(id)) / 10 FROM %[1]s)", s.config.Table))
+		if err != nil {
+			return err
+		}
+		if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+			return errors.New("unable to prune entries below the size limit")
+		}
+	}
+}
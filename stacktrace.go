@@ -0,0 +1,154 @@
+package servicelogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackTraceConfig configures the stack traces of EnableStackTraces. Zero members get their default
+type StackTraceConfig struct {
+	MinLevel  LogLevel // level from which entries get a stack trace, defaults to LL_ERROR
+	All       bool     // the stacks of all goroutines instead of only the logging goroutine
+	MaxFrames int      // frames per goroutine, 0 for all of them
+	Hide      []string // packages whose frames are left out, nil for runtime and this package, empty for none
+}
+
+// StackFrame is a frame of a stack trace
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// GoroutineStack is the stack trace of a goroutine, innermost frame first
+type GoroutineStack struct {
+	Goroutine uint64       `json:"goroutine"`
+	State     string       `json:"state"`
+	Frames    []StackFrame `json:"frames"`
+	Omitted   int          `json:"omitted,omitempty"` // frames beyond the frame limit
+}
+
+// StackTrace is the value of the field stack added by EnableStackTraces. It is written as a JSON array of goroutines
+// with their frames in LF_JSON format, and as text in the style of a Go goroutine dump in the other formats
+type StackTrace []GoroutineStack
+
+// String returns the stack trace in the style of a Go goroutine dump
+func (t StackTrace) String() string {
+	var b strings.Builder
+	for n, g := range t {
+		if n > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "goroutine %d [%s]:\n", g.Goroutine, g.State)
+		for _, frame := range g.Frames {
+			b.WriteString(frame.Function + "\n")
+			if frame.File != "" {
+				fmt.Fprintf(&b, "\t%s:%d\n", frame.File, frame.Line)
+			}
+		}
+		if g.Omitted > 0 {
+			fmt.Fprintf(&b, "...%d frames omitted\n", g.Omitted)
+		}
+	}
+	return b.String()
+}
+
+// MarshalJSON encodes the stack trace as an array of goroutines
+func (t StackTrace) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]GoroutineStack(t))
+}
+
+// stackTraces attaches stack traces to entries
+type stackTraces struct {
+	config StackTraceConfig
+}
+
+// EnableStackTraces adds the stack of the logging goroutine, or of all goroutines, as the field stack to the entries at
+// or above MinLevel, e.g. to tell where an error was logged. Frames of the runtime and of this package are hidden unless
+// Hide says otherwise; a package hides its subpackages as well. A stack field passed by the caller takes precedence.
+// Capturing the stacks of all goroutines stops the world briefly, so use it with a high MinLevel only.
+// EnableStackTraces must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableStackTraces(config StackTraceConfig) {
+	if config.MinLevel == 0 {
+		config.MinLevel = LL_ERROR
+	}
+	if config.Hide == nil {
+		config.Hide = []string{"runtime", packagePath}
+	}
+	l.stackTraces = &stackTraces{config: config}
+}
+
+// attach adds the stack trace to e when its level calls for one
+func (s *stackTraces) attach(e *Entry) {
+	if !e.Level.atLeast(s.config.MinLevel) {
+		return
+	}
+	if _, ok := e.Fields["stack"]; ok {
+		return
+	}
+	var dump []byte
+	if s.config.All {
+		dump = goroutineStacks()
+	} else {
+		buf := make([]byte, 16*1024)
+		for {
+			n := runtime.Stack(buf, false)
+			if n < len(buf) {
+				dump = buf[:n]
+				break
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+	}
+	e.SetField("stack", s.parse(dump))
+}
+
+// parse parses a dump written by runtime.Stack, leaving out hidden frames and frames beyond the limit
+func (s *stackTraces) parse(dump []byte) StackTrace {
+	var trace StackTrace
+	for _, block := range bytes.Split(bytes.TrimSpace(dump), []byte("\n\n")) {
+		lines := strings.Split(string(block), "\n")
+		header := strings.TrimSuffix(strings.TrimPrefix(lines[0], "goroutine "), ":")
+		id, state, _ := strings.Cut(header, " ")
+		g := GoroutineStack{State: strings.Trim(state, "[]")}
+		g.Goroutine, _ = strconv.ParseUint(id, 10, 64)
+		for n := 1; n < len(lines); n++ {
+			frame := StackFrame{Function: lines[n]}
+			if paren := strings.LastIndex(frame.Function, "("); paren > 0 && strings.HasSuffix(frame.Function, ")") {
+				frame.Function = frame.Function[:paren]
+			}
+			if n+1 < len(lines) && strings.HasPrefix(lines[n+1], "\t") {
+				n++
+				location, _, _ := strings.Cut(strings.TrimPrefix(lines[n], "\t"), " +0x")
+				if colon := strings.LastIndex(location, ":"); colon > 0 {
+					frame.File = location[:colon]
+					frame.Line, _ = strconv.Atoi(location[colon+1:])
+				}
+			}
+			switch {
+			case s.hides(frame.Function):
+			case s.config.MaxFrames > 0 && len(g.Frames) >= s.config.MaxFrames:
+				g.Omitted++
+			default:
+				g.Frames = append(g.Frames, frame)
+			}
+		}
+		trace = append(trace, g)
+	}
+	return trace
+}
+
+// hides reports whether the frame of function is left out
+func (s *stackTraces) hides(function string) bool {
+	module, _ := splitFunctionName(strings.TrimPrefix(function, "created by "))
+	for _, hidden := range s.config.Hide {
+		if module == hidden || strings.HasPrefix(module, hidden+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package servicelogger
+
+import "sync/atomic"
+
+// Stats holds counters describing the activity of a Logger
+type Stats struct {
+	Written       uint64 // entries written to the log
+	Dropped       uint64 // entries that could not be written, including QueueDropped
+	QueueDropped  uint64 // entries dropped by the backpressure policy of an asynchronous Logger
+	SinkFailed    uint64 // entries a sink failed to write
+	SinkSkipped   uint64 // entries not handed to a sink because its circuit breaker was open
+	BreakerOpened uint64 // times the circuit breaker of a sink opened
+	WALFailed     uint64 // entries that could not be appended to the write-ahead log
+	AuditFailed   uint64 // entries of audit facilities that could not be written to the audit log
+	OutputFailed  uint64 // lines that could not be written to an output added with AddOutput
+	PoolAllocated uint64 // entries and builders allocated with EnablePooling because none could be reused
+	PoolReused    uint64 // entries and builders reused with EnablePooling
+	Nested        uint64 // messages logged by hooks, interceptors or sinks while logging, written by the Logger itself
+	Deduplicated  uint64 // entries suppressed by EnableDedup as repeats
+}
+
+type loggerStats struct {
+	written       atomic.Uint64
+	dropped       atomic.Uint64
+	queueDropped  atomic.Uint64
+	sinkFailed    atomic.Uint64
+	sinkSkipped   atomic.Uint64
+	breakerOpened atomic.Uint64
+	walFailed     atomic.Uint64
+	auditFailed   atomic.Uint64
+	outputFailed  atomic.Uint64
+	nested        atomic.Uint64
+	deduplicated  atomic.Uint64
+}
+
+// Stats returns the counters of the Logger since it was created
+func (l *Logger) Stats() Stats {
+	if l.stats == nil {
+		return Stats{}
+	}
+	s := Stats{
+		Written:       l.stats.written.Load(),
+		Dropped:       l.stats.dropped.Load(),
+		QueueDropped:  l.stats.queueDropped.Load(),
+		SinkFailed:    l.stats.sinkFailed.Load(),
+		SinkSkipped:   l.stats.sinkSkipped.Load(),
+		BreakerOpened: l.stats.breakerOpened.Load(),
+		WALFailed:     l.stats.walFailed.Load(),
+		AuditFailed:   l.stats.auditFailed.Load(),
+		OutputFailed:  l.stats.outputFailed.Load(),
+		Nested:        l.stats.nested.Load(),
+		Deduplicated:  l.stats.deduplicated.Load(),
+	}
+	if l.pools != nil {
+		s.PoolAllocated = l.pools.allocated.Load()
+		s.PoolReused = l.pools.reused.Load()
+	}
+	return s
+}
+
+func (l *Logger) countWrite(err error) {
+	if l.stats == nil {
+		return
+	}
+	if err != nil {
+		l.stats.dropped.Add(1)
+	} else {
+		l.stats.written.Add(1)
+	}
+}
+
+func (l *Logger) countQueueDrop(count int) {
+	if l.stats == nil {
+		return
+	}
+	l.stats.dropped.Add(uint64(count))
+	l.stats.queueDropped.Add(uint64(count))
+}
@@ -0,0 +1,228 @@
+package servicelogger
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyslogFacility is a syslog facility code. The kernel facility, code 0, cannot be used by applications
+type SyslogFacility int
+
+const (
+	SF_USER     SyslogFacility = 1
+	SF_MAIL     SyslogFacility = 2
+	SF_DAEMON   SyslogFacility = 3
+	SF_AUTH     SyslogFacility = 4
+	SF_SYSLOG   SyslogFacility = 5
+	SF_LPR      SyslogFacility = 6
+	SF_NEWS     SyslogFacility = 7
+	SF_UUCP     SyslogFacility = 8
+	SF_CRON     SyslogFacility = 9
+	SF_AUTHPRIV SyslogFacility = 10
+	SF_FTP      SyslogFacility = 11
+	SF_LOCAL0   SyslogFacility = 16
+	SF_LOCAL1   SyslogFacility = 17
+	SF_LOCAL2   SyslogFacility = 18
+	SF_LOCAL3   SyslogFacility = 19
+	SF_LOCAL4   SyslogFacility = 20
+	SF_LOCAL5   SyslogFacility = 21
+	SF_LOCAL6   SyslogFacility = 22
+	SF_LOCAL7   SyslogFacility = 23
+)
+
+// SyslogSeverity is a syslog severity code, from 0 for emergencies to 7 for debug messages
+type SyslogSeverity int
+
+const (
+	SS_EMERG   SyslogSeverity = 0
+	SS_ALERT   SyslogSeverity = 1
+	SS_CRIT    SyslogSeverity = 2
+	SS_ERR     SyslogSeverity = 3
+	SS_WARNING SyslogSeverity = 4
+	SS_NOTICE  SyslogSeverity = 5
+	SS_INFO    SyslogSeverity = 6
+	SS_DEBUG   SyslogSeverity = 7
+)
+
+// defaultSyslogSeverities maps the levels to the syslog severities unless SyslogConfig.Severities says otherwise
+var defaultSyslogSeverities = map[LogLevel]SyslogSeverity{
+	LL_TRACE: SS_DEBUG,
+	LL_DEBUG: SS_DEBUG,
+	LL_INFO:  SS_INFO,
+	LL_WARN:  SS_WARNING,
+	LL_ERROR: SS_ERR,
+	LL_PANIC: SS_CRIT,
+	LL_FATAL: SS_ALERT,
+}
+
+// syslogSockets are the local syslog sockets tried in order when SyslogConfig.Network is empty. On systemd hosts
+// /dev/log is served by journald
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogConfig configures a sink returned by NewSyslogSink. Zero members get their default
+type SyslogConfig struct {
+	Network    string                      // "udp", "tcp", "unix" or "unixgram", the local syslog socket when empty
+	Address    string                      // address of the syslog server, e.g. "logs.example.com:514"
+	Facility   SyslogFacility              // facility of every message, defaults to SF_USER
+	Severities map[LogLevel]SyslogSeverity // severities of levels that differ from the default mapping
+	Tag        string                      // APP-NAME of the messages, defaults to the name of the program
+	RFC3164    bool                        // use the BSD format instead of RFC 5424, for old syslog daemons
+	Timeout    time.Duration               // timeout of connecting and writing, defaults to 5 seconds
+	TLS        *TLSConfig                  // encrypt the "tcp" connection as in RFC 5425, plaintext when nil
+}
+
+// syslogSink writes entries to a syslog daemon
+type syslogSink struct {
+	mu         sync.Mutex
+	config     SyslogConfig
+	severities map[LogLevel]SyslogSeverity
+	hostname   string
+	tls        *tls.Config
+	conn       net.Conn
+	stream     bool
+}
+
+// NewSyslogSink returns a Sink that sends every entry to a syslog daemon, as a message with the configured facility and
+// the severity the level of the entry maps to. The default mapping is TRACE and DEBUG to debug, INFO to info, WARN to
+// warning, ERROR to err, PANIC to crit and FATAL to alert; config.Severities overrides it per level, e.g. to send INFO
+// as notice so that existing rsyslog rules match. The message text is the line of the entry in LF_TEXT format without
+// the timestamp. Over TCP messages are framed by octet counting (RFC 6587), and with config.TLS the connection is
+// encrypted and the server verified, optionally with a client certificate. A lost connection is reestablished on the
+// next entry
+func NewSyslogSink(config SyslogConfig) (Sink, error) {
+	if config.Facility == 0 {
+		config.Facility = SF_USER
+	}
+	if config.Facility < SF_USER || config.Facility > SF_LOCAL7 {
+		return nil, fmt.Errorf("invalid syslog facility %d", config.Facility)
+	}
+	if config.Tag == "" {
+		config.Tag = filepath.Base(os.Args[0])
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	s := &syslogSink{config: config, severities: make(map[LogLevel]SyslogSeverity, len(defaultSyslogSeverities))}
+	for level, severity := range defaultSyslogSeverities {
+		s.severities[level] = severity
+	}
+	for level, severity := range config.Severities {
+		if severity < SS_EMERG || severity > SS_DEBUG {
+			return nil, fmt.Errorf("invalid syslog severity %d for %s", severity, LogLevelToString(level))
+		}
+		s.severities[level] = severity
+	}
+	if config.TLS != nil {
+		if config.Network != "tcp" {
+			return nil, errors.New("syslog over TLS requires the tcp network")
+		}
+		var err error
+		if s.tls, err = config.TLS.config(); err != nil {
+			return nil, err
+		}
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect opens the connection to the syslog daemon. It must be called with s.mu held or before s is shared
+func (s *syslogSink) connect() error {
+	if s.tls != nil {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: s.config.Timeout}, "tcp", s.config.Address, s.tls)
+		if err != nil {
+			return err
+		}
+		s.conn, s.stream = conn, true
+		return nil
+	}
+	if s.config.Network != "" {
+		conn, err := net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout)
+		if err != nil {
+			return err
+		}
+		s.conn, s.stream = conn, s.config.Network == "tcp" || s.config.Network == "unix"
+		return nil
+	}
+	for _, socket := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.DialTimeout(network, socket, s.config.Timeout); err == nil {
+				s.conn, s.stream = conn, network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("no local syslog socket found")
+}
+
+// format returns the syslog message for e
+func (s *syslogSink) format(e *Entry) string {
+	severity, ok := s.severities[e.Level]
+	if !ok {
+		severity = SS_INFO
+	}
+	priority := int(s.config.Facility)*8 + int(severity)
+	text := fmt.Sprintf("[%s] %s.%s %s", e.Function, e.Prefix, e.Source, sanitizeString(SM_ESCAPE, e.Message))
+	if len(e.Fields) > 0 {
+		text += " " + formatFields(e.Fields)
+	}
+	if s.config.RFC3164 {
+		return fmt.Sprintf("<%d>%s %s[%d]: %s", priority, e.Time.Format(time.Stamp), s.config.Tag, os.Getpid(), text)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s", priority, e.Time.Format(time.RFC3339Nano), s.hostname, s.config.Tag, os.Getpid(), text)
+}
+
+func (s *syslogSink) WriteEntry(e *Entry) error {
+	message := s.format(e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.write(message)
+	if err == nil {
+		return nil
+	}
+	// the daemon may have been restarted: reconnect and try once more
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err = s.connect(); err != nil {
+		return err
+	}
+	return s.write(message)
+}
+
+// write sends a message over the connection. It must be called with s.mu held
+func (s *syslogSink) write(message string) error {
+	if s.conn == nil {
+		return errors.New("not connected to syslog")
+	}
+	if s.stream {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+// Close closes the connection to the syslog daemon
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
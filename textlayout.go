@@ -0,0 +1,202 @@
+package servicelogger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type TextColumns int
+
+const (
+	TC_FUNCTION_FACILITY TextColumns = 1 // [function] prefix.source message
+	TC_FACILITY_FUNCTION TextColumns = 2 // prefix.source [function] message
+	TC_FACILITY          TextColumns = 3 // prefix.source message, without the function
+)
+
+// truncationMark starts a facility shortened to the width of its column
+const truncationMark = "…"
+
+// TextLayout configures the columns of the lines in LF_TEXT format. Zero members get their default
+type TextLayout struct {
+	LevelWidth    int         // width the level is padded to, defaults to 7, negative for no padding
+	FacilityWidth int         // width prefix.source is padded to, 0 for no padding
+	Truncate      bool        // shorten a longer prefix.source to FacilityWidth, keeping its end
+	Brackets      string      // opening and closing character around the function, defaults to "[]"
+	Columns       TextColumns // order of the function and facility columns, defaults to TC_FUNCTION_FACILITY
+}
+
+// defaultTextLayout is the layout of LF_TEXT lines without SetTextLayout
+var defaultTextLayout = TextLayout{LevelWidth: 7, Brackets: "[]", Columns: TC_FUNCTION_FACILITY}
+
+// SetTextLayout sets the layout of the lines in LF_TEXT format, for the log file and the outputs of AddOutput, so that
+// the columns line up even with long facility names, e.g. TextLayout{FacilityWidth: 30, Truncate: true}. A truncated
+// facility starts with "…" and cannot be split into prefix and source when the line is read back. LogReader of the
+// Logger reads lines in the layout; other readers, such as NewLogReader, expect the default layout. SetTextLayout must
+// be called before the Logger is used from multiple goroutines
+func (l *Logger) SetTextLayout(layout TextLayout) error {
+	if layout.LevelWidth == 0 {
+		layout.LevelWidth = defaultTextLayout.LevelWidth
+	}
+	if layout.FacilityWidth < 0 {
+		return errors.New("facility width too low (>=0)")
+	}
+	if layout.Truncate && layout.FacilityWidth < 2 {
+		return errors.New("truncating the facility requires a facility width of at least 2")
+	}
+	if layout.Brackets == "" {
+		layout.Brackets = defaultTextLayout.Brackets
+	}
+	if len(layout.Brackets) != 2 || strings.ContainsAny(layout.Brackets, " \t") {
+		return fmt.Errorf("invalid brackets %q, expected an opening and a closing character", layout.Brackets)
+	}
+	if layout.Columns == 0 {
+		layout.Columns = defaultTextLayout.Columns
+	}
+	if layout.Columns < TC_FUNCTION_FACILITY || layout.Columns > TC_FACILITY {
+		return fmt.Errorf("unknown text columns %d", layout.Columns)
+	}
+	l.textLayout = &layout
+	return nil
+}
+
+// formatEntryAs returns the line for an entry in the provided format, in the text layout and multiline mode of the
+// Logger for LF_TEXT
+func (l *Logger) formatEntryAs(e *Entry, format LogFormat) string {
+	if format == LF_TEXT && l.multiline > MM_ESCAPE && strings.Contains(e.Message, "\n") {
+		return l.formatMultiline(e)
+	}
+	switch {
+	case format == LF_TEXT && (l.textLayout != nil || l.timestamps != nil):
+		return formatText(e, l.sanitize, l.layout(), l.timestamps)
+	case format == LF_JSON && l.timestamps != nil:
+		return formatJSON(e, l.timestamps)
+	case format == LF_LOGFMT && l.timestamps != nil:
+		return formatLogfmt(e, l.timestamps)
+	}
+	return formatEntry(e, format, l.sanitize)
+}
+
+// layout returns the text layout of the Logger
+func (l *Logger) layout() *TextLayout {
+	if l.textLayout == nil {
+		return &defaultTextLayout
+	}
+	return l.textLayout
+}
+
+// formatText returns the line for an entry in LF_TEXT format and the layout, with the timestamps when not nil
+func formatText(e *Entry, sanitize SanitizeMode, layout *TextLayout, ts *timestamps) string {
+	b := getBuffer()
+	defer putBuffer(b)
+	var timestamp [80]byte
+	if ts.wallClock() {
+		b.Write(ts.appendText(timestamp[:0], e.Time))
+		b.WriteByte(' ')
+	}
+	if ts.elapsed() {
+		b.WriteByte('+')
+		b.Write(ts.appendSeconds(timestamp[:0], e.elapsed))
+		b.WriteByte(' ')
+		if ts.Delta {
+			b.WriteString("(+")
+			b.Write(ts.appendSeconds(timestamp[:0], e.delta))
+			b.WriteString(") ")
+		}
+	}
+	writePadded(b, levelLabel(e.Level), layout.LevelWidth)
+	b.WriteByte(' ')
+	function := layout.Brackets[:1] + sanitizeString(sanitize, e.Function) + layout.Brackets[1:] + " "
+	if layout.Columns == TC_FUNCTION_FACILITY {
+		b.WriteString(function)
+	}
+	facility := e.Prefix + "." + sanitizeString(sanitize, e.Source)
+	if layout.Truncate && utf8.RuneCountInString(facility) > layout.FacilityWidth {
+		runes := []rune(facility)
+		facility = truncationMark + string(runes[len(runes)-layout.FacilityWidth+1:])
+	}
+	writePadded(b, facility, layout.FacilityWidth)
+	b.WriteByte(' ')
+	if layout.Columns == TC_FACILITY_FUNCTION {
+		b.WriteString(function)
+	}
+	b.WriteString(sanitizeString(sanitize, e.Message))
+	if len(e.Fields) > 0 {
+		b.WriteString("\t" + formatFields(e.Fields))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writePadded writes text padded with spaces to width characters
+func writePadded(b *bytes.Buffer, text string, width int) {
+	b.WriteString(text)
+	for n := utf8.RuneCountInString(text); n < width; n++ {
+		b.WriteByte(' ')
+	}
+}
+
+// parseLayoutLine parses a line in LF_TEXT format and the layout, with the prefix as for ParseLine and timestamps in
+// location
+func parseLayoutLine(line string, prefix string, layout *TextLayout, location *time.Location) (Entry, error) {
+	var e Entry
+	var err error
+	rest := line
+	if !strings.HasPrefix(line, "+") {
+		if e.Time, rest, err = cutTextTime(line, location); err != nil {
+			return e, err
+		}
+		rest = strings.TrimLeft(rest, " ")
+	}
+	if strings.HasPrefix(rest, "+") {
+		if rest, err = cutElapsed(rest, &e); err != nil {
+			return e, err
+		}
+	}
+	label, rest, _ := strings.Cut(rest, " ")
+	var ok bool
+	e.Level, ok = levelFromLabel(label)
+	if !ok {
+		return e, fmt.Errorf("unknown level %q", label)
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if layout.Columns == TC_FUNCTION_FACILITY {
+		if e.Function, rest, ok = cutFunction(rest, layout.Brackets); !ok {
+			return e, errors.New("missing function")
+		}
+	}
+	facility, rest, _ := strings.Cut(rest, " ")
+	for n := utf8.RuneCountInString(facility) + 1; n <= layout.FacilityWidth && strings.HasPrefix(rest, " "); n++ {
+		rest = rest[1:]
+	}
+	if layout.Columns == TC_FACILITY_FUNCTION {
+		if e.Function, rest, ok = cutFunction(rest, layout.Brackets); !ok {
+			return e, errors.New("missing function")
+		}
+	}
+	switch {
+	case strings.HasPrefix(facility, truncationMark):
+		e.Source = facility
+	case prefix != "" && strings.HasPrefix(facility, prefix+"."):
+		e.Prefix, e.Source = prefix, facility[len(prefix)+1:]
+	default:
+		e.Prefix, e.Source, _ = strings.Cut(facility, ".")
+	}
+	message, fields, hasFields := strings.Cut(rest, "\t")
+	e.Message = message
+	if hasFields {
+		e.Fields = parseFields(fields)
+	}
+	return e, nil
+}
+
+// cutFunction cuts the function between brackets, followed by a space, from the start of s
+func cutFunction(s string, brackets string) (string, string, bool) {
+	if !strings.HasPrefix(s, brackets[:1]) {
+		return "", s, false
+	}
+	return strings.Cut(s[1:], brackets[1:]+" ")
+}
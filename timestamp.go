@@ -0,0 +1,356 @@
+package servicelogger
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ElapsedMode selects how the time elapsed since the start of the process is written
+type ElapsedMode int
+
+const (
+	EM_ALONGSIDE ElapsedMode = 1 // after the wall clock time
+	EM_INSTEAD   ElapsedMode = 2 // instead of the wall clock time
+)
+
+// processStart is the moment elapsed times are measured from
+var processStart = time.Now()
+
+// TimestampConfig configures the timestamps of the log file. Zero members get their default
+type TimestampConfig struct {
+	Elapsed ElapsedMode // writes the time elapsed since the start of the process, defaults to the wall clock time only
+	Delta   bool        // writes the time since the previous entry of the Logger after the elapsed time
+	// Nanoseconds writes times with nanoseconds instead of the microseconds of LF_TEXT lines and elapsed times, and with
+	// all nine decimals in LF_JSON and LF_LOGFMT, which otherwise drop trailing zeros
+	Nanoseconds bool
+	// RFC3339 starts LF_TEXT lines with the time in RFC 3339 format including its offset from UTC, such as
+	// 2006-01-02T15:04:05.000000+02:00, which is unambiguous when the logs of hosts in several time zones are combined
+	RFC3339 bool
+	// Location is the time zone times are written in, e.g. time.UTC or Europe/Amsterdam from time.LoadLocation,
+	// regardless of the time zone of the host, defaults to time.Local
+	Location *time.Location
+	// DualUTC writes the time in UTC followed by the time in Location. LF_TEXT lines then have both times, with their
+	// zone abbreviations unless they are in RFC3339 format, and LF_JSON and LF_LOGFMT lines have the time in UTC and a
+	// local_time member
+	DualUTC bool
+	// Zone writes the abbreviation of the time zone, such as CEST, after the times of LF_TEXT lines, and as a zone member
+	// of LF_JSON and LF_LOGFMT lines
+	Zone bool
+}
+
+// timestamps is the TimestampConfig of a Logger
+type timestamps struct {
+	TimestampConfig
+	since time.Time
+	last  atomic.Int64 // elapsed time of the previous entry in nanoseconds
+}
+
+// SetTimestamps sets the timestamps of the entries in the log file and the outputs of AddOutput. Elapsed times are
+// measured with the monotonic clock, so they are not affected by the wall clock of the host being adjusted, and are
+// written in seconds: LF_TEXT lines read "2006/01/02 15:04:05.000000 +12.345678 (+0.000123) INFO ...", or start with
+// the elapsed time in EM_INSTEAD mode, while LF_JSON and LF_LOGFMT have elapsed and delta members, and omit the time in
+// EM_INSTEAD mode. Entries read back return them from Elapsed and Delta. With SetClock, the time is measured from the
+// call of SetTimestamps instead of the start of the process. EM_INSTEAD cannot be combined with EnableIndex, which
+// needs the wall clock time. LogReader of the Logger reads LF_TEXT timestamps in its Location; other readers, such as
+// NewLogReader, expect local time unless the timestamps are in RFC3339 format. SetTimestamps must be called before the
+// Logger is used from multiple goroutines
+func (l *Logger) SetTimestamps(config TimestampConfig) error {
+	if config.Elapsed < 0 || config.Elapsed > EM_INSTEAD {
+		return errors.New("unknown elapsed mode")
+	}
+	if config.Delta && config.Elapsed == 0 {
+		return errors.New("the delta is written with the elapsed time only")
+	}
+	if config.Elapsed == EM_INSTEAD && l.indexed {
+		return errors.New("an indexed log file needs the wall clock time")
+	}
+	if config == (TimestampConfig{}) {
+		l.timestamps = nil
+		if l.index != nil {
+			l.index.location = time.Local
+		}
+		return nil
+	}
+	ts := &timestamps{TimestampConfig: config, since: processStart}
+	if l.clock != nil {
+		ts.since = l.clock.Now()
+	}
+	l.timestamps = ts
+	if l.index != nil {
+		l.index.location = ts.location()
+	}
+	return nil
+}
+
+// stamp sets the elapsed time of e and, when configured, the time since the previous entry. Entries logged
+// concurrently may be stamped in another order than they are written, in which case the delta is 0
+func (ts *timestamps) stamp(e *Entry) {
+	e.elapsed = e.Time.Sub(ts.since)
+	if !ts.Delta {
+		return
+	}
+	e.delta = e.elapsed - time.Duration(ts.last.Swap(int64(e.elapsed)))
+	if e.delta < 0 {
+		e.delta = 0
+	}
+}
+
+// wallClock reports whether the wall clock time is written
+func (ts *timestamps) wallClock() bool {
+	return ts == nil || ts.Elapsed != EM_INSTEAD
+}
+
+// elapsed reports whether the elapsed time is written
+func (ts *timestamps) elapsed() bool {
+	return ts != nil && ts.Elapsed != 0
+}
+
+// digits returns the number of decimals of the seconds of LF_TEXT timestamps and elapsed times
+func (ts *timestamps) digits() int {
+	if ts != nil && ts.Nanoseconds {
+		return 9
+	}
+	return 6
+}
+
+// location returns the time zone times are written in
+func (ts *timestamps) location() *time.Location {
+	if ts == nil || ts.Location == nil {
+		return time.Local
+	}
+	return ts.Location
+}
+
+// dualUTC reports whether the time is written in UTC as well as in Location
+func (ts *timestamps) dualUTC() bool {
+	return ts != nil && ts.DualUTC
+}
+
+// zone reports whether the abbreviation of the time zone is written
+func (ts *timestamps) zone() bool {
+	return ts != nil && ts.Zone
+}
+
+// localTime returns t in the time zone times are written in
+func (ts *timestamps) localTime(t time.Time) time.Time {
+	if ts == nil {
+		return t
+	}
+	return t.In(ts.location())
+}
+
+// appendText appends the timestamps of an LF_TEXT line for t
+func (ts *timestamps) appendText(b []byte, t time.Time) []byte {
+	if ts == nil {
+		return appendTime(b, t, 6, false)
+	}
+	if ts.DualUTC {
+		b = append(ts.appendTextTime(b, t.UTC()), ' ')
+	}
+	return ts.appendTextTime(b, ts.localTime(t))
+}
+
+// appendTextTime appends a single timestamp of an LF_TEXT line, with its zone abbreviation when needed
+func (ts *timestamps) appendTextTime(b []byte, t time.Time) []byte {
+	b = appendTime(b, t, ts.digits(), ts.RFC3339)
+	if ts.Zone || (ts.DualUTC && !ts.RFC3339) {
+		name, _ := t.Zone()
+		b = append(append(b, ' '), name...)
+	}
+	return b
+}
+
+// structuredTime returns t in the time zone of the time member of LF_JSON and LF_LOGFMT lines
+func (ts *timestamps) structuredTime(t time.Time) time.Time {
+	if ts.dualUTC() {
+		return t.UTC()
+	}
+	return ts.localTime(t)
+}
+
+// appendStructured appends a timestamp of an LF_JSON or LF_LOGFMT line for t
+func (ts *timestamps) appendStructured(b []byte, t time.Time) []byte {
+	if ts == nil || !ts.Nanoseconds {
+		return t.AppendFormat(b, time.RFC3339Nano)
+	}
+	return appendTime(b, t, 9, true)
+}
+
+// appendTime appends t as 2006/01/02 15:04:05, or in RFC 3339 format with its offset, with digits decimals of the
+// seconds. It is the equivalent of time.AppendFormat for these layouts, without parsing a layout for every entry
+func appendTime(b []byte, t time.Time, digits int, rfc3339 bool) []byte {
+	dateSeparator, separator := byte('/'), byte(' ')
+	if rfc3339 {
+		dateSeparator, separator = '-', 'T'
+	}
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	b = appendDigits(b, year, 4)
+	b = appendDigits(append(b, dateSeparator), int(month), 2)
+	b = appendDigits(append(b, dateSeparator), day, 2)
+	b = appendDigits(append(b, separator), hour, 2)
+	b = appendDigits(append(b, ':'), minute, 2)
+	b = appendDigits(append(b, ':'), second, 2)
+	if digits > 0 {
+		fraction := t.Nanosecond()
+		for n := digits; n < 9; n++ {
+			fraction /= 10
+		}
+		b = appendDigits(append(b, '.'), fraction, digits)
+	}
+	if !rfc3339 {
+		return b
+	}
+	_, offset := t.Zone()
+	if offset == 0 {
+		return append(b, 'Z')
+	}
+	sign := byte('+')
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	b = appendDigits(append(b, sign), offset/3600, 2)
+	return appendDigits(append(b, ':'), offset/60%60, 2)
+}
+
+// appendDigits appends v with leading zeros to width digits
+func appendDigits(b []byte, v int, width int) []byte {
+	var digits [20]byte
+	n := len(digits)
+	for v >= 10 || width > 1 {
+		n--
+		digits[n] = byte('0' + v%10)
+		v /= 10
+		width--
+	}
+	n--
+	digits[n] = byte('0' + v)
+	return append(b, digits[n:]...)
+}
+
+// cutTextTime parses the timestamps at the start of an LF_TEXT line, with any number of decimals, in either layout and
+// with or without zone abbreviations, and returns the rest of the line. Timestamps without an offset are in location,
+// or in UTC when their zone is. Of two timestamps, as written with DualUTC, the first is used
+func cutTextTime(line string, location *time.Location) (time.Time, string, error) {
+	t, rest, err := cutTextStamp(line, location)
+	if err != nil {
+		return t, rest, err
+	}
+	zone, after, ok := cutZone(rest)
+	if !ok {
+		return t, rest, nil
+	}
+	if zone == "UTC" && t.Location() != time.UTC && line[10] != 'T' {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+	rest = after
+	if _, after, err = cutTextStamp(strings.TrimLeft(rest, " "), location); err == nil {
+		rest = after
+		if _, after, ok = cutZone(rest); ok {
+			rest = after
+		}
+	}
+	return t, rest, nil
+}
+
+// cutTextStamp parses a single timestamp at the start of s and returns the rest of s
+func cutTextStamp(s string, location *time.Location) (time.Time, string, error) {
+	if len(s) < len(textTimeLayout) || (s[4] != '/' && s[4] != '-') {
+		return time.Time{}, s, errors.New("missing timestamp")
+	}
+	if s[10] == 'T' {
+		stamp, _, _ := strings.Cut(s, " ")
+		t, err := time.Parse(time.RFC3339Nano, stamp)
+		return t, s[len(stamp):], err
+	}
+	end := len(textTimeLayout)
+	if end < len(s) && s[end] == '.' {
+		for end++; end < len(s) && s[end] >= '0' && s[end] <= '9'; end++ {
+		}
+	}
+	t, err := time.ParseInLocation(textTimeLayout, s[:end], location)
+	return t, s[end:], err
+}
+
+// cutZone cuts a zone abbreviation, such as CEST or -03, that follows a timestamp from the start of s. Level labels
+// are never taken for zones
+func cutZone(s string) (string, string, bool) {
+	word, rest, _ := strings.Cut(strings.TrimLeft(s, " "), " ")
+	if word == "" {
+		return "", s, false
+	}
+	if _, ok := levelFromLabel(word); ok {
+		return "", s, false
+	}
+	for n, c := range word {
+		letter := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+		if !letter && !(n > 0 && c >= '0' && c <= '9') && !(n == 0 && (c == '+' || c == '-')) {
+			return "", s, false
+		}
+	}
+	return word, " " + rest, true
+}
+
+// Elapsed returns the time since the start of the process the entry was logged at, when the Logger writes elapsed
+// times, or 0
+func (e *Entry) Elapsed() time.Duration {
+	return e.elapsed
+}
+
+// Delta returns the time since the previous entry of the Logger, when the Logger writes it, or 0
+func (e *Entry) Delta() time.Duration {
+	return e.delta
+}
+
+// appendSeconds appends d in seconds with the decimals of the timestamps
+func (ts *timestamps) appendSeconds(b []byte, d time.Duration) []byte {
+	b = strconv.AppendInt(b, int64(d/time.Second), 10)
+	fraction := int(d % time.Second)
+	digits := ts.digits()
+	for n := digits; n < 9; n++ {
+		fraction /= 10
+	}
+	return appendDigits(append(b, '.'), fraction, digits)
+}
+
+// parseSeconds parses a number of seconds as written by appendSeconds, with up to nine decimals
+func parseSeconds(s string) (time.Duration, error) {
+	whole, fraction, _ := strings.Cut(s, ".")
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || seconds < 0 || len(fraction) > 9 {
+		return 0, errors.New("invalid number of seconds")
+	}
+	d := time.Duration(seconds) * time.Second
+	scale := time.Duration(100000000)
+	for _, c := range fraction {
+		if c < '0' || c > '9' {
+			return 0, errors.New("invalid number of seconds")
+		}
+		d += time.Duration(c-'0') * scale
+		scale /= 10
+	}
+	return d, nil
+}
+
+// cutElapsed cuts the elapsed time and the delta of an LF_TEXT line, as written by formatText, from the start of s
+func cutElapsed(s string, e *Entry) (string, error) {
+	word, rest, _ := strings.Cut(s, " ")
+	var err error
+	if e.elapsed, err = parseSeconds(word[1:]); err != nil {
+		return s, err
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if strings.HasPrefix(rest, "(+") {
+		word, rest, _ = strings.Cut(rest, " ")
+		if !strings.HasSuffix(word, ")") {
+			return s, errors.New("invalid delta")
+		}
+		if e.delta, err = parseSeconds(word[2 : len(word)-1]); err != nil {
+			return s, err
+		}
+	}
+	return strings.TrimLeft(rest, " "), nil
+}
@@ -0,0 +1,31 @@
+package servicelogger
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeOperation logs the start of an operation at TRACE level and returns a function that logs its completion with the
+// elapsed time, at INFO level or at WARN level when it took longer than the threshold set with SetSlowOperation. The
+// completion entry has the field elapsed_ms:
+//
+//	done := l.TimeOperation("RefreshCache", "cache")
+//	defer done()
+func (l *Logger) TimeOperation(function string, source string) func() {
+	start := time.Now()
+	l.log(LL_TRACE, function, source, "Operation started", nil)
+	return func() {
+		elapsed := time.Since(start)
+		level := LL_INFO
+		if l.slowOperation > 0 && elapsed > l.slowOperation {
+			level = LL_WARN
+		}
+		l.log(level, function, source, fmt.Sprintf("Operation completed in %s", elapsed.Round(time.Microsecond)), map[string]interface{}{"elapsed_ms": float64(elapsed) / float64(time.Millisecond)})
+	}
+}
+
+// SetSlowOperation sets the duration above which TimeOperation logs the completion of an operation at WARN level, 0
+// never warns. SetSlowOperation must be called before the Logger is used from multiple goroutines
+func (l *Logger) SetSlowOperation(threshold time.Duration) {
+	l.slowOperation = threshold
+}
@@ -0,0 +1,53 @@
+package servicelogger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig configures the TLS connections of a network sink. Zero members get their default
+type TLSConfig struct {
+	CAFile       string   // PEM bundle of the certificate authorities that verify the server, the system roots when empty
+	CertFile     string   // PEM file with the client certificate for mutual TLS, none when empty
+	KeyFile      string   // PEM file with the private key of the client certificate
+	ServerName   string   // name the certificate of the server is verified against, defaults to the host of the address
+	MinVersion   uint16   // lowest protocol version, such as tls.VersionTLS13, defaults to tls.VersionTLS12
+	CipherSuites []uint16 // cipher suites allowed up to TLS 1.2, the defaults of crypto/tls when empty
+}
+
+// config returns the crypto/tls configuration. The files are read once, so a sink has to be created again to pick up
+// renewed certificates
+func (c *TLSConfig) config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:   c.ServerName,
+		MinVersion:   c.MinVersion,
+		CipherSuites: c.CipherSuites,
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("a client certificate requires both a certificate and a key file")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,72 @@
+package servicelogger
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header HTTPMiddleware takes a traceparent from
+const TraceparentHeader = "traceparent"
+
+// Traceparent is a parsed W3C traceparent value
+type Traceparent struct {
+	TraceID string // 32 lowercase hexadecimal digits
+	SpanID  string // 16 lowercase hexadecimal digits of the parent span
+	Flags   byte   // trace flags, bit 0 being sampled
+}
+
+// Sampled reports whether the caller may have recorded the trace
+func (t Traceparent) Sampled() bool {
+	return t.Flags&1 != 0
+}
+
+// ParseTraceparent parses a W3C traceparent value such as "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01".
+// Values of a later version are accepted as long as they start like version 00, as the specification requires
+func ParseTraceparent(value string) (Traceparent, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return Traceparent{}, errors.New("malformed traceparent")
+	}
+	version, traceID, spanID, flags := value[:2], value[3:35], value[36:52], value[53:55]
+	if !lowerHex(version) || version == "ff" || (version == "00" && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return Traceparent{}, errors.New("invalid traceparent version")
+	}
+	if !lowerHex(traceID) || strings.Trim(traceID, "0") == "" {
+		return Traceparent{}, errors.New("invalid trace ID in traceparent")
+	}
+	if !lowerHex(spanID) || strings.Trim(spanID, "0") == "" {
+		return Traceparent{}, errors.New("invalid parent ID in traceparent")
+	}
+	if !lowerHex(flags) {
+		return Traceparent{}, errors.New("invalid trace flags in traceparent")
+	}
+	flagBits, _ := strconv.ParseUint(flags, 16, 8)
+	return Traceparent{TraceID: traceID, SpanID: spanID, Flags: byte(flagBits)}, nil
+}
+
+// ContextWithTraceparent returns a copy of ctx carrying a W3C traceparent value, e.g. the header a front proxy added
+// to the request. It is parsed when an entry is logged, so an invalid value is simply ignored
+func ContextWithTraceparent(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, traceparentKey, value)
+}
+
+// TraceparentFromContext returns the traceparent carried by ctx, if it holds a valid one
+func TraceparentFromContext(ctx context.Context) (Traceparent, bool) {
+	value, ok := ctx.Value(traceparentKey).(string)
+	if !ok {
+		return Traceparent{}, false
+	}
+	t, err := ParseTraceparent(value)
+	return t, err == nil
+}
+
+func lowerHex(s string) bool {
+	for n := 0; n < len(s); n++ {
+		if (s[n] < '0' || s[n] > '9') && (s[n] < 'a' || s[n] > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package servicelogger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// UnixSocketConfig configures a sink returned by NewUnixSocketSink. Zero members get their default
+type UnixSocketConfig struct {
+	Path     string        // path of the socket
+	Datagram bool          // send every entry as a datagram instead of as a line on a stream connection
+	Format   LogFormat     // format of the entries, defaults to LF_JSON
+	Timeout  time.Duration // timeout of connecting and writing, defaults to 5 seconds
+	Redial   time.Duration // minimum time between two attempts to connect, defaults to one second
+}
+
+// unixSocketSink writes entries to a Unix domain socket
+type unixSocketSink struct {
+	mu       sync.Mutex
+	config   UnixSocketConfig
+	conn     net.Conn
+	dialedAt time.Time
+}
+
+// NewUnixSocketSink returns a Sink that writes every entry to a Unix domain socket, such as that of a sidecar collector,
+// as a line on a stream connection or as one datagram per entry. The socket does not need to exist yet: the sink
+// connects on the first entry, and reconnects when the listener has gone away and come back, at most once per redial
+// interval. Entries written while it cannot connect fail, so that a RetrySink or the circuit breaker of AddSink can
+// take care of them
+func NewUnixSocketSink(config UnixSocketConfig) (Sink, error) {
+	if config.Path == "" {
+		return nil, errors.New("socket path is required")
+	}
+	if config.Format == 0 {
+		config.Format = LF_JSON
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Redial <= 0 {
+		config.Redial = time.Second
+	}
+	return &unixSocketSink{config: config}, nil
+}
+
+func (s *unixSocketSink) WriteEntry(e *Entry) error {
+	line := formatEntry(e, s.config.Format, SM_ESCAPE)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reconnected := false
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+		reconnected = true
+	}
+	err := s.write(line)
+	if err == nil || reconnected {
+		return err
+	}
+	// the listener may have been restarted: reconnect and try once more
+	if err = s.dial(); err != nil {
+		return err
+	}
+	return s.write(line)
+}
+
+// dial connects to the socket, unless the last attempt was less than the redial interval ago. It must be called with
+// s.mu held
+func (s *unixSocketSink) dial() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if time.Since(s.dialedAt) < s.config.Redial {
+		return fmt.Errorf("not connected to %s", s.config.Path)
+	}
+	s.dialedAt = time.Now()
+	network := "unix"
+	if s.config.Datagram {
+		network = "unixgram"
+	}
+	conn, err := net.DialTimeout(network, s.config.Path, s.config.Timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// write sends a line over the connection, closing it on failure. It must be called with s.mu held
+func (s *unixSocketSink) write(line string) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the socket
+func (s *unixSocketSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
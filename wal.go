@@ -0,0 +1,471 @@
+package servicelogger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Suffixes of the segment files of a write-ahead log and of the acknowledged offsets of its sinks
+const (
+	walSuffix    = ".wal"
+	walAckSuffix = ".ack"
+)
+
+// WALConfig configures the write-ahead log of a Logger. Zero members get their default
+type WALConfig struct {
+	Dir         string   // directory of the write-ahead log, created when missing
+	Facilities  []string // facility prefixes, as used by facility filters, of the entries that go through the log. All entries when empty
+	SegmentSize int64    // bytes a segment file holds before the next one is started, defaults to 16 MiB
+}
+
+// WALSinkConfig configures a sink added with AddWALSink. Zero members get their default
+type WALSinkConfig struct {
+	Name     string      // name of the sink, which names the file of its acknowledged offset. Required, and must not change between runs
+	MinLevel LogLevel    // lowest level the sink receives, all entries of the log when zero. Other entries are acknowledged unseen
+	Fields   FieldFilter // fields the sink receives
+	Retry    RetryPolicy // retry policy of every delivery. When the policy gives up, the entry is tried again after MaxBackoff
+}
+
+// wal is a write-ahead log: segment files of LF_BINARY frames named after their sequence number. Every append is
+// synced to disk before it is made visible to the sinks reading the log
+type wal struct {
+	mu          sync.Mutex
+	dir         string
+	facilities  []string
+	segmentSize int64
+	segments    []*walSegment
+	active      *os.File
+	next        uint64
+	closed      bool
+	consumers   []*walConsumer
+}
+
+// walSegment is a segment file of a write-ahead log. size counts the bytes that were synced, so readers never see part
+// of a frame
+type walSegment struct {
+	seq  uint64
+	name string
+	size int64
+}
+
+// walConsumer delivers the entries of a write-ahead log to a sink, recording the position after the last delivered
+// entry as segment and offset
+type walConsumer struct {
+	l       Logger
+	w       *wal
+	sink    Sink
+	config  WALSinkConfig
+	ackName string
+	seq     uint64
+	offset  int64
+	acked   atomic.Uint64
+	wake    chan struct{}
+	stopped chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// EnableWAL makes the Logger append the entries of the configured facilities, such as audit facilities, to a
+// write-ahead log before anything else is done with them. Every append is synced to disk before the log call continues;
+// appends that fail are counted in Stats. Sinks added with AddWALSink read the log from goroutines of their own and
+// durably acknowledge every entry they delivered, so that after a crash or restart they continue after the last
+// acknowledged entry: acknowledged entries are never delivered again, and an entry is only delivered twice when the
+// process stopped between its delivery and its acknowledgement. Segments are removed once every WAL sink acknowledged
+// all of their entries. EnableWAL must be called before the Logger is used from multiple goroutines
+func (l *Logger) EnableWAL(config WALConfig) error {
+	if l.wal != nil {
+		return errors.New("write-ahead log is already enabled")
+	}
+	if config.Dir == "" {
+		return errors.New("write-ahead log needs a directory")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = 16 * 1024 * 1024
+	}
+	w, err := openWAL(config)
+	if err != nil {
+		return err
+	}
+	l.wal = w
+	l.drainers = append(l.drainers, w)
+	return nil
+}
+
+// AddWALSink adds a destination that receives the entries of the write-ahead log in the order they were logged,
+// starting after the last entry it acknowledged in a previous run. Failed deliveries are retried until they succeed,
+// so the sink never skips an entry; entries the sink rejects with a Permanent error are logged as an error and
+// acknowledged. AddWALSink must be called after EnableWAL and before the Logger is used from multiple goroutines
+func (l *Logger) AddWALSink(s Sink, config WALSinkConfig) error {
+	if l.wal == nil {
+		return errors.New("write-ahead log is not enabled")
+	}
+	if config.Name == "" || strings.ContainsAny(config.Name, `/\`) {
+		return errors.New("WAL sink needs a name that can be used as a file name")
+	}
+	for _, c := range l.wal.consumers {
+		if c.config.Name == config.Name {
+			return fmt.Errorf("WAL sink %s already exists", config.Name)
+		}
+	}
+	config.Retry = config.Retry.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &walConsumer{
+		l:       *l,
+		w:       l.wal,
+		sink:    s,
+		config:  config,
+		ackName: filepath.Join(l.wal.dir, config.Name+walAckSuffix),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if err := c.load(); err != nil {
+		cancel()
+		return err
+	}
+	l.wal.mu.Lock()
+	l.wal.consumers = append(l.wal.consumers, c)
+	l.wal.mu.Unlock()
+	go c.run()
+	return nil
+}
+
+// openWAL opens the write-ahead log in config.Dir, picking up the segments left by a previous process. Appends always
+// start a new segment, numbered after the last segment and after every acknowledged position, so that a sink that
+// acknowledged the segments of a previous process that have since been removed does not skip the new ones
+func openWAL(config WALConfig) (*wal, error) {
+	if err := os.MkdirAll(config.Dir, 0750); err != nil {
+		return nil, err
+	}
+	w := &wal{dir: config.Dir, facilities: config.Facilities, segmentSize: config.SegmentSize, next: 1}
+	names, err := filepath.Glob(filepath.Join(config.Dir, "*"+walSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), walSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		w.segments = append(w.segments, &walSegment{seq: seq, name: name, size: info.Size()})
+		w.next = seq + 1
+	}
+	acks, err := filepath.Glob(filepath.Join(config.Dir, "*"+walAckSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range acks {
+		var seq uint64
+		var offset int64
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		// a corrupt position is reported when its sink is added
+		if _, err = fmt.Sscanf(string(data), "%x %d", &seq, &offset); err == nil && seq > w.next {
+			w.next = seq
+		}
+	}
+	if len(w.segments) > 0 {
+		// the last segment may end in a frame cut short by a crash, which was never acknowledged and is cut off
+		last := w.segments[len(w.segments)-1]
+		size, err := completeFrames(last.name)
+		if err != nil {
+			return nil, err
+		}
+		if size < last.size {
+			if err = os.Truncate(last.name, size); err != nil {
+				return nil, err
+			}
+			last.size = size
+		}
+	}
+	return w, nil
+}
+
+// completeFrames returns the number of bytes taken by the complete frames at the start of a file
+func completeFrames(name string) (int64, error) {
+	fh, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+	br := bufio.NewReader(fh)
+	var size int64
+	for {
+		_, n, err := readBinaryFrameSize(br)
+		if err != nil {
+			return size, nil
+		}
+		size += n
+	}
+}
+
+// syncDir syncs a directory, so that the files created or renamed in it survive a crash. Platforms that cannot sync a
+// directory are left to their own guarantees
+func syncDir(dir string) {
+	if fh, err := os.Open(dir); err == nil {
+		_ = fh.Sync()
+		fh.Close()
+	}
+}
+
+// matches reports whether e belongs to a facility that goes through the log
+func (w *wal) matches(e *Entry) bool {
+	return facilityMatches(w.facilities, e.Facility())
+}
+
+// append writes e to the active segment and syncs it, starting a new segment when the active one is full. A failed
+// append is cut off again, so that the segment stays readable
+func (w *wal) append(e *Entry) error {
+	frame := formatBinary(e)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("write-ahead log is drained")
+	}
+	if w.active == nil || w.segments[len(w.segments)-1].size >= w.segmentSize {
+		if err := w.roll(); err != nil {
+			return err
+		}
+	}
+	seg := w.segments[len(w.segments)-1]
+	_, err := w.active.WriteString(frame)
+	if err == nil {
+		err = w.active.Sync()
+	}
+	if err != nil {
+		_ = w.active.Truncate(seg.size)
+		return err
+	}
+	seg.size += int64(len(frame))
+	for _, c := range w.consumers {
+		c.notify()
+	}
+	return nil
+}
+
+// roll closes the active segment and starts a new one
+func (w *wal) roll() error {
+	if w.active != nil {
+		w.active.Close()
+		w.active = nil
+	}
+	name := filepath.Join(w.dir, fmt.Sprintf("%016x%s", w.next, walSuffix))
+	fh, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	syncDir(w.dir)
+	w.segments = append(w.segments, &walSegment{seq: w.next, name: name})
+	w.next++
+	w.active = fh
+	return nil
+}
+
+// segmentAt returns the first segment with a sequence number of at least seq, the number of bytes of it that can be
+// read, and whether it is complete because nothing is appended to it anymore
+func (w *wal) segmentAt(seq uint64) (*walSegment, int64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for n, seg := range w.segments {
+		if seg.seq >= seq {
+			return seg, seg.size, n < len(w.segments)-1 || w.active == nil
+		}
+	}
+	return nil, 0, false
+}
+
+// release removes the segments that every WAL sink has acknowledged completely
+func (w *wal) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	oldest := w.next
+	for _, c := range w.consumers {
+		if seq := c.acked.Load(); seq < oldest {
+			oldest = seq
+		}
+	}
+	for len(w.segments) > 0 && w.segments[0].seq < oldest {
+		_ = os.Remove(w.segments[0].name)
+		w.segments = w.segments[1:]
+	}
+}
+
+func (w *wal) drained() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// Drain stops appending to the log, waits until every WAL sink has delivered all entries and stops the sinks, giving
+// up when ctx is done. Entries that were not delivered stay in the log for the next process, so none are counted
+func (w *wal) Drain(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	w.closed = true
+	if w.active != nil {
+		w.active.Close()
+		w.active = nil
+	}
+	consumers := w.consumers
+	for _, c := range consumers {
+		c.notify()
+	}
+	w.mu.Unlock()
+	var err error
+	for _, c := range consumers {
+		select {
+		case <-c.stopped:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		c.cancel()
+	}
+	return 0, err
+}
+
+// load reads the acknowledged offset of the sink. Without one the sink starts at the beginning of the log
+func (c *walConsumer) load() error {
+	data, err := os.ReadFile(c.ackName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Sscanf(string(data), "%x %d", &c.seq, &c.offset); err != nil {
+		return fmt.Errorf("corrupt acknowledged offset in %s: %w", c.ackName, err)
+	}
+	c.acked.Store(c.seq)
+	return nil
+}
+
+// ack durably records the current position. The file is replaced, so that a crash leaves either the old or the new
+// position
+func (c *walConsumer) ack() error {
+	tmp := c.ackName + ".tmp"
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(fh, "%016x %d\n", c.seq, c.offset)
+	if err == nil {
+		err = fh.Sync()
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.ackName)
+	}
+	if err != nil {
+		return err
+	}
+	syncDir(c.w.dir)
+	c.acked.Store(c.seq)
+	return nil
+}
+
+func (c *walConsumer) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *walConsumer) run() {
+	defer close(c.stopped)
+	for c.ctx.Err() == nil {
+		seg, size, complete := c.w.segmentAt(c.seq)
+		if seg != nil && seg.seq != c.seq {
+			// the segment after the acknowledged one, or the oldest one when the acknowledged segment is gone
+			c.seq, c.offset = seg.seq, 0
+		}
+		switch {
+		case seg != nil && c.offset < size:
+			if !c.deliver(seg, size) {
+				c.sleep(c.config.Retry.MaxBackoff)
+			}
+			continue
+		case seg != nil && complete:
+			c.seq, c.offset = seg.seq+1, 0
+			// without the acknowledgement the segment is kept, and the position is recorded with the next entry
+			if c.ack() == nil {
+				c.w.release()
+			}
+			continue
+		}
+		// every entry has been delivered
+		if c.w.drained() {
+			return
+		}
+		select {
+		case <-c.wake:
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+func (c *walConsumer) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	select {
+	case <-timer.C:
+	case <-c.ctx.Done():
+		timer.Stop()
+	}
+}
+
+// deliver hands the entries of seg from the current offset up to size to the sink, acknowledging every one of them. It
+// returns false when the sink keeps failing, leaving the rest for the next attempt
+func (c *walConsumer) deliver(seg *walSegment, size int64) bool {
+	fh, err := os.Open(seg.name)
+	if err != nil {
+		c.l.writeInternal(LL_ERROR, "deliver", fmt.Sprintf("Unable to read write-ahead log segment %s: %s", seg.name, err.Error()))
+		return false
+	}
+	defer fh.Close()
+	br := bufio.NewReader(io.NewSectionReader(fh, c.offset, size-c.offset))
+	for c.offset < size {
+		e, n, err := readBinaryFrameSize(br)
+		if err != nil {
+			// the synced part of a segment only holds complete frames, so the file was damaged
+			c.l.writeInternal(LL_ERROR, "deliver", fmt.Sprintf("Skipping the damaged rest of write-ahead log segment %s from offset %d for WAL sink %s: %s", seg.name, c.offset, c.config.Name, err.Error()))
+			c.offset = size
+			return c.ack() == nil
+		}
+		if e.Level.atLeast(c.config.MinLevel) {
+			_, err = c.config.Retry.retry(c.ctx, func() error {
+				return c.sink.WriteEntry(c.config.Fields.apply(&e))
+			})
+		}
+		if err != nil && !IsPermanent(err) {
+			return false
+		}
+		if err != nil {
+			c.l.writeInternal(LL_ERROR, "deliver", fmt.Sprintf("WAL sink %s rejected the entry at offset %d of %s: %s", c.config.Name, c.offset, seg.name, err.Error()))
+		}
+		c.offset += n
+		if err = c.ack(); err != nil {
+			c.l.writeInternal(LL_ERROR, "deliver", fmt.Sprintf("Unable to acknowledge the entries delivered to WAL sink %s: %s", c.config.Name, err.Error()))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package servicelogger
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// walRun logs message through a write-ahead log in dir and shuts down cleanly, returning the messages its sink received
+func walRun(t *testing.T, dir string, message string) []string {
+	t.Helper()
+	l0, err := New("test", filepath.Join(t.TempDir(), "test.log"), LL_INFO, false, "10M", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &l0
+	if err = l.EnableWAL(WALConfig{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	sink := &messageSink{}
+	if err = l.AddWALSink(sink, WALSinkConfig{Name: "sink"}); err != nil {
+		t.Fatal(err)
+	}
+	l.LogInfo("TestWAL", "wal", message)
+	if err = l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sink.messages
+}
+
+func TestWALDeliversAfterCleanRestart(t *testing.T) {
+	dir := t.TempDir()
+	for _, message := range []string{"first run", "second run", "third run"} {
+		if got := walRun(t, dir, message); len(got) != 1 || got[0] != message {
+			t.Fatalf("sink received %q, want [%q]", got, message)
+		}
+	}
+}
+
+// messageSink keeps the messages of the entries it receives
+type messageSink struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *messageSink) WriteEntry(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, e.Message)
+	return nil
+}
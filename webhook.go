@@ -0,0 +1,94 @@
+package servicelogger
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type WebhookKind int
+
+const (
+	WK_SLACK WebhookKind = 1
+	WK_TEAMS WebhookKind = 2
+)
+
+// WebhookConfig configures notifications posted to a Slack or Microsoft Teams incoming webhook. Zero members get their
+// default
+type WebhookConfig struct {
+	URL        string        // URL of the incoming webhook
+	Kind       WebhookKind   // kind of webhook, defaults to WK_SLACK
+	MinLevel   LogLevel      // lowest level notified, defaults to LL_FATAL
+	Facilities []string      // facility prefixes notified, all facilities when empty
+	Throttle   time.Duration // minimum time between two notifications for the same facility, defaults to one minute
+	Timeout    time.Duration // timeout of a single request, defaults to 10 seconds
+	Retry      RetryPolicy   // retry policy of failed requests
+}
+
+// EnableWebhookNotifications posts a message to a Slack or Microsoft Teams incoming webhook for every entry at or
+// above the configured level in one of the configured facilities, at most once per throttle interval per facility
+func (l *Logger) EnableWebhookNotifications(config WebhookConfig) (*Notifier, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if config.Kind == 0 {
+		config.Kind = WK_SLACK
+	}
+	if config.MinLevel == 0 {
+		config.MinLevel = LL_FATAL
+	}
+	if config.Throttle <= 0 {
+		config.Throttle = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: config.Timeout}
+	n := l.newNotifier(0, config.Retry, func(no notification) error {
+		return postWebhook(client, config, no)
+	})
+	t := newThrottle(config.Throttle)
+	l.AddBeforeWriteHook(func(e *Entry) {
+		if !e.Level.atLeast(config.MinLevel) || !facilityMatches(config.Facilities, e.Facility()) {
+			return
+		}
+		ok, suppressed := t.allow(e.Facility(), e.Time)
+		if !ok {
+			n.throttled.Add(1)
+			return
+		}
+		n.submit(notification{entries: []Entry{*e}, suppressed: suppressed})
+	})
+	return n, nil
+}
+
+func postWebhook(client *http.Client, config WebhookConfig, no notification) error {
+	e := no.entries[0]
+	title := fmt.Sprintf("%s in %s", LogLevelToString(e.Level), e.Facility())
+	var text strings.Builder
+	text.WriteString(e.Message)
+	if len(e.Fields) > 0 {
+		text.WriteString("\n" + formatFields(e.Fields))
+	}
+	if no.suppressed > 0 {
+		fmt.Fprintf(&text, "\n(%d similar notifications suppressed)", no.suppressed)
+	}
+	text.WriteString("\n" + e.Time.Format(time.RFC3339))
+	var payload map[string]interface{}
+	if config.Kind == WK_TEAMS {
+		payload = map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"title":      title,
+			"themeColor": "D00000",
+			"text":       strings.ReplaceAll(text.String(), "\n", "<br>"),
+		}
+	} else {
+		payload = map[string]interface{}{
+			"text": fmt.Sprintf("*%s*\n```%s```", title, text.String()),
+		}
+	}
+	return postJSON(client, config.URL, payload, nil)
+}
@@ -0,0 +1,10 @@
+module github.com/quadtrix/servicelogger/winservice
+
+go 1.20
+
+require (
+	github.com/quadtrix/servicelogger v0.0.0
+	golang.org/x/sys v0.10.0
+)
+
+replace github.com/quadtrix/servicelogger => ../
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package winservice
+
+import "github.com/quadtrix/servicelogger"
+
+// Run runs the service in the foreground until it returns or the process is interrupted. Only Windows has a Service
+// Control Manager, so this lets services that use Run be built and tried out on other platforms
+func Run(config Config, open func() (*servicelogger.Logger, error), run RunFunc) error {
+	return runInteractive(config.withDefaults(), open, run)
+}
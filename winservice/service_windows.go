@@ -0,0 +1,119 @@
+//go:build windows
+
+package winservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// Run runs a Windows service that logs through the Logger returned by open. The start of the service is logged with a
+// banner, stop and shutdown requests cancel the context of run, pause and continue requests call config.Pause and
+// config.Continue, and when run has returned the Logger is shut down cleanly. When open fails, the error is written to
+// the Windows event log under config.Name and reported to the Service Control Manager as ExitLoggerFailed; a service
+// that fails or does not stop in time is reported as ExitServiceFailed. Outside the Service Control Manager, e.g.
+// from a console, the service runs in the foreground until it is interrupted
+func Run(config Config, open func() (*servicelogger.Logger, error), run RunFunc) error {
+	config = config.withDefaults()
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return runInteractive(config, open, run)
+	}
+	h := &handler{config: config, open: open, run: run}
+	if err = svc.Run(config.Name, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+// handler implements svc.Handler
+type handler struct {
+	config Config
+	open   func() (*servicelogger.Logger, error)
+	run    RunFunc
+	err    error
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	l, err := h.open()
+	if err != nil {
+		h.err = fmt.Errorf("unable to initialize logging: %w", err)
+		reportEvent(h.config.Name, h.err.Error())
+		return true, ExitLoggerFailed
+	}
+	banner(l, h.config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx, l)
+	}()
+	accepts := svc.AcceptStop | svc.AcceptShutdown
+	if h.config.Pause != nil {
+		accepts |= svc.AcceptPauseAndContinue
+	}
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case h.err = <-done:
+			// the service ended by itself
+			code := finish(l, h.config, h.err)
+			return code != 0, code
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				l.LogInfo("Execute", "winservice", fmt.Sprintf("Service %s received a stop request, stopping", h.config.Name))
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(2 * h.config.StopTimeout / time.Millisecond)}
+				cancel()
+				timer := time.NewTimer(h.config.StopTimeout)
+				select {
+				case h.err = <-done:
+					timer.Stop()
+				case <-timer.C:
+					h.err = errors.New("service did not stop in time")
+				}
+				code := finish(l, h.config, h.err)
+				return code != 0, code
+			case svc.Pause:
+				if err := h.config.Pause(); err != nil {
+					l.LogError("Execute", "winservice", fmt.Sprintf("Unable to pause service %s: %s", h.config.Name, err.Error()))
+					continue
+				}
+				l.LogInfo("Execute", "winservice", fmt.Sprintf("Service %s paused", h.config.Name))
+				status <- svc.Status{State: svc.Paused, Accepts: accepts}
+			case svc.Continue:
+				if h.config.Continue != nil {
+					if err := h.config.Continue(); err != nil {
+						l.LogError("Execute", "winservice", fmt.Sprintf("Unable to continue service %s: %s", h.config.Name, err.Error()))
+						continue
+					}
+				}
+				l.LogInfo("Execute", "winservice", fmt.Sprintf("Service %s continued", h.config.Name))
+				status <- svc.Status{State: svc.Running, Accepts: accepts}
+			}
+		}
+	}
+}
+
+// reportEvent writes an error to the Windows event log, for failures that cannot be logged through the Logger. The
+// event source must have been registered when the service was installed, e.g. with eventlog.InstallAsEventCreate
+func reportEvent(name string, text string) {
+	elog, err := eventlog.Open(name)
+	if err != nil {
+		return
+	}
+	defer elog.Close()
+	_ = elog.Error(ExitLoggerFailed, text)
+}
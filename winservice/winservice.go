@@ -0,0 +1,77 @@
+// Package winservice - runs a Windows service that logs its lifecycle through servicelogger
+package winservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/quadtrix/servicelogger"
+)
+
+// Exit codes reported to the Service Control Manager as service specific exit codes
+const (
+	ExitLoggerFailed  = 1 // the Logger could not be opened
+	ExitServiceFailed = 2 // the service returned an error or did not stop in time
+)
+
+// Config configures Run. Zero members get their default
+type Config struct {
+	Name        string        // name of the service, as registered with the Service Control Manager
+	Version     string        // version in the startup banner, left out when empty
+	StopTimeout time.Duration // time the service and then the Logger get to stop, defaults to 20 seconds
+	Pause       func() error  // called when the service is paused, pausing is not offered when nil
+	Continue    func() error  // called when a paused service continues
+}
+
+// RunFunc is the body of a service. It runs until ctx is done, which happens when the service is stopped, and returns
+// nil for a clean stop
+type RunFunc func(ctx context.Context, l *servicelogger.Logger) error
+
+func (c Config) withDefaults() Config {
+	if c.StopTimeout <= 0 {
+		c.StopTimeout = 20 * time.Second
+	}
+	return c
+}
+
+// banner logs the start of the service
+func banner(l *servicelogger.Logger, config Config) {
+	text := fmt.Sprintf("Service %s starting, pid %d", config.Name, os.Getpid())
+	if config.Version != "" {
+		text = fmt.Sprintf("Service %s %s starting, pid %d", config.Name, config.Version, os.Getpid())
+	}
+	l.LogInfo("Run", "winservice", text)
+}
+
+// finish logs how the service ended and shuts the Logger down. It returns the exit code of the service
+func finish(l *servicelogger.Logger, config Config, err error) uint32 {
+	code := uint32(0)
+	if err != nil {
+		l.LogError("Run", "winservice", fmt.Sprintf("Service %s failed: %s", config.Name, err.Error()))
+		code = ExitServiceFailed
+	}
+	l.LogInfo("Run", "winservice", fmt.Sprintf("Service %s stopped", config.Name))
+	ctx, cancel := context.WithTimeout(context.Background(), config.StopTimeout)
+	defer cancel()
+	_ = l.Shutdown(ctx)
+	return code
+}
+
+// runInteractive runs the service in the foreground, e.g. from a console or on another platform during development,
+// stopping it on an interrupt
+func runInteractive(config Config, open func() (*servicelogger.Logger, error), run RunFunc) error {
+	l, err := open()
+	if err != nil {
+		return fmt.Errorf("unable to initialize logging: %w", err)
+	}
+	banner(l, config)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = run(ctx, l)
+	finish(l, config, err)
+	return err
+}